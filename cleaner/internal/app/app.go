@@ -16,7 +16,7 @@ func Run() {
 	}
 	defer storage.Close()
 
-	cleaner, err := cleaner.NewCleaner(storage)
+	cleaner, err := cleaner.NewCleaner(storage, cfg.QuarantineRetention)
 	if err != nil {
 		log.Fatal("Ошибка создания очистителя:", err)
 	}
@@ -8,11 +8,14 @@ import (
 
 type Cleaner struct {
 	Db *storage.Storage
+	// QuarantineRetention - записи карантина старше удаляются
+	QuarantineRetention time.Duration
 }
 
-func NewCleaner(db *storage.Storage) (*Cleaner, error) {
+func NewCleaner(db *storage.Storage, quarantineRetention time.Duration) (*Cleaner, error) {
 	return &Cleaner{
-		Db: db,
+		Db:                  db,
+		QuarantineRetention: quarantineRetention,
 	}, nil
 }
 
@@ -21,9 +24,9 @@ func (c *Cleaner) CleanOldData() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
-	c.Db.CleanOldData()
+	c.Db.CleanOldData(c.QuarantineRetention)
 
 	for range ticker.C {
-		c.Db.CleanOldData()
+		c.Db.CleanOldData(c.QuarantineRetention)
 	}
 }
@@ -2,11 +2,15 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"time"
 )
 
 type Config struct {
 	DatabaseURL string
+	// QuarantineRetention - сколько хранятся тики, не прошедшие проверку качества
+	QuarantineRetention time.Duration
 }
 
 func LoadConfig() *Config {
@@ -19,6 +23,20 @@ func LoadConfig() *Config {
 	)
 
 	return &Config{
-		DatabaseURL: dbURL,
+		DatabaseURL:         dbURL,
+		QuarantineRetention: durationEnv("QUARANTINE_RETENTION", 7*24*time.Hour),
 	}
 }
+
+func durationEnv(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Некорректное значение %s=%q, используется %s", name, value, def)
+		return def
+	}
+	return d
+}
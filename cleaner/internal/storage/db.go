@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -28,7 +29,8 @@ func (s *Storage) Close() {
 	s.pool.Close()
 }
 
-func (s *Storage) CleanOldData() {
+// CleanOldData - удаляет рыночные данные старше 5 минут и записи карантина старше quarantineRetention
+func (s *Storage) CleanOldData(quarantineRetention time.Duration) {
 	ctx := context.Background()
 	log.Println("Попытка получить advisory lock...")
 
@@ -63,4 +65,15 @@ func (s *Storage) CleanOldData() {
 	}
 
 	log.Printf("Удалено %d строк", res.RowsAffected())
+
+	res, err = s.pool.Exec(ctx, `
+        DELETE FROM quarantine
+        WHERE created_at < NOW() - $1 * INTERVAL '1 second'
+    `, int64(quarantineRetention.Seconds()))
+	if err != nil {
+		log.Printf("Ошибка при удалении карантина: %v", err)
+		return
+	}
+
+	log.Printf("Удалено %d записей карантина (старше %s)", res.RowsAffected(), quarantineRetention)
 }
@@ -20,7 +20,7 @@ func TestNewCleaner(t *testing.T) {
 	defer storage.Close()
 
 	// Test cleaner creation
-	cleaner, err := cleaner.NewCleaner(storage)
+	cleaner, err := cleaner.NewCleaner(storage, time.Hour)
 	require.NoError(t, err)
 	assert.NotNil(t, cleaner)
 	assert.NotNil(t, cleaner.Db)
@@ -32,7 +32,7 @@ func TestCleaner_CleanOldData(t *testing.T) {
 	require.NoError(t, err)
 	defer storage.Close()
 
-	cleaner, err := cleaner.NewCleaner(storage)
+	cleaner, err := cleaner.NewCleaner(storage, time.Hour)
 	require.NoError(t, err)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Second)
@@ -1,9 +1,20 @@
 package main
 
 import (
+	"log"
+	"os"
+
 	"connector/internal/app"
+	"connector/internal/backfill"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		if err := backfill.Run(os.Args[2:]); err != nil {
+			log.Fatalf("backfill: %v", err)
+		}
+		return
+	}
+
 	app.Run()
 }
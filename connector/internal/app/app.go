@@ -5,12 +5,18 @@ import (
 	"log"
 
 	"connector/internal/config"
+	"connector/internal/control"
 	"connector/internal/producer"
+	"connector/internal/status"
 
 	"connector/internal/connectors"
 	"connector/internal/connectors/binance"
 	"connector/internal/connectors/bybit"
 	"connector/internal/connectors/coinbase"
+	"connector/internal/connectors/deribit"
+	"connector/internal/connectors/dex"
+	"connector/internal/connectors/fx"
+	"connector/internal/connectors/generic"
 	"connector/internal/connectors/okx"
 )
 
@@ -19,18 +25,40 @@ func Run() {
 
 	log.Println(cfg)
 
+	if cfg.ReplicaCount < 1 || cfg.ReplicaIndex < 0 || cfg.ReplicaIndex >= cfg.ReplicaCount {
+		log.Fatalf("invalid replica %d of %d", cfg.ReplicaIndex, cfg.ReplicaCount)
+	}
+
+	reporter, err := status.NewRabbitReporter(cfg.RabbitMQURL, cfg.StatusExchange, cfg.Exchange, cfg.ReplicaIndex)
+	if err != nil {
+		log.Fatalf("create status reporter: %v", err)
+	}
+	defer reporter.Close()
+
+	opts := connectors.Options{
+		Shard:      connectors.Shard{Index: cfg.ReplicaIndex, Count: cfg.ReplicaCount},
+		StreamMode: cfg.StreamMode,
+		Status:     reporter,
+	}
+
 	var connector connectors.ExchangeConnector
 	switch cfg.Exchange {
 	case "binance":
-		connector = binance.NewConnector()
+		connector = binance.NewConnector(opts)
 	case "bybit":
-		connector = bybit.NewConnector()
+		connector = bybit.NewConnector(opts)
 	case "okx":
-		connector = okx.NewConnector()
+		connector = okx.NewConnector(opts)
 	case "coinbase":
-		connector = coinbase.NewConnector()
+		connector = coinbase.NewConnector(opts)
+	case "fx":
+		connector = fx.NewConnector(cfg.FX)
+	case "deribit":
+		connector = deribit.NewConnector(cfg.Deribit, opts)
+	case "dex":
+		connector = dex.NewConnector(cfg.Dex, opts)
 	// case "moex":
-	// 	connector = moex.NewConnector()
+	// 	connector = moex.NewConnector(opts)
 	// case "nyse":
 	// 	connector = nyse.NewConnector()
 	// case "nasdaq":
@@ -38,7 +66,16 @@ func Run() {
 	// case "lseg":
 	// 	connector = lseg.NewConnector()
 	default:
-		log.Fatalf("unsupported exchange: %s", cfg.Exchange)
+		if cfg.SpecFile == "" {
+			log.Fatalf("unsupported exchange: %s", cfg.Exchange)
+		}
+		spec, err := generic.LoadSpec(cfg.SpecFile)
+		if err != nil {
+			log.Fatalf("load spec: %v", err)
+		}
+		if connector, err = generic.NewConnector(spec, opts); err != nil {
+			log.Fatalf("create generic connector: %v", err)
+		}
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -53,6 +90,20 @@ func Run() {
 	if err := connector.Connect(ctx); err != nil {
 		log.Fatalf("connect: %v", err)
 	}
+
+	if h, ok := connector.(control.Handler); ok {
+		consumer, err := control.NewConsumer(cfg.RabbitMQURL, cfg.ControlExchange, cfg.Exchange, cfg.ReplicaIndex)
+		if err != nil {
+			log.Fatalf("create control consumer: %v", err)
+		}
+		defer consumer.Close()
+
+		go func() {
+			if err := consumer.Run(ctx, h); err != nil && ctx.Err() == nil {
+				log.Printf("control consumer stopped: %v", err)
+			}
+		}()
+	}
 	if err := connector.SubscribeToMarketData(ctx, pub); err != nil {
 		log.Fatalf("listen & publish: %v", err)
 	}
@@ -0,0 +1,209 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"connector/internal/producer"
+)
+
+// Config - параметры команды connector backfill
+type Config struct {
+	Exchange    string
+	Symbols     []string
+	Interval    Interval
+	From, To    time.Time
+	Queue       string
+	Checkpoint  string
+	Rate        float64 // запросов к REST API в секунду
+	RabbitMQURL string
+	DatabaseURL string
+}
+
+func parseFlags(args []string) (Config, error) {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	exchange := fs.String("exchange", os.Getenv("EXCHANGE"), "exchange: binance, bybit, okx, coinbase")
+	symbols := fs.String("symbols", "", "comma separated symbols, e.g. BTCUSDT,ETHUSDT")
+	interval := fs.String("interval", "1m", "candle interval: 1m, 5m, 15m, 1h, 1d")
+	from := fs.String("from", "", "start date, YYYY-MM-DD or RFC3339")
+	to := fs.String("to", "", "end date (exclusive), YYYY-MM-DD or RFC3339; defaults to now")
+	queue := fs.String("queue", "backfill_candles", "queue for candles")
+	checkpoint := fs.String("checkpoint", "backfill.checkpoint.json", "checkpoint file")
+	rate := fs.Float64("rate", 5, "REST requests per second")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Config{
+		Exchange:    *exchange,
+		Queue:       *queue,
+		Checkpoint:  *checkpoint,
+		Rate:        *rate,
+		RabbitMQURL: os.Getenv("RABBITMQ_URL"),
+		DatabaseURL: os.Getenv("DATABASE_URL"),
+	}
+
+	for _, s := range strings.Split(*symbols, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.Symbols = append(cfg.Symbols, s)
+		}
+	}
+	if cfg.Exchange == "" || len(cfg.Symbols) == 0 {
+		return Config{}, fmt.Errorf("--exchange and --symbols are required")
+	}
+	if cfg.Rate <= 0 {
+		return Config{}, fmt.Errorf("--rate must be positive")
+	}
+
+	var err error
+	if cfg.Interval, err = ParseInterval(*interval); err != nil {
+		return Config{}, err
+	}
+	if cfg.From, err = parseDate(*from); err != nil {
+		return Config{}, fmt.Errorf("--from: %w", err)
+	}
+	cfg.To = time.Now().UTC()
+	if *to != "" {
+		if cfg.To, err = parseDate(*to); err != nil {
+			return Config{}, fmt.Errorf("--to: %w", err)
+		}
+	}
+
+	// границы выравниваются по интервалу, чтобы совпадать с временем открытия свечей
+	cfg.From = cfg.From.Truncate(cfg.Interval.Duration)
+	cfg.To = cfg.To.Truncate(cfg.Interval.Duration)
+	if !cfg.From.Before(cfg.To) {
+		return Config{}, fmt.Errorf("empty range %s - %s", cfg.From, cfg.To)
+	}
+	return cfg, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// Run - дозагружает свечи за период через REST API биржи и публикует их в очередь дозагрузки
+func Run(args []string) error {
+	cfg, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := NewClient(cfg.Rate)
+	defer client.Close()
+
+	fetcher, err := NewFetcher(cfg.Exchange, client)
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := LoadCheckpoint(cfg.Checkpoint)
+	if err != nil {
+		return err
+	}
+
+	var existing *Existing
+	if cfg.DatabaseURL != "" {
+		if existing, err = NewExisting(ctx, cfg.DatabaseURL); err != nil {
+			return err
+		}
+		defer existing.Close()
+	} else {
+		log.Println("backfill: DATABASE_URL is not set, existing candles will not be skipped")
+	}
+
+	pub, err := producer.NewRabbitProducer(cfg.RabbitMQURL, cfg.Queue)
+	if err != nil {
+		return fmt.Errorf("create producer: %w", err)
+	}
+	defer pub.Close()
+
+	for _, symbol := range cfg.Symbols {
+		if err := backfillSymbol(ctx, cfg, symbol, fetcher, checkpoint, existing, pub); err != nil {
+			return fmt.Errorf("%s: %w", symbol, err)
+		}
+	}
+	return nil
+}
+
+func backfillSymbol(ctx context.Context, cfg Config, symbol string, fetcher Fetcher, checkpoint *Checkpoint, existing *Existing, pub producer.MessageProducer) error {
+	key := checkpointKey(cfg.Exchange, symbol, cfg.Interval, cfg.From, cfg.To)
+	from := cfg.From
+	if done, ok := checkpoint.Done(key); ok && done.After(from) {
+		from = done
+	}
+	if !from.Before(cfg.To) {
+		log.Printf("backfill: %s already done up to %s", key, cfg.To.Format(time.RFC3339))
+		return nil
+	}
+
+	have := map[time.Time]struct{}{}
+	if existing != nil {
+		var err error
+		if have, err = existing.OpenTimes(ctx, cfg.Exchange, symbol, cfg.Interval, from, cfg.To); err != nil {
+			return err
+		}
+	}
+
+	ranges := MissingRanges(from, cfg.To, cfg.Interval.Duration, have)
+	log.Printf("backfill: %s from %s, %d candles already stored, %d gaps to fetch",
+		key, from.Format(time.RFC3339), len(have), len(ranges))
+
+	page := time.Duration(fetcher.Limit()) * cfg.Interval.Duration
+	published := 0
+	for _, r := range ranges {
+		for start := r.From; start.Before(r.To); {
+			end := start.Add(page)
+			if end.After(r.To) {
+				end = r.To
+			}
+
+			candles, err := fetcher.FetchKlines(ctx, symbol, cfg.Interval, start, end)
+			if err != nil {
+				return err
+			}
+
+			for _, c := range candles {
+				if c.OpenTime.Before(start) || !c.OpenTime.Before(end) {
+					continue
+				}
+				msg, err := json.Marshal(c)
+				if err != nil {
+					return err
+				}
+				if err := pub.PublishKind(producer.KindCandle, msg); err != nil {
+					return fmt.Errorf("publish candle: %w", err)
+				}
+				published++
+			}
+
+			// диапазон без свечей (биржа не торговала) тоже считается выполненным
+			if err := checkpoint.Advance(key, end); err != nil {
+				return err
+			}
+			start = end
+		}
+	}
+
+	if err := checkpoint.Advance(key, cfg.To); err != nil {
+		return err
+	}
+	log.Printf("backfill: %s done, published %d candles", key, published)
+	return nil
+}
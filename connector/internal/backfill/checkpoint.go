@@ -0,0 +1,81 @@
+package backfill
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Checkpoint - файл с временем, до которого дозагрузка каждого символа уже выполнена.
+// Отметка относится к запрошенному диапазону: при повторном запуске с теми же параметрами
+// работа продолжается с этого места, а запуск за другой диапазон начинается заново.
+type Checkpoint struct {
+	path string
+
+	mu    sync.Mutex
+	state map[string]time.Time
+}
+
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	cp := &Checkpoint{path: path, state: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &cp.state); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %s: %w", path, err)
+	}
+	return cp, nil
+}
+
+func checkpointKey(exchange, symbol string, iv Interval, from, to time.Time) string {
+	return exchange + "/" + symbol + "/" + iv.Name + "/" + from.UTC().Format(time.RFC3339) + "/" + to.UTC().Format(time.RFC3339)
+}
+
+// Done - время, до которого свечи символа уже опубликованы
+func (c *Checkpoint) Done(key string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.state[key]
+	return t, ok
+}
+
+// Advance - сдвигает отметку и сразу сохраняет файл, чтобы прерванный запуск не терял прогресс
+func (c *Checkpoint) Advance(key string, t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if prev, ok := c.state[key]; ok && !t.After(prev) {
+		return nil
+	}
+	c.state[key] = t.UTC()
+
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// запись через временный файл, чтобы не оставить поврежденный checkpoint
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
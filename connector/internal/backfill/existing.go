@@ -0,0 +1,76 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Existing - чтение уже сохраненных свечей из historical_data
+type Existing struct {
+	pool *pgxpool.Pool
+}
+
+func NewExisting(ctx context.Context, dbURL string) (*Existing, error) {
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return &Existing{pool: pool}, nil
+}
+
+func (e *Existing) Close() {
+	e.pool.Close()
+}
+
+// OpenTimes - время открытия свечей символа в [from, to), сохраненных из дозагрузки.
+// Свечи, собранные из тиков, могут быть неполными: такие интервалы дозагружаются заново,
+// и свеча биржи заменяет свечу из тиков
+func (e *Existing) OpenTimes(ctx context.Context, exchange, symbol string, iv Interval, from, to time.Time) (map[time.Time]struct{}, error) {
+	rows, err := e.pool.Query(ctx, `
+		SELECT h.timestamp FROM historical_data h
+		JOIN tickers t ON t.id = h.ticker_id
+		WHERE t.exchange = $1 AND t.symbol = $2 AND h.timeframe = $3
+			AND h.timestamp >= $4 AND h.timestamp < $5 AND h.source = 'backfill'
+	`, exchange, symbol, iv.Name, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("query historical data: %w", err)
+	}
+	defer rows.Close()
+
+	have := make(map[time.Time]struct{})
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			return nil, err
+		}
+		have[ts.UTC()] = struct{}{}
+	}
+	return have, rows.Err()
+}
+
+type TimeRange struct {
+	From, To time.Time
+}
+
+// MissingRanges - непрерывные диапазоны [from, to), в которых нет сохраненных свечей
+func MissingRanges(from, to time.Time, step time.Duration, have map[time.Time]struct{}) []TimeRange {
+	var ranges []TimeRange
+	var current *TimeRange
+
+	for t := from; t.Before(to); t = t.Add(step) {
+		if _, ok := have[t]; ok {
+			current = nil
+			continue
+		}
+		if current == nil {
+			ranges = append(ranges, TimeRange{From: t, To: t.Add(step)})
+			current = &ranges[len(ranges)-1]
+			continue
+		}
+		current.To = t.Add(step)
+	}
+	return ranges
+}
@@ -0,0 +1,260 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Interval - интервал свечей и его обозначение в API каждой биржи
+type Interval struct {
+	Name     string
+	Duration time.Duration
+	params   map[string]string
+}
+
+var intervals = map[string]Interval{
+	"1m":  {"1m", time.Minute, map[string]string{"binance": "1m", "bybit": "1", "okx": "1m", "coinbase": "60"}},
+	"5m":  {"5m", 5 * time.Minute, map[string]string{"binance": "5m", "bybit": "5", "okx": "5m", "coinbase": "300"}},
+	"15m": {"15m", 15 * time.Minute, map[string]string{"binance": "15m", "bybit": "15", "okx": "15m", "coinbase": "900"}},
+	"1h":  {"1h", time.Hour, map[string]string{"binance": "1h", "bybit": "60", "okx": "1H", "coinbase": "3600"}},
+	"1d":  {"1d", 24 * time.Hour, map[string]string{"binance": "1d", "bybit": "D", "okx": "1Dutc", "coinbase": "86400"}},
+}
+
+func ParseInterval(name string) (Interval, error) {
+	iv, ok := intervals[name]
+	if !ok {
+		return Interval{}, fmt.Errorf("unsupported interval %q", name)
+	}
+	return iv, nil
+}
+
+// Candle - свеча, публикуемая в очередь дозагрузки
+type Candle struct {
+	Exchange string    `json:"exchange"`
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	OpenTime time.Time `json:"open_time"`
+	Open     string    `json:"open"`
+	High     string    `json:"high"`
+	Low      string    `json:"low"`
+	Close    string    `json:"close"`
+	Volume   string    `json:"volume"`
+}
+
+// Fetcher - REST API свечей биржи
+type Fetcher interface {
+	// Limit - максимальное число свечей в одном ответе
+	Limit() int
+	// FetchKlines - свечи с временем открытия в [from, to), по возрастанию времени
+	FetchKlines(ctx context.Context, symbol string, iv Interval, from, to time.Time) ([]Candle, error)
+}
+
+func NewFetcher(exchange string, client *Client) (Fetcher, error) {
+	switch exchange {
+	case "binance":
+		return binanceFetcher{client}, nil
+	case "bybit":
+		return bybitFetcher{client}, nil
+	case "okx":
+		return okxFetcher{client}, nil
+	case "coinbase":
+		return coinbaseFetcher{client}, nil
+	default:
+		return nil, fmt.Errorf("backfill is not supported for %s", exchange)
+	}
+}
+
+type binanceFetcher struct{ client *Client }
+
+func (f binanceFetcher) Limit() int { return 1000 }
+
+// FetchKlines - [[openTime, open, high, low, close, volume, closeTime, ...]], endTime включительно
+func (f binanceFetcher) FetchKlines(ctx context.Context, symbol string, iv Interval, from, to time.Time) ([]Candle, error) {
+	q := url.Values{}
+	q.Set("symbol", symbol)
+	q.Set("interval", iv.params["binance"])
+	q.Set("startTime", strconv.FormatInt(from.UnixMilli(), 10))
+	q.Set("endTime", strconv.FormatInt(to.UnixMilli()-1, 10))
+	q.Set("limit", strconv.Itoa(f.Limit()))
+
+	var rows [][]json.Number
+	if err := f.client.GetJSON(ctx, "https://api.binance.com/api/v3/klines?"+q.Encode(), &rows); err != nil {
+		return nil, err
+	}
+	return toCandles("binance", symbol, iv, rows, time.UnixMilli, [5]int{1, 2, 3, 4, 5})
+}
+
+type bybitFetcher struct{ client *Client }
+
+func (f bybitFetcher) Limit() int { return 1000 }
+
+// FetchKlines - result.list: [[start, open, high, low, close, volume, turnover]] по убыванию времени
+func (f bybitFetcher) FetchKlines(ctx context.Context, symbol string, iv Interval, from, to time.Time) ([]Candle, error) {
+	q := url.Values{}
+	q.Set("category", "spot")
+	q.Set("symbol", symbol)
+	q.Set("interval", iv.params["bybit"])
+	q.Set("start", strconv.FormatInt(from.UnixMilli(), 10))
+	q.Set("end", strconv.FormatInt(to.UnixMilli()-1, 10))
+	q.Set("limit", strconv.Itoa(f.Limit()))
+
+	var resp struct {
+		RetCode int    `json:"retCode"`
+		RetMsg  string `json:"retMsg"`
+		Result  struct {
+			List [][]json.Number `json:"list"`
+		} `json:"result"`
+	}
+	if err := f.client.GetJSON(ctx, "https://api.bybit.com/v5/market/kline?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if resp.RetCode != 0 {
+		return nil, fmt.Errorf("API error: %s", resp.RetMsg)
+	}
+	return toCandles("bybit", symbol, iv, resp.Result.List, time.UnixMilli, [5]int{1, 2, 3, 4, 5})
+}
+
+type okxFetcher struct{ client *Client }
+
+func (f okxFetcher) Limit() int { return 100 }
+
+// FetchKlines - history-candles: after - записи раньше ts, before - позже ts; по убыванию времени
+func (f okxFetcher) FetchKlines(ctx context.Context, symbol string, iv Interval, from, to time.Time) ([]Candle, error) {
+	q := url.Values{}
+	q.Set("instId", symbol)
+	q.Set("bar", iv.params["okx"])
+	q.Set("after", strconv.FormatInt(to.UnixMilli(), 10))
+	q.Set("before", strconv.FormatInt(from.UnixMilli()-1, 10))
+	q.Set("limit", strconv.Itoa(f.Limit()))
+
+	var resp struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data [][]json.Number `json:"data"`
+	}
+	if err := f.client.GetJSON(ctx, "https://www.okx.com/api/v5/market/history-candles?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != "0" {
+		return nil, fmt.Errorf("API error: %s", resp.Msg)
+	}
+	return toCandles("okx", symbol, iv, resp.Data, time.UnixMilli, [5]int{1, 2, 3, 4, 5})
+}
+
+type coinbaseFetcher struct{ client *Client }
+
+func (f coinbaseFetcher) Limit() int { return 300 }
+
+// FetchKlines - [[time, low, high, open, close, volume]] по убыванию времени, время в секундах
+func (f coinbaseFetcher) FetchKlines(ctx context.Context, symbol string, iv Interval, from, to time.Time) ([]Candle, error) {
+	q := url.Values{}
+	q.Set("granularity", iv.params["coinbase"])
+	q.Set("start", from.UTC().Format(time.RFC3339))
+	q.Set("end", to.Add(-time.Second).UTC().Format(time.RFC3339))
+
+	var rows [][]json.Number
+	if err := f.client.GetJSON(ctx, "https://api.exchange.coinbase.com/products/"+url.PathEscape(symbol)+"/candles?"+q.Encode(), &rows); err != nil {
+		return nil, err
+	}
+	unix := func(sec int64) time.Time { return time.Unix(sec, 0) }
+	return toCandles("coinbase", symbol, iv, rows, unix, [5]int{3, 2, 1, 4, 5})
+}
+
+// toCandles - строки ответа в свечи; cols - индексы open, high, low, close, volume
+func toCandles(exchange, symbol string, iv Interval, rows [][]json.Number, parseTime func(int64) time.Time, cols [5]int) ([]Candle, error) {
+	candles := make([]Candle, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 6 {
+			return nil, fmt.Errorf("unexpected kline row: %v", row)
+		}
+		ts, err := row[0].Int64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid kline time %q: %w", row[0], err)
+		}
+		candles = append(candles, Candle{
+			Exchange: exchange,
+			Symbol:   symbol,
+			Interval: iv.Name,
+			OpenTime: parseTime(ts).UTC(),
+			Open:     row[cols[0]].String(),
+			High:     row[cols[1]].String(),
+			Low:      row[cols[2]].String(),
+			Close:    row[cols[3]].String(),
+			Volume:   row[cols[4]].String(),
+		})
+	}
+	sort.Slice(candles, func(i, j int) bool { return candles[i].OpenTime.Before(candles[j].OpenTime) })
+	return candles, nil
+}
+
+// Client - HTTP-клиент с ограничением частоты запросов и повтором при 429
+type Client struct {
+	http    *http.Client
+	limiter *time.Ticker
+}
+
+const maxRetries = 5
+
+func NewClient(requestsPerSecond float64) *Client {
+	return &Client{
+		http:    &http.Client{Timeout: 30 * time.Second},
+		limiter: time.NewTicker(time.Duration(float64(time.Second) / requestsPerSecond)),
+	}
+}
+
+func (c *Client) GetJSON(ctx context.Context, rawURL string, dst interface{}) error {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.limiter.C:
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return fmt.Errorf("get %s: %w", rawURL, err)
+		}
+
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot || resp.StatusCode >= 500
+		if retry && attempt < maxRetries {
+			resp.Body.Close()
+			wait := backoff
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+				wait = time.Duration(s) * time.Second
+			}
+			log.Printf("backfill: %s, retry in %s", resp.Status, wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			backoff *= 2
+			continue
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("get %s: unexpected status %s", rawURL, resp.Status)
+		}
+
+		dec := json.NewDecoder(resp.Body)
+		dec.UseNumber()
+		return dec.Decode(dst)
+	}
+}
+
+func (c *Client) Close() {
+	c.limiter.Stop()
+}
@@ -2,12 +2,48 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+type FXConfig struct {
+	Provider string
+	URL      string
+	Base     string
+	Interval time.Duration
+}
+
+// DeribitConfig - адрес API задается, чтобы в тестах его можно было заменить заглушкой
+type DeribitConfig struct {
+	URL        string
+	Currencies []string
+}
+
+// DexConfig - узел Ethereum JSON-RPC и файл со списком пулов
+type DexConfig struct {
+	RPCURL       string
+	PoolsFile    string
+	PollInterval time.Duration
+}
+
 type Config struct {
 	Exchange    string
 	Queue       string
 	RabbitMQURL string
+	// ReplicaIndex и ReplicaCount задают долю символов биржи для этой реплики
+	ReplicaIndex int
+	ReplicaCount int
+	StreamMode   string
+	// ControlExchange - обменник команд управления подписками
+	ControlExchange string
+	// StatusExchange - topic-обменник событий жизненного цикла коннектора
+	StatusExchange string
+	// SpecFile - спецификация биржи для универсального коннектора
+	SpecFile string
+	FX       FXConfig
+	Deribit  DeribitConfig
+	Dex      DexConfig
 }
 
 func LoadConfig() Config {
@@ -16,8 +52,56 @@ func LoadConfig() Config {
 	rabbitMQURL := os.Getenv("RABBITMQ_URL")
 
 	return Config{
-		Exchange:    exchange,
-		Queue:       queue,
-		RabbitMQURL: rabbitMQURL,
+		Exchange:        exchange,
+		Queue:           queue,
+		RabbitMQURL:     rabbitMQURL,
+		ReplicaIndex:    intEnv("REPLICA_INDEX", 0),
+		ReplicaCount:    intEnv("REPLICA_COUNT", 1),
+		StreamMode:      os.Getenv("STREAM_MODE"),
+		ControlExchange: stringEnv("CONTROL_EXCHANGE", queue+"_control"),
+		StatusExchange:  stringEnv("STATUS_EXCHANGE", "connector_status"),
+		SpecFile:        os.Getenv("SPEC_FILE"),
+		FX: FXConfig{
+			Provider: os.Getenv("FX_PROVIDER"),
+			URL:      os.Getenv("FX_URL"),
+			Base:     os.Getenv("FX_BASE"),
+			Interval: durationEnv("FX_INTERVAL", time.Hour),
+		},
+		Deribit: DeribitConfig{
+			URL:        stringEnv("DERIBIT_URL", "https://www.deribit.com"),
+			Currencies: strings.Split(stringEnv("DERIBIT_CURRENCIES", "BTC,ETH"), ","),
+		},
+		Dex: DexConfig{
+			RPCURL:       os.Getenv("DEX_RPC_URL"),
+			PoolsFile:    stringEnv("DEX_POOLS", "specs/dex_pools.yaml"),
+			PollInterval: durationEnv("DEX_POLL_INTERVAL", 4*time.Second),
+		},
+	}
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+func intEnv(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func stringEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
+	return def
 }
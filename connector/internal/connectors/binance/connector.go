@@ -7,84 +7,263 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"connector/internal/connectors"
+	"connector/internal/control"
 	"connector/internal/producer"
+	"connector/internal/status"
+	"connector/internal/ws"
+)
 
-	"github.com/gorilla/websocket"
+// Режимы подписки: поток @ticker на каждый символ или общий поток по всему рынку
+const (
+	ModeSymbolStreams = "streams"
+	ModeTickerArr     = "ticker_arr"
+	ModeMiniTickerArr = "mini_ticker_arr"
 )
 
+var arrayStreams = map[string]string{
+	ModeTickerArr:     "!ticker@arr",
+	ModeMiniTickerArr: "!miniTicker@arr",
+}
+
 type BinanceConnector struct {
-	tickers [][]string // разбиение на чанки
+	opts        connectors.Options
+	tickers     [][]string // разбиение на чанки
+	instruments []connectors.Instrument
+	subs        connectors.Subscriptions
+
+	universeMu sync.RWMutex
+	universe   map[string]struct{} // символы реплики для режимов с общим потоком
 }
 
 type ExchangeInfo struct {
 	Symbols []struct {
-		Symbol string `json:"symbol"`
-		Status string `json:"status"`
+		Symbol     string `json:"symbol"`
+		Status     string `json:"status"`
+		BaseAsset  string `json:"baseAsset"`
+		QuoteAsset string `json:"quoteAsset"`
+		Filters    []struct {
+			FilterType  string `json:"filterType"`
+			TickSize    string `json:"tickSize"`
+			StepSize    string `json:"stepSize"`
+			MinNotional string `json:"minNotional"`
+		} `json:"filters"`
 	} `json:"symbols"`
 }
 
 type StreamResponse struct {
 	Stream string          `json:"stream"`
 	Data   json.RawMessage `json:"data"`
+	ID     int64           `json:"id"`
+	Error  *struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
 }
 
-func NewConnector() *BinanceConnector {
-	return &BinanceConnector{}
+type arrayItem struct {
+	Symbol string `json:"s"`
+}
+
+// errTooManyRequests - код ошибки Binance при превышении лимита сообщений в сокете
+const errTooManyRequests = -1003
+
+// requestID - идентификатор запросов SUBSCRIBE/UNSUBSCRIBE в сокете
+var requestID atomic.Int64
+
+func NewConnector(opts connectors.Options) *BinanceConnector {
+	return &BinanceConnector{opts: opts}
 }
 
 func (c *BinanceConnector) Connect(ctx context.Context) error {
+	instruments, err := c.fetchInstruments(ctx)
+	if err != nil {
+		return err
+	}
+	c.instruments = instruments
+
+	var all []string
+	c.universe = make(map[string]struct{})
+	for _, inst := range instruments {
+		if inst.Status == "TRADING" {
+			all = append(all, inst.Symbol)
+			c.universe[inst.Symbol] = struct{}{}
+		}
+	}
+
+	log.Printf("Binance: found %d active trading pairs for shard %d/%d", len(all), c.opts.Shard.Index, c.opts.Shard.Count)
+	c.tickers = chunkTickers(all, 200)
+	return nil
+}
+
+// fetchInstruments - справочник exchangeInfo; шаг цены, лота и минимальная сумма заявки берутся из фильтров символа
+func (c *BinanceConnector) fetchInstruments(ctx context.Context) ([]connectors.Instrument, error) {
 	resp, err := http.Get("https://api.binance.com/api/v3/exchangeInfo")
 	if err != nil {
-		return fmt.Errorf("failed to get exchangeInfo: %w", err)
+		return nil, fmt.Errorf("failed to get exchangeInfo: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := c.opts.CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("failed to get exchangeInfo: %w", err)
+	}
+
 	var info ExchangeInfo
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return fmt.Errorf("decode exchange info: %w", err)
+		return nil, fmt.Errorf("decode exchange info: %w", err)
 	}
 
-	var all []string
+	instruments := make([]connectors.Instrument, 0, len(info.Symbols))
 	for _, s := range info.Symbols {
-		if s.Status == "TRADING" {
-			all = append(all, strings.ToLower(s.Symbol)+"@ticker")
+		inst := connectors.Instrument{
+			Exchange: "binance",
+			Symbol:   s.Symbol,
+			Base:     s.BaseAsset,
+			Quote:    s.QuoteAsset,
+			Status:   s.Status,
 		}
+		for _, f := range s.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				inst.TickSize = f.TickSize
+			case "LOT_SIZE":
+				inst.LotSize = f.StepSize
+			case "NOTIONAL", "MIN_NOTIONAL":
+				inst.MinNotional = f.MinNotional
+			}
+		}
+		instruments = append(instruments, inst)
 	}
 
-	log.Printf("Binance: found %d active trading pairs", len(all))
-	c.tickers = chunkTickers(all, 200)
-	return nil
+	return c.opts.OwnedInstruments(instruments), nil
 }
 
 func (c *BinanceConnector) SubscribeToMarketData(ctx context.Context, pub producer.MessageProducer) error {
+	connectors.PublishInstruments(pub, c.instruments)
+	go connectors.RefreshInstruments(ctx, pub, c.fetchInstruments)
+
+	switch c.opts.StreamMode {
+	case ModeTickerArr, ModeMiniTickerArr:
+		return c.subscribeToArrayStream(ctx, pub, arrayStreams[c.opts.StreamMode])
+	case ModeSymbolStreams, "":
+	default:
+		return fmt.Errorf("unsupported binance stream mode: %s", c.opts.StreamMode)
+	}
+
 	for _, chunk := range c.tickers {
-		streamURL := "wss://stream.binance.com:9443/stream?streams=" + strings.Join(chunk, "/")
+		streams := make([]string, len(chunk))
+		for i, symbol := range chunk {
+			streams[i] = streamName(symbol)
+		}
 
-		conn, _, err := websocket.DefaultDialer.Dial(streamURL, nil)
-		if err != nil {
-			log.Printf("websocket dial failed: %v", err)
+		conn := ws.NewWSClient("wss://stream.binance.com:9443/stream?streams=" + strings.Join(streams, "/"))
+		if err := conn.Connect(); err != nil {
+			c.opts.Report(status.Disconnected, fmt.Sprintf("dial: %v", err))
 			continue
 		}
+		// подписка задается в URL, отдельного подтверждения нет
+		c.opts.Report(status.Connected, fmt.Sprintf("%d streams", len(chunk)))
+		c.opts.Report(status.SubscribeAck, fmt.Sprintf("%d streams", len(chunk)))
+
+		stream := connectors.NewStream(conn, chunk)
+		c.subs.Add(stream)
 
-		go handleConnection(ctx, conn, pub)
+		go c.handleConnection(ctx, stream, pub)
 	}
 
 	<-ctx.Done()
 	return ctx.Err()
 }
 
-func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.MessageProducer) {
-	defer conn.Close()
+func (c *BinanceConnector) HandleCommand(ctx context.Context, cmd control.Command) ([]string, error) {
+	for i, symbol := range cmd.Symbols {
+		cmd.Symbols[i] = strings.ToUpper(symbol)
+	}
+
+	if _, ok := arrayStreams[c.opts.StreamMode]; ok {
+		switch cmd.Action {
+		case control.Subscribe, control.Unsubscribe:
+			return c.updateUniverse(c.opts.Shard.Filter(cmd.Symbols), cmd.Action == control.Subscribe), nil
+		}
+	}
+	return c.subs.HandleCommand(cmd, c.opts.Shard, sendSubscription)
+}
+
+// updateUniverse - в режимах с общим потоком подписка меняется локальным фильтром
+func (c *BinanceConnector) updateUniverse(symbols []string, subscribe bool) []string {
+	c.universeMu.Lock()
+	defer c.universeMu.Unlock()
+
+	for _, symbol := range symbols {
+		if subscribe {
+			c.universe[symbol] = struct{}{}
+		} else {
+			delete(c.universe, symbol)
+		}
+	}
+	return symbols
+}
+
+func (c *BinanceConnector) inUniverse(symbol string) bool {
+	c.universeMu.RLock()
+	defer c.universeMu.RUnlock()
+	_, ok := c.universe[symbol]
+	return ok
+}
+
+func sendSubscription(conn *ws.WSClient, symbols []string, subscribe bool) error {
+	method := "UNSUBSCRIBE"
+	if subscribe {
+		method = "SUBSCRIBE"
+	}
+
+	params := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		params[i] = streamName(symbol)
+	}
+
+	return conn.WriteJSON(map[string]interface{}{
+		"method": method,
+		"params": params,
+		"id":     requestID.Add(1),
+	})
+}
+
+func streamName(symbol string) string {
+	return strings.ToLower(symbol) + "@ticker"
+}
+
+// reportSubscription - ответ на SUBSCRIBE: {"result": null, "id": N} или {"error": {...}, "id": N}
+func (c *BinanceConnector) reportSubscription(resp StreamResponse) {
+	if resp.ID == 0 {
+		return
+	}
+	if resp.Error != nil {
+		event := status.SubscribeError
+		if resp.Error.Code == errTooManyRequests {
+			event = status.RateLimited
+		}
+		c.opts.Report(event, fmt.Sprintf("request %d: %d %s", resp.ID, resp.Error.Code, resp.Error.Msg))
+		return
+	}
+	c.opts.Report(status.SubscribeAck, fmt.Sprintf("request %d", resp.ID))
+}
+
+func (c *BinanceConnector) handleConnection(ctx context.Context, stream *connectors.Stream, pub producer.MessageProducer) {
+	defer c.subs.Remove(stream)
+	defer stream.Conn.Close()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			_, msg, err := conn.ReadMessage()
+			msg, err := stream.Conn.ReadMessage()
 			if err != nil {
-				log.Printf("read error: %v", err)
+				c.opts.Report(status.Disconnected, fmt.Sprintf("read: %v", err))
 				return
 			}
 
@@ -94,6 +273,15 @@ func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.Me
 				continue
 			}
 
+			// ответы на SUBSCRIBE/UNSUBSCRIBE не содержат данных
+			if len(streamMsg.Data) == 0 {
+				c.reportSubscription(streamMsg)
+				continue
+			}
+			if c.subs.Paused() {
+				continue
+			}
+
 			if err := pub.Publish(streamMsg.Data); err != nil {
 				log.Printf("publish error: %v", err)
 			}
@@ -101,6 +289,71 @@ func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.Me
 	}
 }
 
+// subscribeToArrayStream - один сокет на весь рынок: массив тикеров разбивается
+// на отдельные сообщения и фильтруется по символам реплики
+func (c *BinanceConnector) subscribeToArrayStream(ctx context.Context, pub producer.MessageProducer, stream string) error {
+	conn := ws.NewWSClient("wss://stream.binance.com:9443/stream?streams=" + stream)
+	if err := conn.Connect(); err != nil {
+		c.opts.Report(status.Disconnected, fmt.Sprintf("dial: %v", err))
+		return fmt.Errorf("websocket dial failed: %w", err)
+	}
+	c.opts.Report(status.Connected, stream)
+	log.Printf("Binance: subscribed to %s for %d symbols", stream, len(c.universe))
+
+	go c.handleArrayConnection(ctx, conn, pub)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *BinanceConnector) handleArrayConnection(ctx context.Context, conn *ws.WSClient, pub producer.MessageProducer) {
+	defer conn.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				c.opts.Report(status.Disconnected, fmt.Sprintf("read: %v", err))
+				return
+			}
+
+			if c.subs.Paused() {
+				continue
+			}
+
+			var streamMsg StreamResponse
+			if err := json.Unmarshal(msg, &streamMsg); err != nil {
+				log.Printf("unmarshal error: %v", err)
+				continue
+			}
+
+			var items []json.RawMessage
+			if err := json.Unmarshal(streamMsg.Data, &items); err != nil {
+				log.Printf("unmarshal array error: %v", err)
+				continue
+			}
+
+			for _, data := range items {
+				var item arrayItem
+				if err := json.Unmarshal(data, &item); err != nil {
+					log.Printf("unmarshal item error: %v", err)
+					continue
+				}
+				if !c.inUniverse(item.Symbol) {
+					continue
+				}
+
+				if err := pub.Publish(data); err != nil {
+					log.Printf("publish error: %v", err)
+				}
+			}
+		}
+	}
+}
+
 func chunkTickers(tickers []string, size int) [][]string {
 	var chunks [][]string
 	for size < len(tickers) {
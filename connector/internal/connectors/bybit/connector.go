@@ -6,91 +6,142 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"connector/internal/connectors"
+	"connector/internal/control"
 	"connector/internal/producer"
-
-	"github.com/gorilla/websocket"
+	"connector/internal/status"
+	"connector/internal/ws"
 )
 
 type BybitConnector struct {
+	opts         connectors.Options
 	symbolChunks [][]string
+	instruments  []connectors.Instrument
+	sequences    *connectors.SequenceTracker
+	subs         connectors.Subscriptions
 }
 
 type instrumentResponse struct {
 	Result struct {
 		List []struct {
-			Symbol string `json:"symbol"`
-			Status string `json:"status"`
+			Symbol        string `json:"symbol"`
+			Status        string `json:"status"`
+			BaseCoin      string `json:"baseCoin"`
+			QuoteCoin     string `json:"quoteCoin"`
+			LotSizeFilter struct {
+				BasePrecision string `json:"basePrecision"`
+				MinOrderAmt   string `json:"minOrderAmt"`
+			} `json:"lotSizeFilter"`
+			PriceFilter struct {
+				TickSize string `json:"tickSize"`
+			} `json:"priceFilter"`
 		} `json:"list"`
 	} `json:"result"`
 }
 
 type StreamResponse struct {
-	Topic string          `json:"topic"`
-	Ts    int64           `json:"ts"`
-	Type  string          `json:"type"`
-	CS    int64           `json:"cs"`
-	Data  json.RawMessage `json:"data"`
+	Topic   string          `json:"topic"`
+	Ts      int64           `json:"ts"`
+	Type    string          `json:"type"`
+	CS      int64           `json:"cs"`
+	Data    json.RawMessage `json:"data"`
+	Op      string          `json:"op"`
+	Success bool            `json:"success"`
+	RetMsg  string          `json:"ret_msg"`
 }
 
-func NewConnector() *BybitConnector {
-	return &BybitConnector{}
+func NewConnector(opts connectors.Options) *BybitConnector {
+	return &BybitConnector{
+		opts: opts,
+		// cs - общий счетчик инструмента, растет монотонно, но не на единицу между тикерами;
+		// непрерывного публичного счетчика у Bybit нет (ID сделок деривативов - UUID),
+		// поэтому проверяется только порядок, без поиска разрывов
+		sequences: connectors.NewSequenceTracker("bybit"),
+	}
 }
 
 func (c *BybitConnector) Connect(ctx context.Context) error {
+	instruments, err := c.fetchInstruments(ctx)
+	if err != nil {
+		return err
+	}
+	c.instruments = instruments
+
+	var all []string
+	for _, inst := range instruments {
+		if inst.Status == "Trading" {
+			all = append(all, inst.Symbol)
+		}
+	}
+
+	log.Printf("Bybit: found %d active symbols for shard %d/%d", len(all), c.opts.Shard.Index, c.opts.Shard.Count)
+	c.symbolChunks = chunkStrings(all, 10)
+	return nil
+}
+
+// fetchInstruments - справочник спотовых инструментов; время листинга для спота Bybit не публикует
+func (c *BybitConnector) fetchInstruments(ctx context.Context) ([]connectors.Instrument, error) {
 	resp, err := http.Get("https://api.bybit.com/v5/market/instruments-info?category=spot")
 	if err != nil {
-		return fmt.Errorf("get instruments: %w", err)
+		return nil, fmt.Errorf("get instruments: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := c.opts.CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("get instruments: %w", err)
+	}
+
 	var result instrumentResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("decode instruments: %w", err)
+		return nil, fmt.Errorf("decode instruments: %w", err)
 	}
 
-	var all []string
+	instruments := make([]connectors.Instrument, 0, len(result.Result.List))
 	for _, s := range result.Result.List {
-		if s.Status == "Trading" {
-			all = append(all, s.Symbol)
-		}
+		instruments = append(instruments, connectors.Instrument{
+			Exchange:    "bybit",
+			Symbol:      s.Symbol,
+			Base:        s.BaseCoin,
+			Quote:       s.QuoteCoin,
+			TickSize:    s.PriceFilter.TickSize,
+			LotSize:     s.LotSizeFilter.BasePrecision,
+			MinNotional: s.LotSizeFilter.MinOrderAmt,
+			Status:      s.Status,
+		})
 	}
 
-	log.Printf("Bybit: found %d active symbols", len(all))
-	c.symbolChunks = chunkStrings(all, 10)
-	return nil
+	return c.opts.OwnedInstruments(instruments), nil
 }
 
 func (c *BybitConnector) SubscribeToMarketData(ctx context.Context, pub producer.MessageProducer) error {
+	connectors.PublishInstruments(pub, c.instruments)
+	go connectors.RefreshInstruments(ctx, pub, c.fetchInstruments)
+
 	for _, chunk := range c.symbolChunks {
-		conn, _, err := websocket.DefaultDialer.Dial("wss://stream.bybit.com/v5/public/spot", nil)
-		if err != nil {
-			log.Printf("dial error: %v", err)
+		conn := ws.NewWSClient("wss://stream.bybit.com/v5/public/spot")
+		if err := conn.Connect(); err != nil {
+			c.opts.Report(status.Disconnected, fmt.Sprintf("dial: %v", err))
 			continue
 		}
+		c.opts.Report(status.Connected, fmt.Sprintf("%s, %d symbols", conn.URL, len(chunk)))
 
-		var args []string
-		for _, s := range chunk {
-			args = append(args, "tickers."+s)
-		}
-
-		subMsg := map[string]interface{}{
-			"op":   "subscribe",
-			"args": args,
-		}
-
-		if err := conn.WriteJSON(subMsg); err != nil {
+		if err := sendSubscription(conn, chunk, true); err != nil {
 			log.Printf("subscribe error: %v", err)
 			continue
 		}
 
-		_, msg, err := conn.ReadMessage()
+		msg, err := conn.ReadMessage()
 		if err != nil {
 			log.Printf("read subscription response error: %v", err)
 			continue
 		}
-		log.Printf("subscription response: %s", string(msg))
+		c.reportSubscription(msg)
+
+		stream := connectors.NewStream(conn, chunk)
+		c.subs.Add(stream)
 
 		go func() {
 			ticker := time.NewTicker(15 * time.Second)
@@ -110,24 +161,67 @@ func (c *BybitConnector) SubscribeToMarketData(ctx context.Context, pub producer
 			}
 		}()
 
-		go handleConnection(ctx, conn, pub)
+		go c.handleConnection(ctx, stream, pub)
 	}
 
 	<-ctx.Done()
 	return ctx.Err()
 }
 
-func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.MessageProducer) {
-	defer conn.Close()
+func (c *BybitConnector) HandleCommand(ctx context.Context, cmd control.Command) ([]string, error) {
+	return c.subs.HandleCommand(cmd, c.opts.Shard, sendSubscription)
+}
+
+func sendSubscription(conn *ws.WSClient, symbols []string, subscribe bool) error {
+	op := "unsubscribe"
+	if subscribe {
+		op = "subscribe"
+	}
+
+	// Bybit принимает не более 10 аргументов в одном запросе
+	for _, chunk := range chunkStrings(symbols, 10) {
+		var args []string
+		for _, s := range chunk {
+			args = append(args, "tickers."+s)
+		}
+
+		if err := conn.WriteJSON(map[string]interface{}{
+			"op":   op,
+			"args": args,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportSubscription - публикует результат подписки из ответа {"op": "subscribe", "success": ...}
+func (c *BybitConnector) reportSubscription(msg []byte) {
+	var resp StreamResponse
+	if err := json.Unmarshal(msg, &resp); err != nil || resp.Op != "subscribe" {
+		log.Printf("subscription response: %s", string(msg))
+		return
+	}
+
+	if resp.Success {
+		c.opts.Report(status.SubscribeAck, string(msg))
+	} else {
+		c.opts.Report(status.SubscribeError, resp.RetMsg)
+	}
+}
+
+func (c *BybitConnector) handleConnection(ctx context.Context, stream *connectors.Stream, pub producer.MessageProducer) {
+	defer c.subs.Remove(stream)
+	defer stream.Conn.Close()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			_, msg, err := conn.ReadMessage()
+			msg, err := stream.Conn.ReadMessage()
 			if err != nil {
-				log.Printf("read error: %v", err)
+				c.opts.Report(status.Disconnected, fmt.Sprintf("read: %v", err))
 				return
 			}
 
@@ -137,8 +231,28 @@ func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.Me
 				continue
 			}
 
+			if streamMsg.Op == "subscribe" {
+				c.reportSubscription(msg)
+				continue
+			}
+
+			// ответы на подписку и pong не содержат данных
+			if len(streamMsg.Data) == 0 {
+				continue
+			}
+
 			log.Printf("streamMsg: %v", streamMsg)
 
+			if symbol, ok := strings.CutPrefix(streamMsg.Topic, "tickers."); ok {
+				if ev, ok := c.sequences.Observe(symbol, streamMsg.CS); ok {
+					connectors.PublishSequenceEvent(pub, ev)
+				}
+			}
+
+			if c.subs.Paused() {
+				continue
+			}
+
 			if err := pub.Publish(streamMsg.Data); err != nil {
 				log.Printf("publish error: %v", err)
 			}
@@ -6,20 +6,35 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"connector/internal/connectors"
+	"connector/internal/control"
 	"connector/internal/producer"
-
-	"github.com/gorilla/websocket"
+	"connector/internal/status"
+	"connector/internal/ws"
 )
 
+// heartbeatTimeout - через сколько без heartbeat подписка считается оборванной
+const heartbeatTimeout = 5 * time.Second
+
 type CoinbaseConnector struct {
+	opts         connectors.Options
 	symbolChunks [][]string
+	instruments  []connectors.Instrument
+	sequences    *connectors.SequenceTracker
+	subs         connectors.Subscriptions
 }
 
 type productResponse []struct {
-	ProductID string `json:"id"`
-	Status    string `json:"status"`
+	ProductID      string `json:"id"`
+	Status         string `json:"status"`
+	BaseCurrency   string `json:"base_currency"`
+	QuoteCurrency  string `json:"quote_currency"`
+	QuoteIncrement string `json:"quote_increment"`
+	BaseIncrement  string `json:"base_increment"`
+	MinMarketFunds string `json:"min_market_funds"`
 }
 
 type StreamResponse struct {
@@ -40,99 +55,187 @@ type StreamResponse struct {
 	Time        string `json:"time"`
 	TradeID     int64  `json:"trade_id"`
 	LastSize    string `json:"last_size"`
+	LastTradeID int64  `json:"last_trade_id,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Reason      string `json:"reason,omitempty"`
 }
 
-func NewConnector() *CoinbaseConnector {
-	return &CoinbaseConnector{}
+func NewConnector(opts connectors.Options) *CoinbaseConnector {
+	return &CoinbaseConnector{
+		opts: opts,
+		// отслеживаются ID сделок: sequence тикера - общий счетчик продукта с пропусками,
+		// а trade_id непрерывен, и heartbeat сообщает last_trade_id для проверки разрыва
+		sequences: connectors.NewSequenceTracker("coinbase"),
+	}
 }
 
 func (c *CoinbaseConnector) Connect(ctx context.Context) error {
+	instruments, err := c.fetchInstruments(ctx)
+	if err != nil {
+		return err
+	}
+	c.instruments = instruments
+
+	var all []string
+	for _, inst := range instruments {
+		if inst.Status == "online" {
+			all = append(all, inst.Symbol)
+		}
+	}
+
+	log.Printf("Coinbase: found %d active products for shard %d/%d", len(all), c.opts.Shard.Index, c.opts.Shard.Count)
+	c.symbolChunks = chunkStrings(all, 10)
+	return nil
+}
+
+// fetchInstruments - справочник продуктов; время листинга Coinbase не публикует
+func (c *CoinbaseConnector) fetchInstruments(ctx context.Context) ([]connectors.Instrument, error) {
 	resp, err := http.Get("https://api.exchange.coinbase.com/products")
 	if err != nil {
-		return fmt.Errorf("get products: %w", err)
+		return nil, fmt.Errorf("get products: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := c.opts.CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("get products: %w", err)
+	}
+
 	var result productResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("decode products: %w", err)
+		return nil, fmt.Errorf("decode products: %w", err)
 	}
 
-	var all []string
+	instruments := make([]connectors.Instrument, 0, len(result))
 	for _, p := range result {
-		if p.Status == "online" {
-			all = append(all, p.ProductID)
-		}
+		instruments = append(instruments, connectors.Instrument{
+			Exchange:    "coinbase",
+			Symbol:      p.ProductID,
+			Base:        p.BaseCurrency,
+			Quote:       p.QuoteCurrency,
+			TickSize:    p.QuoteIncrement,
+			LotSize:     p.BaseIncrement,
+			MinNotional: p.MinMarketFunds,
+			Status:      p.Status,
+		})
 	}
 
-	log.Printf("Coinbase: found %d active products", len(all))
-	c.symbolChunks = chunkStrings(all, 10)
-	return nil
+	return c.opts.OwnedInstruments(instruments), nil
 }
 
 func (c *CoinbaseConnector) SubscribeToMarketData(ctx context.Context, pub producer.MessageProducer) error {
+	connectors.PublishInstruments(pub, c.instruments)
+	go connectors.RefreshInstruments(ctx, pub, c.fetchInstruments)
+
 	for _, chunk := range c.symbolChunks {
-		conn, _, err := websocket.DefaultDialer.Dial("wss://ws-feed.exchange.coinbase.com", nil)
-		if err != nil {
-			log.Printf("dial error: %v", err)
+		conn := ws.NewWSClient("wss://ws-feed.exchange.coinbase.com")
+		if err := conn.Connect(); err != nil {
+			c.opts.Report(status.Disconnected, fmt.Sprintf("dial: %v", err))
 			continue
 		}
+		c.opts.Report(status.Connected, fmt.Sprintf("%s, %d products", conn.URL, len(chunk)))
 
-		subMsg := map[string]interface{}{
-			"type":        "subscribe",
-			"channels":    []string{"ticker"},
-			"product_ids": chunk,
-		}
-
-		if err := conn.WriteJSON(subMsg); err != nil {
+		if err := sendSubscription(conn, chunk, true); err != nil {
 			log.Printf("subscribe error: %v", err)
 			continue
 		}
 
-		_, msg, err := conn.ReadMessage()
+		msg, err := conn.ReadMessage()
 		if err != nil {
 			log.Printf("read subscription response error: %v", err)
 			continue
 		}
-		log.Printf("subscription response: %s", string(msg))
+		var resp StreamResponse
+		if err := json.Unmarshal(msg, &resp); err == nil {
+			c.reportSubscription(resp)
+		}
+
+		stream := connectors.NewStream(conn, chunk)
+		c.subs.Add(stream)
+
+		connCtx, cancel := context.WithCancel(ctx)
+		var lastHeartbeat atomic.Int64
+		lastHeartbeat.Store(time.Now().UnixNano())
 
 		go func() {
-			ticker := time.NewTicker(15 * time.Second)
+			ticker := time.NewTicker(time.Second)
 			defer ticker.Stop()
 			for {
 				select {
-				case <-ctx.Done():
+				case <-connCtx.Done():
 					return
 				case <-ticker.C:
-					if err := conn.WriteJSON(map[string]interface{}{
-						"type": "heartbeat",
-						"on":   true,
-					}); err != nil {
-						log.Printf("heartbeat error: %v", err)
+					if time.Since(time.Unix(0, lastHeartbeat.Load())) < heartbeatTimeout {
+						continue
+					}
+
+					products := stream.Symbols()
+					log.Printf("Coinbase: missed heartbeat for %d products, resubscribing", len(products))
+					c.sequences.Reset(products...)
+					lastHeartbeat.Store(time.Now().UnixNano())
+					if err := sendSubscription(conn, products, true); err != nil {
+						log.Printf("resubscribe error: %v", err)
+						cancel()
 						return
 					}
 				}
 			}
 		}()
 
-		go handleConnection(ctx, conn, pub)
+		go func() {
+			defer cancel()
+			c.handleConnection(connCtx, stream, pub, &lastHeartbeat)
+		}()
 	}
 
 	<-ctx.Done()
 	return ctx.Err()
 }
 
-func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.MessageProducer) {
-	defer conn.Close()
+func (c *CoinbaseConnector) HandleCommand(ctx context.Context, cmd control.Command) ([]string, error) {
+	symbols, err := c.subs.HandleCommand(cmd, c.opts.Shard, sendSubscription)
+	if cmd.Action == control.Resync || cmd.Action == control.Unsubscribe {
+		c.sequences.Reset(symbols...)
+	}
+	return symbols, err
+}
+
+// sendSubscription - канал heartbeat присылает сообщение по каждому продукту раз в секунду,
+// по нему отличаем тихий рынок от оборванной подписки
+func sendSubscription(conn *ws.WSClient, products []string, subscribe bool) error {
+	msgType := "unsubscribe"
+	if subscribe {
+		msgType = "subscribe"
+	}
+
+	return conn.WriteJSON(map[string]interface{}{
+		"type":        msgType,
+		"channels":    []string{"ticker", "heartbeat"},
+		"product_ids": products,
+	})
+}
+
+// reportSubscription - в ответ на подписку Coinbase присылает {"type": "subscriptions"} или {"type": "error"}
+func (c *CoinbaseConnector) reportSubscription(resp StreamResponse) {
+	switch resp.Type {
+	case "subscriptions":
+		c.opts.Report(status.SubscribeAck, "ticker, heartbeat")
+	case "error":
+		c.opts.Report(status.SubscribeError, resp.Message+": "+resp.Reason)
+	}
+}
+
+func (c *CoinbaseConnector) handleConnection(ctx context.Context, stream *connectors.Stream, pub producer.MessageProducer, lastHeartbeat *atomic.Int64) {
+	defer c.subs.Remove(stream)
+	defer stream.Conn.Close()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			_, msg, err := conn.ReadMessage()
+			msg, err := stream.Conn.ReadMessage()
 			if err != nil {
-				log.Printf("read error: %v", err)
+				c.opts.Report(status.Disconnected, fmt.Sprintf("read: %v", err))
 				return
 			}
 
@@ -142,9 +245,27 @@ func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.Me
 				continue
 			}
 
-			if streamMsg.Type == "ticker" {
+			switch streamMsg.Type {
+			case "subscriptions", "error":
+				c.reportSubscription(streamMsg)
+			case "heartbeat":
+				lastHeartbeat.Store(time.Now().UnixNano())
+				// тикер приходит на каждую сделку (каскад сделок - одним тикером с последним trade_id),
+				// поэтому сделка новее последнего тикера означает потерянные тикеры
+				if ev, ok := c.sequences.ObserveLatest(streamMsg.ProductID, streamMsg.LastTradeID); ok {
+					connectors.PublishSequenceEvent(pub, ev)
+				}
+			case "ticker":
 				log.Printf("streamMsg: %v", streamMsg)
 
+				if ev, ok := c.sequences.Observe(streamMsg.ProductID, streamMsg.TradeID); ok {
+					connectors.PublishSequenceEvent(pub, ev)
+				}
+
+				if c.subs.Paused() {
+					continue
+				}
+
 				msg, err := json.Marshal(streamMsg)
 				if err != nil {
 					log.Printf("marshal error: %v", err)
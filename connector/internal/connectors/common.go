@@ -2,8 +2,12 @@ package connectors
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"net/http"
 
 	"connector/internal/producer"
+	"connector/internal/status"
 )
 
 type ExchangeConnector interface {
@@ -12,3 +16,32 @@ type ExchangeConnector interface {
 	// FetchHistoricalData(ctx context.Context, symbol string, period string, limit int) ([]HistoricalData, error)
 	// KlinesData(ctx ctx context.Context) error
 }
+
+// Options - общие параметры коннекторов бирж
+type Options struct {
+	Shard      Shard
+	StreamMode string          // режим подписки, если биржа поддерживает несколько
+	Status     status.Reporter // получатель событий жизненного цикла, может быть nil
+}
+
+// Report - публикует событие жизненного цикла, если задан Status
+func (o Options) Report(event, detail string) {
+	if o.Status == nil {
+		log.Printf("status: %s %s", event, detail)
+		return
+	}
+	o.Status.Report(event, detail)
+}
+
+// CheckResponse - проверяет ответ REST API биржи и сообщает об ограничении частоты запросов
+func (o Options) CheckResponse(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusTooManyRequests, http.StatusTeapot: // Binance отвечает 418 после бана по IP
+		o.Report(status.RateLimited, resp.Request.URL.String())
+		return fmt.Errorf("rate limited: %s", resp.Status)
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
@@ -0,0 +1,285 @@
+package deribit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"connector/internal/config"
+	"connector/internal/connectors"
+	"connector/internal/control"
+	"connector/internal/producer"
+	"connector/internal/status"
+	"connector/internal/ws"
+)
+
+// heartbeatInterval - период heartbeat, который сервер Deribit присылает в виде test_request
+const heartbeatInterval = 30
+
+// errTooManyRequests - код ошибки Deribit при превышении лимита запросов
+const errTooManyRequests = 10028
+
+type DeribitConnector struct {
+	cfg          config.DeribitConfig
+	opts         connectors.Options
+	symbolChunks [][]string
+	instruments  []connectors.Instrument
+	subs         connectors.Subscriptions
+}
+
+type instrumentResponse struct {
+	Result []struct {
+		InstrumentName    string  `json:"instrument_name"`
+		IsActive          bool    `json:"is_active"`
+		BaseCurrency      string  `json:"base_currency"`
+		CounterCurrency   string  `json:"counter_currency"`
+		TickSize          float64 `json:"tick_size"`
+		MinTradeAmount    float64 `json:"min_trade_amount"`
+		CreationTimestamp int64   `json:"creation_timestamp"`
+	} `json:"result"`
+	Error *rpcError `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcMessage - ответ на запрос JSON-RPC (id, result, error) или уведомление (method, params)
+type rpcMessage struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+	Method string          `json:"method"`
+	Params struct {
+		Channel string          `json:"channel"`
+		Data    json.RawMessage `json:"data"`
+		Type    string          `json:"type"`
+	} `json:"params"`
+}
+
+// requestID - идентификатор запросов JSON-RPC в сокете
+var requestID atomic.Int64
+
+func NewConnector(cfg config.DeribitConfig, opts connectors.Options) *DeribitConnector {
+	return &DeribitConnector{cfg: cfg, opts: opts}
+}
+
+func (c *DeribitConnector) Connect(ctx context.Context) error {
+	instruments, err := c.fetchInstruments(ctx)
+	if err != nil {
+		return err
+	}
+	c.instruments = instruments
+
+	var all []string
+	for _, inst := range instruments {
+		if inst.Status == "active" {
+			all = append(all, inst.Symbol)
+		}
+	}
+
+	log.Printf("Deribit: found %d active options for shard %d/%d", len(all), c.opts.Shard.Index, c.opts.Shard.Count)
+	c.symbolChunks = chunkStrings(all, 200)
+	return nil
+}
+
+// fetchInstruments - опционы по каждой валюте из public/get_instruments
+func (c *DeribitConnector) fetchInstruments(ctx context.Context) ([]connectors.Instrument, error) {
+	var instruments []connectors.Instrument
+	for _, currency := range c.cfg.Currencies {
+		q := url.Values{}
+		q.Set("currency", strings.TrimSpace(currency))
+		q.Set("kind", "option")
+		q.Set("expired", "false")
+
+		resp, err := http.Get(c.cfg.URL + "/api/v2/public/get_instruments?" + q.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("get instruments: %w", err)
+		}
+
+		if err := c.opts.CheckResponse(resp); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("get instruments: %w", err)
+		}
+
+		var result instrumentResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode instruments: %w", err)
+		}
+		if result.Error != nil {
+			return nil, fmt.Errorf("API error: %s", result.Error.Message)
+		}
+
+		for _, r := range result.Result {
+			inst := connectors.Instrument{
+				Exchange: "deribit",
+				Symbol:   r.InstrumentName,
+				Market:   "options",
+				Base:     r.BaseCurrency,
+				Quote:    r.CounterCurrency,
+				TickSize: strconv.FormatFloat(r.TickSize, 'f', -1, 64),
+				LotSize:  strconv.FormatFloat(r.MinTradeAmount, 'f', -1, 64),
+				Status:   "inactive",
+			}
+			if r.IsActive {
+				inst.Status = "active"
+			}
+			if r.CreationTimestamp > 0 {
+				t := time.UnixMilli(r.CreationTimestamp).UTC()
+				inst.ListingTime = &t
+			}
+			instruments = append(instruments, inst)
+		}
+	}
+
+	return c.opts.OwnedInstruments(instruments), nil
+}
+
+// wsURL - адрес сокета выводится из адреса REST API, чтобы заглушке хватало одного сервера
+func (c *DeribitConnector) wsURL() string {
+	u := strings.Replace(c.cfg.URL, "https://", "wss://", 1)
+	u = strings.Replace(u, "http://", "ws://", 1)
+	return u + "/ws/api/v2"
+}
+
+func (c *DeribitConnector) SubscribeToMarketData(ctx context.Context, pub producer.MessageProducer) error {
+	connectors.PublishInstruments(pub, c.instruments)
+	go connectors.RefreshInstruments(ctx, pub, c.fetchInstruments)
+
+	for _, chunk := range c.symbolChunks {
+		if len(chunk) == 0 {
+			continue
+		}
+
+		conn := ws.NewWSClient(c.wsURL())
+		if err := conn.Connect(); err != nil {
+			c.opts.Report(status.Disconnected, fmt.Sprintf("dial: %v", err))
+			continue
+		}
+		c.opts.Report(status.Connected, fmt.Sprintf("%s, %d options", conn.URL, len(chunk)))
+
+		if err := call(conn, "public/set_heartbeat", map[string]interface{}{"interval": heartbeatInterval}); err != nil {
+			log.Printf("set heartbeat error: %v", err)
+			conn.Close()
+			continue
+		}
+
+		if err := sendSubscription(conn, chunk, true); err != nil {
+			log.Printf("subscribe error: %v", err)
+			conn.Close()
+			continue
+		}
+
+		stream := connectors.NewStream(conn, chunk)
+		c.subs.Add(stream)
+
+		go c.handleConnection(ctx, stream, pub)
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *DeribitConnector) HandleCommand(ctx context.Context, cmd control.Command) ([]string, error) {
+	return c.subs.HandleCommand(cmd, c.opts.Shard, sendSubscription)
+}
+
+func sendSubscription(conn *ws.WSClient, symbols []string, subscribe bool) error {
+	method := "public/unsubscribe"
+	if subscribe {
+		method = "public/subscribe"
+	}
+
+	channels := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		channels[i] = "ticker." + symbol + ".100ms"
+	}
+	return call(conn, method, map[string]interface{}{"channels": channels})
+}
+
+func call(conn *ws.WSClient, method string, params interface{}) error {
+	return conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      requestID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+}
+
+func (c *DeribitConnector) handleConnection(ctx context.Context, stream *connectors.Stream, pub producer.MessageProducer) {
+	defer c.subs.Remove(stream)
+	defer stream.Conn.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			msg, err := stream.Conn.ReadMessage()
+			if err != nil {
+				c.opts.Report(status.Disconnected, fmt.Sprintf("read: %v", err))
+				return
+			}
+
+			var rpc rpcMessage
+			if err := json.Unmarshal(msg, &rpc); err != nil {
+				log.Printf("unmarshal error: %v", err)
+				continue
+			}
+
+			switch rpc.Method {
+			case "subscription":
+				if len(rpc.Params.Data) == 0 || c.subs.Paused() {
+					continue
+				}
+				if err := pub.Publish(rpc.Params.Data); err != nil {
+					log.Printf("publish error: %v", err)
+				}
+			case "heartbeat":
+				// без ответа на test_request сервер закрывает соединение
+				if rpc.Params.Type == "test_request" {
+					if err := call(stream.Conn, "public/test", map[string]interface{}{}); err != nil {
+						log.Printf("heartbeat response error: %v", err)
+					}
+				}
+			case "":
+				c.reportResponse(rpc)
+			}
+		}
+	}
+}
+
+// reportResponse - ответ на subscribe содержит список каналов, ошибки приходят в поле error
+func (c *DeribitConnector) reportResponse(rpc rpcMessage) {
+	if rpc.Error != nil {
+		event := status.SubscribeError
+		if rpc.Error.Code == errTooManyRequests {
+			event = status.RateLimited
+		}
+		c.opts.Report(event, fmt.Sprintf("request %d: %d %s", rpc.ID, rpc.Error.Code, rpc.Error.Message))
+		return
+	}
+
+	var channels []string
+	if err := json.Unmarshal(rpc.Result, &channels); err == nil {
+		c.opts.Report(status.SubscribeAck, fmt.Sprintf("request %d: %d channels", rpc.ID, len(channels)))
+	}
+}
+
+func chunkStrings(list []string, size int) [][]string {
+	var chunks [][]string
+	for size < len(list) {
+		list, chunks = list[size:], append(chunks, list[0:size:size])
+	}
+	return append(chunks, list)
+}
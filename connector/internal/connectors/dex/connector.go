@@ -0,0 +1,133 @@
+package dex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"connector/internal/config"
+	"connector/internal/connectors"
+	"connector/internal/producer"
+	"connector/internal/status"
+)
+
+// Ticker - цена пула в формате тикера универсального коннектора с рынком dex
+type Ticker struct {
+	Exchange  string `json:"exchange"`
+	Symbol    string `json:"symbol"`
+	Market    string `json:"market"`
+	Price     string `json:"price"`
+	Pool      string `json:"pool"`
+	Block     uint64 `json:"block"`
+	Timestamp string `json:"timestamp"`
+}
+
+// DexConnector - опрашивает пулы Uniswap через JSON-RPC на каждом новом блоке
+type DexConnector struct {
+	cfg       config.DexConfig
+	opts      connectors.Options
+	rpc       *rpcClient
+	pools     []Pool
+	lastBlock uint64
+}
+
+func NewConnector(cfg config.DexConfig, opts connectors.Options) *DexConnector {
+	return &DexConnector{cfg: cfg, opts: opts, rpc: newRPCClient(cfg.RPCURL, opts)}
+}
+
+func (c *DexConnector) Connect(ctx context.Context) error {
+	pools, err := LoadPools(c.cfg.PoolsFile)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pools {
+		if c.opts.Shard.Owns(p.Address) {
+			c.pools = append(c.pools, p)
+		}
+	}
+
+	block, err := c.rpc.blockNumber(ctx)
+	if err != nil {
+		c.opts.Report(status.Disconnected, fmt.Sprintf("eth_blockNumber: %v", err))
+		return err
+	}
+	c.opts.Report(status.Connected, fmt.Sprintf("%s, block %d, %d pools", c.cfg.RPCURL, block, len(c.pools)))
+
+	log.Printf("DEX: found %d pools for shard %d/%d", len(c.pools), c.opts.Shard.Index, c.opts.Shard.Count)
+	return nil
+}
+
+func (c *DexConnector) SubscribeToMarketData(ctx context.Context, pub producer.MessageProducer) error {
+	connectors.PublishInstruments(pub, c.instruments())
+
+	ticker := time.NewTicker(c.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		c.poll(ctx, pub)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll - публикует цены пулов, если с прошлого опроса вышел новый блок
+func (c *DexConnector) poll(ctx context.Context, pub producer.MessageProducer) {
+	block, err := c.rpc.blockNumber(ctx)
+	if err != nil {
+		log.Printf("eth_blockNumber: %v", err)
+		return
+	}
+	if block <= c.lastBlock {
+		return
+	}
+	c.lastBlock = block
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, p := range c.pools {
+		price, err := p.price(ctx, c.rpc, block)
+		if err != nil {
+			log.Printf("price %s: %v", p.Symbol(), err)
+			continue
+		}
+
+		msg, err := json.Marshal(Ticker{
+			Exchange:  p.Exchange(),
+			Symbol:    p.Symbol(),
+			Market:    "dex",
+			Price:     formatPrice(price),
+			Pool:      p.Address,
+			Block:     block,
+			Timestamp: now,
+		})
+		if err != nil {
+			log.Printf("marshal ticker %s: %v", p.Symbol(), err)
+			continue
+		}
+		if err := pub.PublishKind(producer.KindTicker, msg); err != nil {
+			log.Printf("publish error: %v", err)
+		}
+	}
+}
+
+// instruments - пулы из файла; шаг цены и лот у пулов отсутствуют
+func (c *DexConnector) instruments() []connectors.Instrument {
+	instruments := make([]connectors.Instrument, len(c.pools))
+	for i, p := range c.pools {
+		instruments[i] = connectors.Instrument{
+			Exchange: p.Exchange(),
+			Symbol:   p.Symbol(),
+			Market:   "dex",
+			Base:     p.Base(),
+			Quote:    p.Quote(),
+			Status:   "active",
+		}
+	}
+	return instruments
+}
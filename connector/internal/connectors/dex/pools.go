@@ -0,0 +1,144 @@
+package dex
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Селекторы view-функций пулов Uniswap
+const (
+	selectorGetReserves = "0x0902f1ac" // getReserves() - v2
+	selectorSlot0       = "0x3850c7bd" // slot0() - v3
+)
+
+type Token struct {
+	Symbol   string `yaml:"symbol"`
+	Decimals int    `yaml:"decimals"`
+}
+
+// Pool - пул из файла DEX_POOLS. Цена считается как token1 за token0,
+// invert разворачивает ее в token0 за token1.
+type Pool struct {
+	Name    string `yaml:"name"`
+	Address string `yaml:"address"`
+	Version string `yaml:"version"` // v2 или v3
+	Token0  Token  `yaml:"token0"`
+	Token1  Token  `yaml:"token1"`
+	Invert  bool   `yaml:"invert"`
+}
+
+func LoadPools(path string) ([]Pool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pools: %w", err)
+	}
+
+	var file struct {
+		Pools []Pool `yaml:"pools"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("parse pools %s: %w", path, err)
+	}
+
+	for i, p := range file.Pools {
+		if p.Address == "" || p.Token0.Symbol == "" || p.Token1.Symbol == "" {
+			return nil, fmt.Errorf("pool %d: address and token symbols are required", i)
+		}
+		if p.Version != "v2" && p.Version != "v3" {
+			return nil, fmt.Errorf("pool %s: unsupported version %q", p.Address, p.Version)
+		}
+		file.Pools[i].Address = strings.ToLower(p.Address)
+	}
+	return file.Pools, nil
+}
+
+// Exchange - протокол пула, используется как биржа тикера
+func (p Pool) Exchange() string {
+	return "uniswap_" + p.Version
+}
+
+func (p Pool) Base() string {
+	if p.Invert {
+		return p.Token1.Symbol
+	}
+	return p.Token0.Symbol
+}
+
+func (p Pool) Quote() string {
+	if p.Invert {
+		return p.Token0.Symbol
+	}
+	return p.Token1.Symbol
+}
+
+// Symbol - имя пула из файла или пара base-quote
+func (p Pool) Symbol() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Base() + "-" + p.Quote()
+}
+
+var q96 = new(big.Int).Lsh(big.NewInt(1), 96)
+
+// price - цена пула на блоке с учетом decimals токенов
+func (p Pool) price(ctx context.Context, rpc *rpcClient, block uint64) (*big.Rat, error) {
+	var raw *big.Rat
+	switch p.Version {
+	case "v2":
+		words, err := rpc.ethCall(ctx, p.Address, selectorGetReserves, block)
+		if err != nil {
+			return nil, err
+		}
+		if len(words) < 2 || words[0].Sign() == 0 {
+			return nil, fmt.Errorf("pool %s: empty reserves", p.Address)
+		}
+		// reserve1 / reserve0
+		raw = new(big.Rat).SetFrac(words[1], words[0])
+	case "v3":
+		words, err := rpc.ethCall(ctx, p.Address, selectorSlot0, block)
+		if err != nil {
+			return nil, err
+		}
+		if len(words) < 1 || words[0].Sign() == 0 {
+			return nil, fmt.Errorf("pool %s: empty slot0", p.Address)
+		}
+		// (sqrtPriceX96 / 2^96)^2
+		sqrt := new(big.Rat).SetFrac(words[0], q96)
+		raw = new(big.Rat).Mul(sqrt, sqrt)
+	}
+
+	// сырые единицы токенов переводятся в целые: price * 10^(decimals0 - decimals1)
+	price := raw.Mul(raw, pow10(p.Token0.Decimals-p.Token1.Decimals))
+	if p.Invert {
+		price = price.Inv(price)
+	}
+	return price, nil
+}
+
+func pow10(exp int) *big.Rat {
+	n := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil)
+	if exp < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), n)
+	}
+	return new(big.Rat).SetInt(n)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// formatPrice - десятичная запись без экспоненты и лишних нулей
+func formatPrice(r *big.Rat) string {
+	s := r.FloatString(18)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
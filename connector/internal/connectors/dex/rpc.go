@@ -0,0 +1,110 @@
+package dex
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"connector/internal/connectors"
+)
+
+// rpcClient - минимальный клиент Ethereum JSON-RPC: eth_blockNumber и eth_call
+type rpcClient struct {
+	url    string
+	http   *http.Client
+	opts   connectors.Options
+	nextID atomic.Int64
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int64         `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newRPCClient(url string, opts connectors.Options) *rpcClient {
+	return &rpcClient{url: url, http: &http.Client{Timeout: 10 * time.Second}, opts: opts}
+}
+
+func (c *rpcClient) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: c.nextID.Add(1), Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if err := c.opts.CheckResponse(resp); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	var rpc rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpc); err != nil {
+		return fmt.Errorf("%s: decode response: %w", method, err)
+	}
+	if rpc.Error != nil {
+		return fmt.Errorf("%s: rpc error %d: %s", method, rpc.Error.Code, rpc.Error.Message)
+	}
+	return json.Unmarshal(rpc.Result, result)
+}
+
+func (c *rpcClient) blockNumber(ctx context.Context) (uint64, error) {
+	var hexBlock string
+	if err := c.call(ctx, "eth_blockNumber", []interface{}{}, &hexBlock); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(hexBlock, "0x"), 16, 64)
+}
+
+// ethCall - вызывает view-функцию контракта без аргументов и возвращает 32-байтовые слова ответа
+func (c *rpcClient) ethCall(ctx context.Context, to, selector string, block uint64) ([]*big.Int, error) {
+	var out string
+	params := []interface{}{
+		map[string]string{"to": to, "data": selector},
+		"0x" + strconv.FormatUint(block, 16),
+	}
+	if err := c.call(ctx, "eth_call", params, &out); err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(strings.TrimPrefix(out, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("eth_call %s: %w", to, err)
+	}
+	if len(data) == 0 || len(data)%32 != 0 {
+		return nil, fmt.Errorf("eth_call %s: unexpected result length %d", to, len(data))
+	}
+
+	words := make([]*big.Int, len(data)/32)
+	for i := range words {
+		words[i] = new(big.Int).SetBytes(data[i*32 : (i+1)*32])
+	}
+	return words, nil
+}
@@ -0,0 +1,92 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"connector/internal/config"
+	"connector/internal/producer"
+)
+
+type FXConnector struct {
+	cfg      config.FXConfig
+	provider provider
+	pending  []Rate // курсы, полученные при подключении
+}
+
+type RateMessage struct {
+	Market string `json:"market"`
+	Base   string `json:"base"`
+	Quote  string `json:"quote"`
+	Rate   string `json:"rate"`
+	Source string `json:"source"`
+	Time   string `json:"time"`
+}
+
+func NewConnector(cfg config.FXConfig) *FXConnector {
+	return &FXConnector{cfg: cfg}
+}
+
+func (c *FXConnector) Connect(ctx context.Context) error {
+	p, err := newProvider(c.cfg)
+	if err != nil {
+		return err
+	}
+	c.provider = p
+
+	rates, err := p.Fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("fetch %s rates: %w", p.Name(), err)
+	}
+
+	log.Printf("FX: provider %s returned %d rates", p.Name(), len(rates))
+	c.pending = rates
+	return nil
+}
+
+func (c *FXConnector) SubscribeToMarketData(ctx context.Context, pub producer.MessageProducer) error {
+	publishRates(c.provider.Name(), c.pending, pub)
+	c.pending = nil
+
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			rates, err := c.provider.Fetch(ctx)
+			if err != nil {
+				log.Printf("fetch %s rates: %v", c.provider.Name(), err)
+				continue
+			}
+			publishRates(c.provider.Name(), rates, pub)
+		}
+	}
+}
+
+func publishRates(source string, rates []Rate, pub producer.MessageProducer) {
+	for _, r := range rates {
+		msg, err := json.Marshal(RateMessage{
+			Market: "fx",
+			Base:   r.Base,
+			Quote:  r.Quote,
+			Rate:   r.Value,
+			Source: source,
+			Time:   r.Time.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			log.Printf("marshal error for %s/%s: %v", r.Base, r.Quote, err)
+			continue
+		}
+
+		if err := pub.Publish(msg); err != nil {
+			log.Printf("publish error for %s/%s: %v", r.Base, r.Quote, err)
+		}
+	}
+	log.Printf("FX: published %d %s rates", len(rates), source)
+}
@@ -0,0 +1,271 @@
+package fx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"connector/internal/config"
+)
+
+const (
+	ecbDailyURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	cbrDailyURL = "https://www.cbr.ru/scripts/XML_daily.asp"
+)
+
+// Rate - курс: 1 Base = Value Quote
+type Rate struct {
+	Base  string
+	Quote string
+	Value string
+	Time  time.Time
+}
+
+type provider interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Rate, error)
+}
+
+func newProvider(cfg config.FXConfig) (provider, error) {
+	switch cfg.Provider {
+	case "ecb", "":
+		return &ecbProvider{url: orDefault(cfg.URL, ecbDailyURL)}, nil
+	case "cbr":
+		return &cbrProvider{url: orDefault(cfg.URL, cbrDailyURL)}, nil
+	case "json":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("FX_URL is required for json provider")
+		}
+		return &jsonProvider{url: cfg.URL, base: cfg.Base}, nil
+	default:
+		return nil, fmt.Errorf("unsupported fx provider: %s", cfg.Provider)
+	}
+}
+
+// ecbProvider - опорные курсы ЕЦБ, база EUR
+type ecbProvider struct {
+	url string
+}
+
+type ecbEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+func (p *ecbProvider) Name() string { return "ecb" }
+
+func (p *ecbProvider) Fetch(ctx context.Context) ([]Rate, error) {
+	body, err := fetch(ctx, p.url)
+	if err != nil {
+		return nil, err
+	}
+
+	var env ecbEnvelope
+	if err := xml.Unmarshal(body, &env); err != nil {
+		return nil, fmt.Errorf("decode ecb xml: %w", err)
+	}
+
+	var rates []Rate
+	for _, day := range env.Cube.Days {
+		ts, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return nil, fmt.Errorf("parse ecb date %q: %w", day.Time, err)
+		}
+		for _, r := range day.Rates {
+			rates = append(rates, Rate{Base: "EUR", Quote: r.Currency, Value: r.Rate, Time: ts})
+		}
+	}
+	return rates, nil
+}
+
+// cbrProvider - официальные курсы ЦБ РФ, котировка в RUB
+type cbrProvider struct {
+	url string
+}
+
+type cbrValCurs struct {
+	Date    string `xml:"Date,attr"`
+	Valutes []struct {
+		CharCode  string `xml:"CharCode"`
+		Nominal   string `xml:"Nominal"`
+		Value     string `xml:"Value"`
+		VunitRate string `xml:"VunitRate"`
+	} `xml:"Valute"`
+}
+
+func (p *cbrProvider) Name() string { return "cbr" }
+
+func (p *cbrProvider) Fetch(ctx context.Context) ([]Rate, error) {
+	body, err := fetch(ctx, p.url)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charsetReader
+
+	var curs cbrValCurs
+	if err := dec.Decode(&curs); err != nil {
+		return nil, fmt.Errorf("decode cbr xml: %w", err)
+	}
+
+	ts, err := time.Parse("02.01.2006", curs.Date)
+	if err != nil {
+		return nil, fmt.Errorf("parse cbr date %q: %w", curs.Date, err)
+	}
+
+	var rates []Rate
+	for _, v := range curs.Valutes {
+		value, err := cbrUnitRate(v.Value, v.Nominal, v.VunitRate)
+		if err != nil {
+			return nil, fmt.Errorf("parse cbr rate for %s: %w", v.CharCode, err)
+		}
+		rates = append(rates, Rate{Base: v.CharCode, Quote: "RUB", Value: value, Time: ts})
+	}
+	return rates, nil
+}
+
+// cbrUnitRate - курс за одну единицу валюты; ЦБ публикует курс за Nominal единиц
+// и использует запятую в качестве десятичного разделителя
+func cbrUnitRate(value, nominal, unitRate string) (string, error) {
+	if unitRate != "" {
+		return strings.ReplaceAll(unitRate, ",", "."), nil
+	}
+
+	value = strings.ReplaceAll(value, ",", ".")
+	n, err := strconv.Atoi(nominal)
+	if err != nil || n <= 0 {
+		return "", fmt.Errorf("invalid nominal %q", nominal)
+	}
+	if n == 1 {
+		return value, nil
+	}
+
+	// делится без float64: колонка курса NUMERIC и хранит значение точно.
+	// Nominal - степень десяти, поэтому знаков после запятой прибавляется не больше, чем цифр в нем
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return "", fmt.Errorf("invalid rate %q", value)
+	}
+	r.Quo(r, new(big.Rat).SetInt64(int64(n)))
+	_, fraction, _ := strings.Cut(value, ".")
+	s := strings.TrimRight(r.FloatString(len(fraction)+len(nominal)), "0")
+	return strings.TrimSuffix(s, "."), nil
+}
+
+// jsonProvider - произвольный JSON-эндпоинт вида {"base": "USD", "date": "2006-01-02", "rates": {"EUR": 0.92}}
+type jsonProvider struct {
+	url  string
+	base string
+}
+
+type jsonRates struct {
+	Base  string                 `json:"base"`
+	Date  string                 `json:"date"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+func (p *jsonProvider) Name() string { return "json" }
+
+func (p *jsonProvider) Fetch(ctx context.Context) ([]Rate, error) {
+	body, err := fetch(ctx, p.url)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var result jsonRates
+	if err := dec.Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode json rates: %w", err)
+	}
+
+	base := orDefault(result.Base, p.base)
+	if base == "" {
+		return nil, fmt.Errorf("base currency is not set: configure FX_BASE")
+	}
+
+	ts := time.Now().UTC()
+	if d, err := time.Parse("2006-01-02", result.Date); err == nil {
+		ts = d
+	}
+
+	var rates []Rate
+	for quote, value := range result.Rates {
+		if quote == base {
+			continue
+		}
+		rates = append(rates, Rate{Base: base, Quote: quote, Value: value.String(), Time: ts})
+	}
+	return rates, nil
+}
+
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: unexpected status %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// charsetReader - ЦБ отдает XML в windows-1251, encoding/xml понимает только UTF-8
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	if !strings.EqualFold(label, "windows-1251") {
+		return nil, fmt.Errorf("unsupported charset: %s", label)
+	}
+
+	raw, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	for _, b := range raw {
+		switch {
+		case b < 0x80:
+			sb.WriteByte(b)
+		case b >= 0xC0:
+			sb.WriteRune(rune(0x410 + int(b-0xC0))) // А..я
+		case b == 0xA8:
+			sb.WriteRune('Ё')
+		case b == 0xB8:
+			sb.WriteRune('ё')
+		default:
+			sb.WriteRune('?')
+		}
+	}
+	return strings.NewReader(sb.String()), nil
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,250 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"connector/internal/connectors"
+	"connector/internal/control"
+	"connector/internal/producer"
+	"connector/internal/status"
+	"connector/internal/ws"
+
+	"github.com/gorilla/websocket"
+)
+
+// Ticker - нормализованный тикер, который публикует универсальный коннектор
+type Ticker struct {
+	Exchange      string `json:"exchange"`
+	Symbol        string `json:"symbol"`
+	Price         string `json:"price"`
+	Volume        string `json:"volume,omitempty"`
+	High          string `json:"high,omitempty"`
+	Low           string `json:"low,omitempty"`
+	ChangePercent string `json:"change_percent,omitempty"`
+	Timestamp     string `json:"timestamp,omitempty"`
+}
+
+// GenericConnector - коннектор WebSocket-биржи, описанной спецификацией вместо отдельного пакета
+type GenericConnector struct {
+	spec         *compiledSpec
+	opts         connectors.Options
+	symbolChunks [][]string
+	subs         connectors.Subscriptions
+}
+
+func NewConnector(spec *Spec, opts connectors.Options) (*GenericConnector, error) {
+	compiled, err := compile(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &GenericConnector{spec: compiled, opts: opts}, nil
+}
+
+func (c *GenericConnector) Connect(ctx context.Context) error {
+	resp, err := http.Get(c.spec.Discovery.URL)
+	if err != nil {
+		return fmt.Errorf("get symbols: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := c.opts.CheckResponse(resp); err != nil {
+		return fmt.Errorf("get symbols: %w", err)
+	}
+
+	body, err := decode(resp.Body)
+	if err != nil {
+		return fmt.Errorf("decode symbols: %w", err)
+	}
+
+	all := c.opts.Shard.Filter(c.spec.symbols.Strings(body))
+
+	log.Printf("%s: found %d symbols for shard %d/%d", c.spec.Name, len(all), c.opts.Shard.Index, c.opts.Shard.Count)
+	c.symbolChunks = chunkStrings(all, c.spec.ChunkSize)
+	return nil
+}
+
+func (c *GenericConnector) SubscribeToMarketData(ctx context.Context, pub producer.MessageProducer) error {
+	for _, chunk := range c.symbolChunks {
+		if len(chunk) == 0 {
+			continue
+		}
+
+		conn := ws.NewWSClient(c.spec.URL)
+		if err := conn.Connect(); err != nil {
+			c.opts.Report(status.Disconnected, fmt.Sprintf("dial: %v", err))
+			continue
+		}
+		c.opts.Report(status.Connected, fmt.Sprintf("%s, %d symbols", conn.URL, len(chunk)))
+
+		if err := c.sendSubscription(conn, chunk, true); err != nil {
+			c.opts.Report(status.SubscribeError, err.Error())
+			conn.Close()
+			continue
+		}
+
+		stream := connectors.NewStream(conn, chunk)
+		c.subs.Add(stream)
+
+		if c.spec.ping != nil && c.spec.Ping.Interval > 0 {
+			go c.pingLoop(ctx, conn)
+		}
+
+		go c.handleConnection(ctx, stream, pub)
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *GenericConnector) HandleCommand(ctx context.Context, cmd control.Command) ([]string, error) {
+	return c.subs.HandleCommand(cmd, c.opts.Shard, c.sendSubscription)
+}
+
+func (c *GenericConnector) sendSubscription(conn *ws.WSClient, symbols []string, subscribe bool) error {
+	t := c.spec.subscribe
+	if !subscribe {
+		t = c.spec.unsubscribe
+	}
+	if t == nil {
+		return fmt.Errorf("spec %s has no unsubscribe template", c.spec.Name)
+	}
+
+	// чанк из спецификации ограничивает и число символов в одном сообщении
+	for _, chunk := range chunkStrings(symbols, c.spec.ChunkSize) {
+		msg, err := render(t, chunk)
+		if err != nil {
+			return fmt.Errorf("render %s: %w", t.Name(), err)
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *GenericConnector) pingLoop(ctx context.Context, conn *ws.WSClient) {
+	ticker := time.NewTicker(c.spec.Ping.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			msg, err := render(c.spec.ping, nil)
+			if err != nil {
+				log.Printf("render ping: %v", err)
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				log.Printf("ping error: %v", err)
+				return
+			}
+		}
+	}
+}
+
+func (c *GenericConnector) handleConnection(ctx context.Context, stream *connectors.Stream, pub producer.MessageProducer) {
+	defer c.subs.Remove(stream)
+	defer stream.Conn.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			msg, err := stream.Conn.ReadMessage()
+			if err != nil {
+				c.opts.Report(status.Disconnected, fmt.Sprintf("read: %v", err))
+				return
+			}
+
+			root, err := decode(bytes.NewReader(msg))
+			if err != nil {
+				log.Printf("unmarshal error: %v", err)
+				continue
+			}
+
+			if c.subs.Paused() {
+				continue
+			}
+
+			for _, t := range c.extract(root) {
+				data, err := json.Marshal(t)
+				if err != nil {
+					log.Printf("marshal ticker: %v", err)
+					continue
+				}
+				if err := pub.PublishKind(producer.KindTicker, data); err != nil {
+					log.Printf("publish error: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// extract - тикеры из сообщения; сообщения без символа и цены (ответы на подписку, pong) пропускаются
+func (c *GenericConnector) extract(root interface{}) []Ticker {
+	items := []interface{}{root}
+	if c.spec.items != nil {
+		items = c.spec.items.Eval(root)
+	}
+
+	var tickers []Ticker
+	for _, item := range items {
+		field := func(name string) string {
+			p, ok := c.spec.fields[name]
+			if !ok {
+				return ""
+			}
+			src := item
+			if p.root {
+				src = root
+			}
+			v, _ := p.Scalar(src)
+			return v
+		}
+
+		t := Ticker{
+			Exchange:      c.spec.Name,
+			Symbol:        field("symbol"),
+			Price:         field("price"),
+			Volume:        field("volume"),
+			High:          field("high"),
+			Low:           field("low"),
+			ChangePercent: field("change_percent"),
+			Timestamp:     field("timestamp"),
+		}
+		if t.Symbol == "" || t.Price == "" {
+			continue
+		}
+		tickers = append(tickers, t)
+	}
+	return tickers
+}
+
+// decode - числа сохраняются как json.Number, чтобы не терять точность цен
+func decode(r io.Reader) (interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func chunkStrings(list []string, size int) [][]string {
+	var chunks [][]string
+	for size < len(list) {
+		list, chunks = list[size:], append(chunks, list[0:size:size])
+	}
+	return append(chunks, list)
+}
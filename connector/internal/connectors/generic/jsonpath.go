@@ -0,0 +1,245 @@
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path - подмножество JSONPath, достаточное для разбора ответов бирж:
+//
+//	$.a.b        поле объекта
+//	$.a[0]       элемент массива
+//	$.a[*], $.*  все элементы массива или значения объекта
+//	$['a-b']     поле с произвольным именем
+//	$.a[?(@.status=='online')]  элементы, поле которых равно значению (также !=)
+//
+// Путь без "$" считается относительным: в спецификации поля данных
+// вычисляются относительно элемента, а пути с "$" - от корня сообщения.
+type Path struct {
+	expr     string
+	root     bool
+	segments []segment
+}
+
+type segmentKind int
+
+const (
+	segKey segmentKind = iota
+	segIndex
+	segWildcard
+	segFilter
+)
+
+type segment struct {
+	kind   segmentKind
+	key    string
+	index  int
+	field  *Path // поле элемента в фильтре
+	value  string
+	negate bool
+}
+
+func ParsePath(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+	rest := strings.TrimSpace(expr)
+	if strings.HasPrefix(rest, "$") {
+		p.root = true
+		rest = rest[1:]
+	} else if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("jsonpath %q: empty field name", expr)
+			case "*":
+				p.segments = append(p.segments, segment{kind: segWildcard})
+			default:
+				p.segments = append(p.segments, segment{kind: segKey, key: name})
+			}
+		case '[':
+			seg, n, err := parseBracket(rest)
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: %w", expr, err)
+			}
+			p.segments = append(p.segments, seg)
+			rest = rest[n:]
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return p, nil
+}
+
+// parseBracket - разбирает сегмент в квадратных скобках, возвращает его длину
+func parseBracket(s string) (segment, int, error) {
+	if strings.HasPrefix(s, "[?(") {
+		end := strings.Index(s, ")]")
+		if end < 0 {
+			return segment{}, 0, fmt.Errorf("unterminated filter")
+		}
+		seg, err := parseFilter(s[3:end])
+		return seg, end + 2, err
+	}
+
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return segment{}, 0, fmt.Errorf("unterminated bracket")
+	}
+	inner := strings.TrimSpace(s[1:end])
+	switch {
+	case inner == "*":
+		return segment{kind: segWildcard}, end + 1, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return segment{kind: segKey, key: inner[1 : len(inner)-1]}, end + 1, nil
+	default:
+		idx, err := strconv.Atoi(inner)
+		if err != nil {
+			return segment{}, 0, fmt.Errorf("invalid index %q", inner)
+		}
+		return segment{kind: segIndex, index: idx}, end + 1, nil
+	}
+}
+
+// parseFilter - выражение вида @.field=='value' или @.field!=value
+func parseFilter(expr string) (segment, error) {
+	op, negate := "==", false
+	i := strings.Index(expr, "==")
+	if j := strings.Index(expr, "!="); j >= 0 {
+		op, negate, i = "!=", true, j
+	}
+	if i < 0 {
+		return segment{}, fmt.Errorf("unsupported filter %q", expr)
+	}
+
+	left := strings.TrimSpace(expr[:i])
+	right := strings.TrimSpace(expr[i+len(op):])
+	if !strings.HasPrefix(left, "@") {
+		return segment{}, fmt.Errorf("filter must start with @: %q", expr)
+	}
+	field, err := ParsePath(left[1:])
+	if err != nil {
+		return segment{}, err
+	}
+	if len(right) >= 2 && (right[0] == '\'' || right[0] == '"') && right[len(right)-1] == right[0] {
+		right = right[1 : len(right)-1]
+	}
+	return segment{kind: segFilter, field: field, value: right, negate: negate}, nil
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Eval - все значения, найденные по пути
+func (p *Path) Eval(v interface{}) []interface{} {
+	current := []interface{}{v}
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, node := range current {
+			next = append(next, seg.apply(node)...)
+		}
+		current = next
+	}
+	return current
+}
+
+// Scalar - первое найденное значение в строковом виде
+func (p *Path) Scalar(v interface{}) (string, bool) {
+	for _, node := range p.Eval(v) {
+		if s, ok := scalar(node); ok {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// Strings - все найденные скалярные значения в строковом виде
+func (p *Path) Strings(v interface{}) []string {
+	var out []string
+	for _, node := range p.Eval(v) {
+		if s, ok := scalar(node); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (s segment) apply(node interface{}) []interface{} {
+	switch s.kind {
+	case segKey:
+		if obj, ok := node.(map[string]interface{}); ok {
+			if v, ok := obj[s.key]; ok {
+				return []interface{}{v}
+			}
+		}
+	case segIndex:
+		if arr, ok := node.([]interface{}); ok {
+			idx := s.index
+			if idx < 0 {
+				idx += len(arr)
+			}
+			if idx >= 0 && idx < len(arr) {
+				return []interface{}{arr[idx]}
+			}
+		}
+	case segWildcard:
+		return children(node)
+	case segFilter:
+		var out []interface{}
+		for _, child := range children(node) {
+			value, ok := s.field.Scalar(child)
+			if (ok && value == s.value) != s.negate {
+				out = append(out, child)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func children(node interface{}) []interface{} {
+	switch v := node.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		// порядок ключей фиксируется, чтобы разбиение символов на чанки было стабильным
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, 0, len(v))
+		for _, key := range keys {
+			out = append(out, v[key])
+		}
+		return out
+	}
+	return nil
+}
+
+// scalar - строки и числа отдаются как есть; числа должны быть декодированы с UseNumber
+func scalar(v interface{}) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case json.Number:
+		return x.String(), true
+	case bool:
+		return strconv.FormatBool(x), true
+	}
+	return "", false
+}
@@ -0,0 +1,160 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Spec - описание биржи для универсального коннектора. Пример - specs/gateio.yaml.
+type Spec struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+
+	Discovery struct {
+		URL     string `yaml:"url"`
+		Symbols string `yaml:"symbols"` // JSONPath к символам в ответе
+	} `yaml:"discovery"`
+
+	// Subscribe и Unsubscribe - шаблоны text/template, в шаблон передается .Symbols
+	Subscribe   string `yaml:"subscribe"`
+	Unsubscribe string `yaml:"unsubscribe"`
+	ChunkSize   int    `yaml:"chunk_size"`
+
+	Ping struct {
+		Payload  string        `yaml:"payload"` // шаблон text/template
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"ping"`
+
+	// Data - пути к полям тикера. Items указывает на элементы с данными внутри
+	// сообщения; остальные пути вычисляются относительно элемента, если не начинаются с "$".
+	Data struct {
+		Items         string `yaml:"items"`
+		Symbol        string `yaml:"symbol"`
+		Price         string `yaml:"price"`
+		Volume        string `yaml:"volume"`
+		High          string `yaml:"high"`
+		Low           string `yaml:"low"`
+		ChangePercent string `yaml:"change_percent"`
+		Timestamp     string `yaml:"timestamp"`
+	} `yaml:"data"`
+}
+
+// compiledSpec - спецификация с разобранными путями и шаблонами
+type compiledSpec struct {
+	Spec
+
+	symbols     *Path
+	subscribe   *template.Template
+	unsubscribe *template.Template
+	ping        *template.Template
+	items       *Path
+	fields      map[string]*Path
+}
+
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read spec: %w", err)
+	}
+
+	var spec Spec
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("parse spec %s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"unix":  func() int64 { return time.Now().Unix() },
+	"unixMilli": func() int64 {
+		return time.Now().UnixMilli()
+	},
+}
+
+func compile(spec *Spec) (*compiledSpec, error) {
+	switch {
+	case spec.Name == "":
+		return nil, fmt.Errorf("spec: name is required")
+	case spec.URL == "":
+		return nil, fmt.Errorf("spec %s: url is required", spec.Name)
+	case spec.Discovery.URL == "" || spec.Discovery.Symbols == "":
+		return nil, fmt.Errorf("spec %s: discovery url and symbols are required", spec.Name)
+	case spec.Subscribe == "":
+		return nil, fmt.Errorf("spec %s: subscribe template is required", spec.Name)
+	case spec.Data.Symbol == "" || spec.Data.Price == "":
+		return nil, fmt.Errorf("spec %s: data symbol and price paths are required", spec.Name)
+	}
+
+	c := &compiledSpec{Spec: *spec, fields: make(map[string]*Path)}
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = 10
+	}
+
+	var err error
+	if c.symbols, err = ParsePath(spec.Discovery.Symbols); err != nil {
+		return nil, err
+	}
+	if spec.Data.Items != "" {
+		if c.items, err = ParsePath(spec.Data.Items); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, expr := range map[string]string{
+		"symbol":         spec.Data.Symbol,
+		"price":          spec.Data.Price,
+		"volume":         spec.Data.Volume,
+		"high":           spec.Data.High,
+		"low":            spec.Data.Low,
+		"change_percent": spec.Data.ChangePercent,
+		"timestamp":      spec.Data.Timestamp,
+	} {
+		if expr == "" {
+			continue
+		}
+		if c.fields[name], err = ParsePath(expr); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range []struct {
+		dst  **template.Template
+		name string
+		text string
+	}{
+		{&c.subscribe, "subscribe", spec.Subscribe},
+		{&c.unsubscribe, "unsubscribe", spec.Unsubscribe},
+		{&c.ping, "ping", spec.Ping.Payload},
+	} {
+		if t.text == "" {
+			continue
+		}
+		if *t.dst, err = template.New(t.name).Funcs(templateFuncs).Parse(t.text); err != nil {
+			return nil, fmt.Errorf("spec %s: %s template: %w", spec.Name, t.name, err)
+		}
+	}
+
+	return c, nil
+}
+
+func render(t *template.Template, symbols []string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, struct{ Symbols []string }{symbols}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
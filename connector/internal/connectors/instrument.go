@@ -0,0 +1,77 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"connector/internal/producer"
+)
+
+// InstrumentRefreshInterval - как часто коннекторы перечитывают справочник инструментов биржи
+const InstrumentRefreshInterval = time.Hour
+
+// Instrument - метаданные инструмента из справочника биржи. Числовые поля
+// передаются строками в том виде, в каком их отдает биржа; пустое значение
+// означает, что биржа его не публикует.
+type Instrument struct {
+	Exchange    string     `json:"exchange"`
+	Symbol      string     `json:"symbol"`
+	Market      string     `json:"market,omitempty"` // пусто для спотовых инструментов
+	Base        string     `json:"base"`
+	Quote       string     `json:"quote"`
+	TickSize    string     `json:"tick_size,omitempty"`
+	LotSize     string     `json:"lot_size,omitempty"`
+	MinNotional string     `json:"min_notional,omitempty"`
+	Status      string     `json:"status"`
+	ListingTime *time.Time `json:"listing_time,omitempty"`
+}
+
+// InstrumentFetcher - загружает справочник инструментов биржи, принадлежащих шарду
+type InstrumentFetcher func(ctx context.Context) ([]Instrument, error)
+
+// PublishInstruments - публикует метаданные инструментов служебными сообщениями
+func PublishInstruments(pub producer.MessageProducer, instruments []Instrument) {
+	for _, inst := range instruments {
+		msg, err := json.Marshal(inst)
+		if err != nil {
+			log.Printf("marshal instrument %s: %v", inst.Symbol, err)
+			continue
+		}
+		if err := pub.PublishKind(producer.KindInstrument, msg); err != nil {
+			log.Printf("publish instrument %s: %v", inst.Symbol, err)
+		}
+	}
+}
+
+// RefreshInstruments - периодически перечитывает справочник и публикует его заново
+func RefreshInstruments(ctx context.Context, pub producer.MessageProducer, fetch InstrumentFetcher) {
+	ticker := time.NewTicker(InstrumentRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			instruments, err := fetch(ctx)
+			if err != nil {
+				log.Printf("refresh instruments: %v", err)
+				continue
+			}
+			PublishInstruments(pub, instruments)
+		}
+	}
+}
+
+// OwnedInstruments - инструменты, символы которых принадлежат шарду
+func (o Options) OwnedInstruments(instruments []Instrument) []Instrument {
+	var owned []Instrument
+	for _, inst := range instruments {
+		if o.Shard.Owns(inst.Symbol) {
+			owned = append(owned, inst)
+		}
+	}
+	return owned
+}
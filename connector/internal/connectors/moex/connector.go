@@ -8,10 +8,12 @@ import (
 	"net/http"
 	"time"
 
+	"connector/internal/connectors"
 	"connector/internal/producer"
 )
 
 type MOEXConnector struct {
+	opts         connectors.Options
 	symbolChunks [][]string
 }
 
@@ -38,8 +40,8 @@ type StreamResponse struct {
 	Time      string  `json:"time"`
 }
 
-func NewConnector() *MOEXConnector {
-	return &MOEXConnector{}
+func NewConnector(opts connectors.Options) *MOEXConnector {
+	return &MOEXConnector{opts: opts}
 }
 
 func (c *MOEXConnector) Connect(ctx context.Context) error {
@@ -63,7 +65,9 @@ func (c *MOEXConnector) Connect(ctx context.Context) error {
 		}
 	}
 
-	log.Printf("MOEX: found %d active securities", len(all))
+	all = c.opts.Shard.Filter(all)
+
+	log.Printf("MOEX: found %d active securities for shard %d/%d", len(all), c.opts.Shard.Index, c.opts.Shard.Count)
 	c.symbolChunks = chunkStrings(all, 10)
 	return nil
 }
@@ -6,98 +6,155 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"connector/internal/connectors"
+	"connector/internal/control"
 	"connector/internal/producer"
-
-	"github.com/gorilla/websocket"
+	"connector/internal/status"
+	"connector/internal/ws"
 )
 
 type OKXConnector struct {
+	opts         connectors.Options
 	symbolChunks [][]string
+	instruments  []connectors.Instrument
+	sequences    *connectors.SequenceTracker
+	subs         connectors.Subscriptions
 }
 
 type instrumentResponse struct {
 	Code string `json:"code"`
 	Msg  string `json:"msg"`
 	Data []struct {
-		InstID string `json:"instId"`
-		State  string `json:"state"`
+		InstID   string `json:"instId"`
+		State    string `json:"state"`
+		BaseCcy  string `json:"baseCcy"`
+		QuoteCcy string `json:"quoteCcy"`
+		TickSz   string `json:"tickSz"`
+		LotSz    string `json:"lotSz"`
+		ListTime string `json:"listTime"`
 	} `json:"data"`
 }
 
 type StreamResponse struct {
-	Arg struct {
+	Event string `json:"event"`
+	Code  string `json:"code"`
+	Msg   string `json:"msg"`
+	Arg   struct {
 		Channel string `json:"channel"`
 		InstID  string `json:"instId"`
 	} `json:"arg"`
 	Data []json.RawMessage `json:"data"`
 }
 
-func NewConnector() *OKXConnector {
-	return &OKXConnector{}
+// errTooFrequent - код ошибки OKX при превышении частоты запросов
+const errTooFrequent = "60014"
+
+// tradeData - сделка канала trades; сделки агрегируются: tradeId последней сделки и их число
+type tradeData struct {
+	InstID  string `json:"instId"`
+	TradeID string `json:"tradeId"`
+	Count   string `json:"count"`
+}
+
+func NewConnector(opts connectors.Options) *OKXConnector {
+	return &OKXConnector{
+		opts: opts,
+		// отслеживаются ID сделок: у тикеров нет непрерывного счетчика, а tradeId растет на единицу
+		sequences: connectors.NewSequenceTracker("okx"),
+	}
 }
 
 func (c *OKXConnector) Connect(ctx context.Context) error {
+	instruments, err := c.fetchInstruments(ctx)
+	if err != nil {
+		return err
+	}
+	c.instruments = instruments
+
+	var all []string
+	for _, inst := range instruments {
+		if inst.Status == "live" {
+			all = append(all, inst.Symbol)
+		}
+	}
+
+	log.Printf("OKX: found %d active instruments for shard %d/%d", len(all), c.opts.Shard.Index, c.opts.Shard.Count)
+	c.symbolChunks = chunkStrings(all, 10)
+	return nil
+}
+
+// fetchInstruments - справочник спотовых инструментов; минимальную сумму заявки OKX не публикует
+func (c *OKXConnector) fetchInstruments(ctx context.Context) ([]connectors.Instrument, error) {
 	resp, err := http.Get("https://www.okx.com/api/v5/public/instruments?instType=SPOT")
 	if err != nil {
-		return fmt.Errorf("get instruments: %w", err)
+		return nil, fmt.Errorf("get instruments: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := c.opts.CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("get instruments: %w", err)
+	}
+
 	var result instrumentResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("decode instruments: %w", err)
+		return nil, fmt.Errorf("decode instruments: %w", err)
 	}
 
 	if result.Code != "0" {
-		return fmt.Errorf("API error: %s", result.Msg)
+		return nil, fmt.Errorf("API error: %s", result.Msg)
 	}
 
-	var all []string
-	for _, inst := range result.Data {
-		if inst.State == "live" {
-			all = append(all, inst.InstID)
+	instruments := make([]connectors.Instrument, 0, len(result.Data))
+	for _, d := range result.Data {
+		inst := connectors.Instrument{
+			Exchange: "okx",
+			Symbol:   d.InstID,
+			Base:     d.BaseCcy,
+			Quote:    d.QuoteCcy,
+			TickSize: d.TickSz,
+			LotSize:  d.LotSz,
+			Status:   d.State,
+		}
+		// listTime - время листинга в миллисекундах, пустое для старых инструментов
+		if ms, err := strconv.ParseInt(d.ListTime, 10, 64); err == nil && ms > 0 {
+			t := time.UnixMilli(ms).UTC()
+			inst.ListingTime = &t
 		}
+		instruments = append(instruments, inst)
 	}
 
-	log.Printf("OKX: found %d active instruments", len(all))
-	c.symbolChunks = chunkStrings(all, 10)
-	return nil
+	return c.opts.OwnedInstruments(instruments), nil
 }
 
 func (c *OKXConnector) SubscribeToMarketData(ctx context.Context, pub producer.MessageProducer) error {
+	connectors.PublishInstruments(pub, c.instruments)
+	go connectors.RefreshInstruments(ctx, pub, c.fetchInstruments)
+
 	for _, chunk := range c.symbolChunks {
-		conn, _, err := websocket.DefaultDialer.Dial("wss://ws.okx.com:8443/ws/v5/public", nil)
-		if err != nil {
-			log.Printf("dial error: %v", err)
+		conn := ws.NewWSClient("wss://ws.okx.com:8443/ws/v5/public")
+		if err := conn.Connect(); err != nil {
+			c.opts.Report(status.Disconnected, fmt.Sprintf("dial: %v", err))
 			continue
 		}
+		c.opts.Report(status.Connected, fmt.Sprintf("%s, %d instruments", conn.URL, len(chunk)))
 
-		var args []map[string]string
-		for _, instID := range chunk {
-			args = append(args, map[string]string{
-				"channel": "tickers",
-				"instId":  instID,
-			})
-		}
-
-		subMsg := map[string]interface{}{
-			"op":   "subscribe",
-			"args": args,
-		}
-
-		if err := conn.WriteJSON(subMsg); err != nil {
+		if err := sendSubscription(conn, chunk, true); err != nil {
 			log.Printf("subscribe error: %v", err)
 			continue
 		}
 
-		_, msg, err := conn.ReadMessage()
+		msg, err := conn.ReadMessage()
 		if err != nil {
 			log.Printf("read subscription response error: %v", err)
 			continue
 		}
-		log.Printf("subscription response: %s", string(msg))
+		c.reportEvent(msg)
+
+		stream := connectors.NewStream(conn, chunk)
+		c.subs.Add(stream)
 
 		go func() {
 			ticker := time.NewTicker(15 * time.Second)
@@ -117,24 +174,80 @@ func (c *OKXConnector) SubscribeToMarketData(ctx context.Context, pub producer.M
 			}
 		}()
 
-		go handleConnection(ctx, conn, pub)
+		go c.handleConnection(ctx, stream, pub)
 	}
 
 	<-ctx.Done()
 	return ctx.Err()
 }
 
-func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.MessageProducer) {
-	defer conn.Close()
+func (c *OKXConnector) HandleCommand(ctx context.Context, cmd control.Command) ([]string, error) {
+	symbols, err := c.subs.HandleCommand(cmd, c.opts.Shard, sendSubscription)
+	// сделки без подписки не приходят, после нее счетчик начинается заново
+	if cmd.Action == control.Resync || cmd.Action == control.Unsubscribe {
+		c.sequences.Reset(symbols...)
+	}
+	return symbols, err
+}
+
+func sendSubscription(conn *ws.WSClient, instIDs []string, subscribe bool) error {
+	op := "unsubscribe"
+	if subscribe {
+		op = "subscribe"
+	}
+
+	// канал trades не публикуется, по нему проверяется, что сообщения не потеряны
+	var args []map[string]string
+	for _, instID := range instIDs {
+		for _, channel := range []string{"tickers", "trades"} {
+			args = append(args, map[string]string{
+				"channel": channel,
+				"instId":  instID,
+			})
+		}
+	}
+
+	return conn.WriteJSON(map[string]interface{}{
+		"op":   op,
+		"args": args,
+	})
+}
+
+// reportEvent - OKX отвечает событием на каждый аргумент подписки:
+// {"event": "subscribe", "arg": {...}} или {"event": "error", "code": "...", "msg": "..."}
+func (c *OKXConnector) reportEvent(msg []byte) {
+	var resp StreamResponse
+	if err := json.Unmarshal(msg, &resp); err != nil {
+		log.Printf("subscription response: %s", string(msg))
+		return
+	}
+
+	switch resp.Event {
+	case "subscribe":
+		c.opts.Report(status.SubscribeAck, resp.Arg.Channel+":"+resp.Arg.InstID)
+	case "error":
+		if resp.Code == errTooFrequent {
+			c.opts.Report(status.RateLimited, resp.Msg)
+			return
+		}
+		c.opts.Report(status.SubscribeError, resp.Code+": "+resp.Msg)
+	default:
+		log.Printf("subscription response: %s", string(msg))
+	}
+}
+
+func (c *OKXConnector) handleConnection(ctx context.Context, stream *connectors.Stream, pub producer.MessageProducer) {
+	defer c.subs.Remove(stream)
+	defer stream.Conn.Close()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			_, msg, err := conn.ReadMessage()
+			msg, err := stream.Conn.ReadMessage()
 			if err != nil {
-				log.Printf("read error: %v", err)
+				c.opts.Report(status.Disconnected, fmt.Sprintf("read: %v", err))
 				return
 			}
 
@@ -144,9 +257,23 @@ func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.Me
 				continue
 			}
 
-			if streamMsg.Arg.Channel == "tickers" {
+			if streamMsg.Event != "" {
+				c.reportEvent(msg)
+				continue
+			}
+
+			switch streamMsg.Arg.Channel {
+			case "trades":
+				for _, data := range streamMsg.Data {
+					c.observeTrade(data, pub)
+				}
+			case "tickers":
 				log.Printf("streamMsg: %v", streamMsg)
 				for _, data := range streamMsg.Data {
+					if c.subs.Paused() {
+						continue
+					}
+
 					if err := pub.Publish(data); err != nil {
 						log.Printf("publish error: %v", err)
 					}
@@ -156,6 +283,27 @@ func handleConnection(ctx context.Context, conn *websocket.Conn, pub producer.Me
 	}
 }
 
+// observeTrade - проверяет, что ID сделок инструмента идут подряд
+func (c *OKXConnector) observeTrade(data json.RawMessage, pub producer.MessageProducer) {
+	var trade tradeData
+	if err := json.Unmarshal(data, &trade); err != nil {
+		log.Printf("unmarshal trade error: %v", err)
+		return
+	}
+	last, err := strconv.ParseInt(trade.TradeID, 10, 64)
+	if err != nil {
+		return
+	}
+	count, err := strconv.ParseInt(trade.Count, 10, 64)
+	if err != nil || count < 1 {
+		count = 1
+	}
+
+	if ev, ok := c.sequences.ObserveRange(trade.InstID, last-count+1, last); ok {
+		connectors.PublishSequenceEvent(pub, ev)
+	}
+}
+
 func chunkStrings(list []string, size int) [][]string {
 	var chunks [][]string
 	for size < len(list) {
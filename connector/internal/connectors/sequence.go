@@ -0,0 +1,150 @@
+package connectors
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"connector/internal/producer"
+)
+
+const (
+	// SequenceGap - пропущены номера непрерывного счетчика: сообщения потеряны
+	SequenceGap = "gap"
+	// SequenceOutOfOrder - номер сообщения меньше уже полученного по символу
+	SequenceOutOfOrder = "out_of_order"
+)
+
+// SequenceEvent - нарушение последовательности сообщений по символу
+type SequenceEvent struct {
+	Exchange string    `json:"exchange"`
+	Symbol   string    `json:"symbol"`
+	Kind     string    `json:"kind"`
+	Expected int64     `json:"expected"`
+	Received int64     `json:"received"`
+	Time     time.Time `json:"time"`
+}
+
+// SequenceTracker - отслеживает номера последовательности по каждому символу.
+// Разрыв ищется только по непрерывным счетчикам (ID сделок Coinbase, tradeId OKX);
+// номера каналов тикеров (Coinbase sequence, Bybit cs) - общая последовательность инструмента
+// с пропусками, по ним проверяется только монотонность.
+type SequenceTracker struct {
+	exchange string
+
+	mu   sync.Mutex
+	last map[string]int64
+}
+
+func NewSequenceTracker(exchange string) *SequenceTracker {
+	return &SequenceTracker{
+		exchange: exchange,
+		last:     make(map[string]int64),
+	}
+}
+
+// Observe - запоминает номер и возвращает событие, если он меньше уже полученного
+func (t *SequenceTracker) Observe(symbol string, seq int64) (SequenceEvent, bool) {
+	if seq <= 0 {
+		return SequenceEvent{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.last[symbol]
+	if !ok {
+		t.last[symbol] = seq
+		return SequenceEvent{}, false
+	}
+
+	switch {
+	case seq < last:
+		return t.event(symbol, SequenceOutOfOrder, last+1, seq), true
+	case seq > last:
+		t.last[symbol] = seq
+	}
+	// повтор того же номера (например, снимок после переподключения) нарушением не считается
+	return SequenceEvent{}, false
+}
+
+// ObserveRange - номера first..last одного сообщения непрерывного счетчика
+// (сделки OKX агрегируются: tradeId последней сделки и их число). Пропуск перед first - разрыв.
+func (t *SequenceTracker) ObserveRange(symbol string, first, last int64) (SequenceEvent, bool) {
+	if first <= 0 || last < first {
+		return SequenceEvent{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.last[symbol]
+	if !ok {
+		t.last[symbol] = last
+		return SequenceEvent{}, false
+	}
+
+	switch {
+	case first > prev+1:
+		t.last[symbol] = last
+		return t.event(symbol, SequenceGap, prev+1, first), true
+	case last < prev:
+		return t.event(symbol, SequenceOutOfOrder, prev+1, first), true
+	default:
+		t.last[symbol] = last
+		return SequenceEvent{}, false
+	}
+}
+
+// ObserveLatest - последний номер, о котором сообщила биржа (last_trade_id в heartbeat Coinbase).
+// Если он больше полученного в сообщениях, сообщения с номерами Expected..Received потеряны.
+func (t *SequenceTracker) ObserveLatest(symbol string, latest int64) (SequenceEvent, bool) {
+	if latest <= 0 {
+		return SequenceEvent{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.last[symbol]
+	t.last[symbol] = max(prev, latest)
+	if !ok || latest <= prev {
+		return SequenceEvent{}, false
+	}
+	return t.event(symbol, SequenceGap, prev+1, latest), true
+}
+
+func (t *SequenceTracker) event(symbol, kind string, expected, received int64) SequenceEvent {
+	return SequenceEvent{
+		Exchange: t.exchange,
+		Symbol:   symbol,
+		Kind:     kind,
+		Expected: expected,
+		Received: received,
+		Time:     time.Now().UTC(),
+	}
+}
+
+// Reset - забывает последние номера символов, например после переподписки
+func (t *SequenceTracker) Reset(symbols ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, s := range symbols {
+		delete(t.last, s)
+	}
+}
+
+func PublishSequenceEvent(pub producer.MessageProducer, ev SequenceEvent) {
+	log.Printf("%s: sequence %s for %s: expected %d, received %d", ev.Exchange, ev.Kind, ev.Symbol, ev.Expected, ev.Received)
+
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("marshal sequence event: %v", err)
+		return
+	}
+	if err := pub.PublishKind(producer.KindSequenceGap, msg); err != nil {
+		log.Printf("publish sequence event: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package connectors
+
+import "hash/fnv"
+
+// Shard - часть символов биржи, которую обслуживает одна реплика коннектора.
+// Символ закрепляется за репликой rendezvous-хэшированием: назначение каждого символа
+// не зависит от остальных листингов, а при изменении числа реплик переезжает
+// только ~1/Count символов.
+type Shard struct {
+	Index int
+	Count int
+}
+
+func (s Shard) Owns(symbol string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	return shardOwner(symbol, s.Count) == s.Index
+}
+
+func (s Shard) Filter(symbols []string) []string {
+	if s.Count <= 1 {
+		return symbols
+	}
+
+	owned := make([]string, 0, len(symbols)/s.Count+1)
+	for _, symbol := range symbols {
+		if s.Owns(symbol) {
+			owned = append(owned, symbol)
+		}
+	}
+	return owned
+}
+
+func shardOwner(symbol string, count int) int {
+	h := fnv.New64a()
+	h.Write([]byte(symbol))
+	key := h.Sum64()
+
+	owner, best := 0, uint64(0)
+	for i := 0; i < count; i++ {
+		score := mix64(key ^ (uint64(i+1) * 0x9e3779b97f4a7c15))
+		if i == 0 || score > best {
+			owner, best = i, score
+		}
+	}
+	return owner
+}
+
+// mix64 - финализатор splitmix64, перемешивает биты хэша
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,208 @@
+package connectors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"connector/internal/control"
+	"connector/internal/ws"
+)
+
+// Stream - живое соединение и символы, на которые оно подписано
+type Stream struct {
+	Conn *ws.WSClient
+
+	mu      sync.Mutex
+	symbols map[string]struct{}
+}
+
+func NewStream(conn *ws.WSClient, symbols []string) *Stream {
+	s := &Stream{Conn: conn, symbols: make(map[string]struct{}, len(symbols))}
+	for _, symbol := range symbols {
+		s.symbols[symbol] = struct{}{}
+	}
+	return s
+}
+
+func (s *Stream) Symbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbols := make([]string, 0, len(s.symbols))
+	for symbol := range s.symbols {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func (s *Stream) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.symbols)
+}
+
+func (s *Stream) has(symbol string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.symbols[symbol]
+	return ok
+}
+
+func (s *Stream) update(symbols []string, subscribed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, symbol := range symbols {
+		if subscribed {
+			s.symbols[symbol] = struct{}{}
+		} else {
+			delete(s.symbols, symbol)
+		}
+	}
+}
+
+// SendFunc - отправляет в соединение сообщение подписки (subscribe = true) или отписки
+type SendFunc func(conn *ws.WSClient, symbols []string, subscribe bool) error
+
+// Subscriptions - распределение символов по живым соединениям коннектора
+type Subscriptions struct {
+	mu      sync.Mutex
+	streams []*Stream
+	paused  atomic.Bool
+}
+
+func (s *Subscriptions) Add(stream *Stream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams = append(s.streams, stream)
+}
+
+// Remove - вызывается при обрыве соединения
+func (s *Subscriptions) Remove(stream *Stream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, st := range s.streams {
+		if st == stream {
+			s.streams = append(s.streams[:i], s.streams[i+1:]...)
+			return
+		}
+	}
+}
+
+// Paused - публикация данных приостановлена командой pause
+func (s *Subscriptions) Paused() bool {
+	return s.paused.Load()
+}
+
+// HandleCommand - выполняет команду для бирж, подписка которых меняется сообщениями в сокете.
+// Подписка и отписка касаются только символов, принадлежащих шарду реплики.
+func (s *Subscriptions) HandleCommand(cmd control.Command, shard Shard, send SendFunc) ([]string, error) {
+	switch cmd.Action {
+	case control.Subscribe:
+		return s.subscribe(shard.Filter(cmd.Symbols), send)
+	case control.Unsubscribe:
+		return s.unsubscribe(shard.Filter(cmd.Symbols), send)
+	case control.Resync:
+		return s.Resync(send)
+	case control.Pause:
+		s.paused.Store(true)
+		return nil, nil
+	case control.Resume:
+		s.paused.Store(false)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported action: %s", cmd.Action)
+	}
+}
+
+// subscribe - новые символы добавляются в наименее загруженные соединения.
+// Если реплике не принадлежит ни один символ команды, подписывать нечего и это не ошибка.
+func (s *Subscriptions) subscribe(symbols []string, send SendFunc) ([]string, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.streams) == 0 {
+		return nil, fmt.Errorf("no live connections")
+	}
+
+	load := make(map[*Stream]int, len(s.streams))
+	for _, st := range s.streams {
+		load[st] = st.size()
+	}
+
+	groups := make(map[*Stream][]string)
+	for _, symbol := range symbols {
+		if s.ownerLocked(symbol) != nil {
+			continue
+		}
+		target := s.streams[0]
+		for _, st := range s.streams[1:] {
+			if load[st] < load[target] {
+				target = st
+			}
+		}
+		load[target]++
+		groups[target] = append(groups[target], symbol)
+	}
+
+	return s.apply(groups, send, true)
+}
+
+func (s *Subscriptions) unsubscribe(symbols []string, send SendFunc) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups := make(map[*Stream][]string)
+	for _, symbol := range symbols {
+		if st := s.ownerLocked(symbol); st != nil {
+			groups[st] = append(groups[st], symbol)
+		}
+	}
+
+	return s.apply(groups, send, false)
+}
+
+// Resync - повторно отправляет подписки всех соединений
+func (s *Subscriptions) Resync(send SendFunc) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups := make(map[*Stream][]string, len(s.streams))
+	for _, st := range s.streams {
+		groups[st] = st.Symbols()
+	}
+
+	return s.apply(groups, send, true)
+}
+
+func (s *Subscriptions) apply(groups map[*Stream][]string, send SendFunc, subscribe bool) ([]string, error) {
+	var applied []string
+	for st, symbols := range groups {
+		if len(symbols) == 0 {
+			continue
+		}
+		if err := send(st.Conn, symbols, subscribe); err != nil {
+			sort.Strings(applied)
+			return applied, err
+		}
+		st.update(symbols, subscribe)
+		applied = append(applied, symbols...)
+	}
+	sort.Strings(applied)
+	return applied, nil
+}
+
+func (s *Subscriptions) ownerLocked(symbol string) *Stream {
+	for _, st := range s.streams {
+		if st.has(symbol) {
+			return st
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package control
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	Subscribe   = "subscribe"
+	Unsubscribe = "unsubscribe"
+	Resync      = "resync"
+	Pause       = "pause"
+	Resume      = "resume"
+)
+
+// Command - команда управления подписками работающего коннектора
+type Command struct {
+	ID      string   `json:"id"`
+	Action  string   `json:"action"`
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// Ack - результат выполнения команды одной репликой коннектора
+type Ack struct {
+	ID       string    `json:"id"`
+	Action   string    `json:"action"`
+	Exchange string    `json:"exchange"`
+	Replica  int       `json:"replica"`
+	Symbols  []string  `json:"symbols,omitempty"` // символы, к которым применена команда
+	OK       bool      `json:"ok"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Handler - коннектор, поддерживающий управление подписками на лету.
+// Возвращает символы, к которым команда была применена.
+type Handler interface {
+	HandleCommand(ctx context.Context, cmd Command) ([]string, error)
+}
@@ -0,0 +1,156 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// queueExpiry - очередь реплики без потребителей удаляется через это время
+	queueExpiry = time.Minute
+	// commandTTL - команда, не прочитанная за это время, не применяется: контроллер уже не ждет подтверждения
+	commandTTL = 30 * time.Second
+)
+
+// Consumer - читает команды из fanout-обменника коннектора. Каждая реплика
+// получает все команды в свою очередь и применяет их к своей доле символов,
+// результат публикуется в обменник подтверждений.
+type Consumer struct {
+	conn        *amqp.Connection
+	ch          *amqp.Channel
+	queue       string
+	ackExchange string
+	exchange    string
+	replica     int
+}
+
+func NewConsumer(url, controlExchange, exchange string, replica int) (*Consumer, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("open channel: %w", err)
+	}
+
+	ackExchange := controlExchange + "_ack"
+	for _, name := range []string{controlExchange, ackExchange} {
+		if err := ch.ExchangeDeclare(name, "fanout", true, false, false, false, nil); err != nil {
+			return nil, fmt.Errorf("declare exchange %s: %w", name, err)
+		}
+	}
+
+	// очередь реплики нужна, только пока реплика работает: она удаляется вместе с последним
+	// потребителем или через queueExpiry без него, а устаревшие команды отбрасываются
+	queue := fmt.Sprintf("%s_%d", controlExchange, replica)
+	_, err = ch.QueueDeclare(
+		queue,
+		false, // durable
+		true,  // auto-delete
+		false, // exclusive: новая реплика с тем же номером может подключиться до закрытия старой
+		false, // no-wait
+		amqp.Table{
+			"x-expires":     int32(queueExpiry / time.Millisecond),
+			"x-message-ttl": int32(commandTTL / time.Millisecond),
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("declare queue: %w", err)
+	}
+
+	if err := ch.QueueBind(queue, "", controlExchange, false, nil); err != nil {
+		return nil, fmt.Errorf("bind queue: %w", err)
+	}
+
+	return &Consumer{
+		conn:        conn,
+		ch:          ch,
+		queue:       queue,
+		ackExchange: ackExchange,
+		exchange:    exchange,
+		replica:     replica,
+	}, nil
+}
+
+func (c *Consumer) Run(ctx context.Context, h Handler) error {
+	msgs, err := c.ch.Consume(c.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume %s: %w", c.queue, err)
+	}
+	log.Printf("control: listening for commands on %s", c.queue)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-msgs:
+			if !ok {
+				return fmt.Errorf("control channel closed")
+			}
+
+			ack := c.handle(ctx, h, msg.Body)
+			if err := c.publishAck(ack); err != nil {
+				log.Printf("control: publish ack for %s: %v", ack.ID, err)
+			}
+			if err := msg.Ack(false); err != nil {
+				log.Printf("control: ack delivery: %v", err)
+			}
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, h Handler, body []byte) Ack {
+	ack := Ack{Exchange: c.exchange, Replica: c.replica}
+
+	var cmd Command
+	if err := json.Unmarshal(body, &cmd); err != nil {
+		ack.Error = fmt.Sprintf("decode command: %v", err)
+		ack.Time = time.Now().UTC()
+		return ack
+	}
+	ack.ID = cmd.ID
+	ack.Action = cmd.Action
+
+	symbols, err := h.HandleCommand(ctx, cmd)
+	ack.Symbols = symbols
+	ack.OK = err == nil
+	if err != nil {
+		ack.Error = err.Error()
+	}
+	ack.Time = time.Now().UTC()
+
+	log.Printf("control: %s %v -> ok=%t %s", cmd.Action, symbols, ack.OK, ack.Error)
+	return ack
+}
+
+func (c *Consumer) publishAck(ack Ack) error {
+	body, err := json.Marshal(ack)
+	if err != nil {
+		return err
+	}
+	return c.ch.Publish(
+		c.ackExchange,
+		"",
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: ack.ID,
+			Body:          body,
+		},
+	)
+}
+
+func (c *Consumer) Close() error {
+	if err := c.ch.Close(); err != nil {
+		return err
+	}
+	return c.conn.Close()
+}
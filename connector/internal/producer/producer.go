@@ -2,12 +2,23 @@ package producer
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+// Типы служебных сообщений, передаются в свойстве Type сообщения AMQP.
+// Рыночные данные публикуются без типа.
+const (
+	KindSequenceGap = "sequence_gap"
+	KindInstrument  = "instrument"
+	KindTicker      = "ticker" // тикер в нормализованном виде от универсального коннектора
+	KindCandle      = "candle" // свеча из дозагрузки истории
+)
+
 type MessageProducer interface {
 	Publish([]byte) error
+	PublishKind(kind string, msg []byte) error
 	Close() error
 }
 
@@ -48,6 +59,10 @@ func NewRabbitProducer(url, queueName string) (*RabbitProducer, error) {
 }
 
 func (r *RabbitProducer) Publish(msg []byte) error {
+	return r.PublishKind("", msg)
+}
+
+func (r *RabbitProducer) PublishKind(kind string, msg []byte) error {
 	return r.ch.Publish(
 		"",
 		r.queue,
@@ -55,6 +70,8 @@ func (r *RabbitProducer) Publish(msg []byte) error {
 		false,
 		amqp.Publishing{
 			ContentType: "application/json",
+			Type:        kind,
+			Timestamp:   time.Now(), // по нему препроцессор определяет время тика, пролежавшего в очереди
 			Body:        msg,
 		},
 	)
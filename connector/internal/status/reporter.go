@@ -0,0 +1,101 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// События жизненного цикла коннектора
+const (
+	Connected      = "connected"
+	SubscribeAck   = "subscribe_ack"
+	SubscribeError = "subscribe_error"
+	Disconnected   = "disconnected"
+	RateLimited    = "rate_limited"
+)
+
+type Event struct {
+	Exchange string    `json:"exchange"`
+	Replica  int       `json:"replica"`
+	Event    string    `json:"event"`
+	Detail   string    `json:"detail,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type Reporter interface {
+	Report(event, detail string)
+}
+
+// RabbitReporter - публикует события в topic-обменник с ключом <exchange>.<event>
+type RabbitReporter struct {
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	exchange string
+	source   string
+	replica  int
+}
+
+func NewRabbitReporter(url, exchange, source string, replica int) (*RabbitReporter, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("open channel: %w", err)
+	}
+
+	if err := ch.ExchangeDeclare(exchange, "topic", true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("declare exchange: %w", err)
+	}
+
+	return &RabbitReporter{
+		conn:     conn,
+		ch:       ch,
+		exchange: exchange,
+		source:   source,
+		replica:  replica,
+	}, nil
+}
+
+func (r *RabbitReporter) Report(event, detail string) {
+	log.Printf("status: %s %s", event, detail)
+
+	body, err := json.Marshal(Event{
+		Exchange: r.source,
+		Replica:  r.replica,
+		Event:    event,
+		Detail:   detail,
+		Time:     time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("status: marshal event: %v", err)
+		return
+	}
+
+	err = r.ch.Publish(
+		r.exchange,
+		r.source+"."+event,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+	if err != nil {
+		log.Printf("status: publish event: %v", err)
+	}
+}
+
+func (r *RabbitReporter) Close() error {
+	if err := r.ch.Close(); err != nil {
+		return err
+	}
+	return r.conn.Close()
+}
@@ -1,14 +1,19 @@
 package ws
 
 import (
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// WSClient - обертка над соединением; gorilla/websocket допускает только одного
+// писателя, поэтому запись (пинги, подписки, команды управления) сериализуется
 type WSClient struct {
 	Conn *websocket.Conn
 	URL  string
+
+	mu sync.Mutex
 }
 
 func NewWSClient(url string) *WSClient {
@@ -30,9 +35,17 @@ func (c *WSClient) ReadMessage() ([]byte, error) {
 }
 
 func (c *WSClient) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.Conn.WriteMessage(messageType, data)
 }
 
+func (c *WSClient) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
 func (c *WSClient) SetReadDeadline(t time.Time) error {
 	return c.Conn.SetReadDeadline(t)
 }
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"connector/internal/config"
+	"connector/internal/connectors"
+	"connector/internal/connectors/deribit"
+	"connector/internal/producer"
+
+	"github.com/gorilla/websocket"
+)
+
+// recordingProducer - запоминает опубликованные сообщения вместо отправки в RabbitMQ
+type recordingProducer struct {
+	mu   sync.Mutex
+	msgs map[string][][]byte
+	data chan []byte
+}
+
+func newRecordingProducer() *recordingProducer {
+	return &recordingProducer{msgs: make(map[string][][]byte), data: make(chan []byte, 10)}
+}
+
+func (p *recordingProducer) Publish(msg []byte) error {
+	return p.PublishKind("", msg)
+}
+
+func (p *recordingProducer) PublishKind(kind string, msg []byte) error {
+	p.mu.Lock()
+	p.msgs[kind] = append(p.msgs[kind], msg)
+	p.mu.Unlock()
+	if kind == "" {
+		p.data <- msg
+	}
+	return nil
+}
+
+func (p *recordingProducer) Close() error { return nil }
+
+// newDeribitStub - заглушка Deribit: get_instruments по HTTP и подписка на тикеры по WebSocket
+func newDeribitStub(t *testing.T, subscribed chan<- []string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v2/public/get_instruments", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("currency") != "BTC" || r.URL.Query().Get("kind") != "option" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": []interface{}{}})
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","result":[
+			{"instrument_name":"BTC-27DEC24-100000-C","is_active":true,"base_currency":"BTC","counter_currency":"USD","tick_size":0.0005,"min_trade_amount":0.1,"creation_timestamp":1700000000000},
+			{"instrument_name":"BTC-27DEC24-90000-P","is_active":false,"base_currency":"BTC","counter_currency":"USD","tick_size":0.0005,"min_trade_amount":0.1,"creation_timestamp":1700000000000}
+		]}`))
+	})
+
+	mux.HandleFunc("/ws/api/v2", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req struct {
+				ID     int64  `json:"id"`
+				Method string `json:"method"`
+				Params struct {
+					Channels []string `json:"channels"`
+				} `json:"params"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.Method != "public/subscribe" {
+				conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "ok"})
+				continue
+			}
+
+			subscribed <- req.Params.Channels
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": req.Params.Channels})
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"subscription","params":{
+				"channel":"ticker.BTC-27DEC24-100000-C.100ms",
+				"data":{"instrument_name":"BTC-27DEC24-100000-C","timestamp":1700000001000,"mark_iv":52.1,"bid_iv":50.5,"ask_iv":53.7,
+					"underlying_price":97000.5,"open_interest":120.4,"greeks":{"delta":0.41,"gamma":0.00002,"vega":85.1,"theta":-120.3,"rho":10.2}}}}`))
+		}
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestDeribitConnector_Stub(t *testing.T) {
+	subscribed := make(chan []string, 1)
+	stub := newDeribitStub(t, subscribed)
+	defer stub.Close()
+
+	c := deribit.NewConnector(
+		config.DeribitConfig{URL: stub.URL, Currencies: []string{"BTC", "ETH"}},
+		connectors.Options{Shard: connectors.Shard{Index: 0, Count: 1}},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	pub := newRecordingProducer()
+	go c.SubscribeToMarketData(ctx, pub)
+
+	select {
+	case channels := <-subscribed:
+		if len(channels) != 1 || channels[0] != "ticker.BTC-27DEC24-100000-C.100ms" {
+			t.Fatalf("unexpected channels: %v", channels)
+		}
+	case <-ctx.Done():
+		t.Fatal("no subscription received")
+	}
+
+	select {
+	case data := <-pub.data:
+		var ticker struct {
+			InstrumentName string  `json:"instrument_name"`
+			MarkIV         float64 `json:"mark_iv"`
+			Greeks         struct {
+				Delta float64 `json:"delta"`
+			} `json:"greeks"`
+		}
+		if err := json.Unmarshal(data, &ticker); err != nil {
+			t.Fatalf("unmarshal ticker: %v", err)
+		}
+		if ticker.InstrumentName != "BTC-27DEC24-100000-C" || ticker.MarkIV != 52.1 || ticker.Greeks.Delta != 0.41 {
+			t.Fatalf("unexpected ticker: %s", data)
+		}
+	case <-ctx.Done():
+		t.Fatal("no ticker published")
+	}
+
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	instruments := pub.msgs[producer.KindInstrument]
+	if len(instruments) != 2 {
+		t.Fatalf("expected 2 instruments, got %d", len(instruments))
+	}
+	if !strings.Contains(string(instruments[0]), `"market":"options"`) {
+		t.Fatalf("instrument without options market: %s", instruments[0])
+	}
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connector/internal/config"
+	"connector/internal/connectors"
+	"connector/internal/connectors/dex"
+	"connector/internal/producer"
+)
+
+const testPools = `
+pools:
+  - name: ETH-USDC
+    address: "0x00000000000000000000000000000000000000A2"
+    version: v2
+    token0: {symbol: USDC, decimals: 6}
+    token1: {symbol: WETH, decimals: 18}
+    invert: true
+  - address: "0x00000000000000000000000000000000000000A3"
+    version: v3
+    token0: {symbol: WBTC, decimals: 8}
+    token1: {symbol: WETH, decimals: 18}
+`
+
+// abiWords - ответ eth_call из 32-байтовых слов
+func abiWords(values ...*big.Int) string {
+	out := "0x"
+	for _, v := range values {
+		out += fmt.Sprintf("%064x", v)
+	}
+	return out
+}
+
+// newJSONRPCStub - заглушка узла Ethereum: постоянный номер блока и состояние двух пулов
+func newJSONRPCStub(t *testing.T) *httptest.Server {
+	// 3 000 000 USDC и 1000 WETH - 3000 USDC за ETH
+	reserve0 := big.NewInt(3_000_000_000_000)
+	reserve1, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	// sqrtPriceX96 = 4e5 * 2^96 - 16e10 сырых единиц WETH за WBTC, то есть 16 WETH
+	sqrtPrice := new(big.Int).Mul(big.NewInt(400_000), new(big.Int).Lsh(big.NewInt(1), 96))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int64             `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			return
+		}
+
+		var result string
+		switch req.Method {
+		case "eth_blockNumber":
+			result = "0x10"
+		case "eth_call":
+			var call struct {
+				To   string `json:"to"`
+				Data string `json:"data"`
+			}
+			var block string
+			json.Unmarshal(req.Params[0], &call)
+			json.Unmarshal(req.Params[1], &block)
+			if block != "0x10" {
+				t.Errorf("eth_call on block %s, want 0x10", block)
+			}
+
+			switch call.Data {
+			case "0x0902f1ac":
+				result = abiWords(reserve0, reserve1, big.NewInt(1700000000))
+			case "0x3850c7bd":
+				result = abiWords(sqrtPrice, big.NewInt(0), big.NewInt(0), big.NewInt(1), big.NewInt(1), big.NewInt(0), big.NewInt(1))
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+}
+
+func TestDexConnector_Stub(t *testing.T) {
+	stub := newJSONRPCStub(t)
+	defer stub.Close()
+
+	poolsFile := filepath.Join(t.TempDir(), "pools.yaml")
+	if err := os.WriteFile(poolsFile, []byte(testPools), 0o644); err != nil {
+		t.Fatalf("write pools: %v", err)
+	}
+
+	c := dex.NewConnector(
+		config.DexConfig{RPCURL: stub.URL, PoolsFile: poolsFile, PollInterval: 20 * time.Millisecond},
+		connectors.Options{Shard: connectors.Shard{Index: 0, Count: 1}},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	pub := newRecordingProducer()
+	c.SubscribeToMarketData(ctx, pub)
+
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+
+	// блок не меняется, поэтому цены публикуются только один раз
+	tickers := pub.msgs[producer.KindTicker]
+	if len(tickers) != 2 {
+		t.Fatalf("expected 2 tickers, got %d", len(tickers))
+	}
+
+	want := map[string]dex.Ticker{
+		"ETH-USDC":  {Exchange: "uniswap_v2", Symbol: "ETH-USDC", Price: "3000"},
+		"WBTC-WETH": {Exchange: "uniswap_v3", Symbol: "WBTC-WETH", Price: "16"},
+	}
+	for _, data := range tickers {
+		var got dex.Ticker
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("unmarshal ticker: %v", err)
+		}
+		w, ok := want[got.Symbol]
+		if !ok || got.Exchange != w.Exchange || got.Price != w.Price || got.Market != "dex" || got.Block != 16 {
+			t.Fatalf("unexpected ticker: %s", data)
+		}
+	}
+
+	if instruments := pub.msgs[producer.KindInstrument]; len(instruments) != 2 {
+		t.Fatalf("expected 2 instruments, got %d", len(instruments))
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connector/internal/config"
+	"connector/internal/connectors/fx"
+)
+
+func TestFXConnector_CbrNominal(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0"?>
+<ValCurs Date="15.01.2024" name="Foreign Currency Market">
+	<Valute><CharCode>USD</CharCode><Nominal>1</Nominal><Value>89,6883</Value></Valute>
+	<Valute><CharCode>JPY</CharCode><Nominal>100</Nominal><Value>61,2839</Value></Valute>
+	<Valute><CharCode>KZT</CharCode><Nominal>100</Nominal><Value>19,6730</Value></Valute>
+	<Valute><CharCode>VND</CharCode><Nominal>10000</Nominal><Value>36,5901</Value></Valute>
+	<Valute><CharCode>HUF</CharCode><Nominal>100</Nominal><Value>25,8915</Value><VunitRate>0,258915</VunitRate></Valute>
+</ValCurs>`))
+	}))
+	defer stub.Close()
+
+	c := fx.NewConnector(config.FXConfig{Provider: "cbr", URL: stub.URL, Interval: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	pub := newRecordingProducer()
+	go c.SubscribeToMarketData(ctx, pub)
+
+	// курс за единицу делится без float64 и записывается точно, без лишних нулей
+	want := map[string]string{
+		"USD": "89.6883",
+		"JPY": "0.612839",
+		"KZT": "0.19673",
+		"VND": "0.00365901",
+		"HUF": "0.258915",
+	}
+	for range want {
+		var msg fx.RateMessage
+		select {
+		case raw := <-pub.data:
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				t.Fatalf("decode rate: %v", err)
+			}
+		case <-ctx.Done():
+			t.Fatal("not all rates published")
+		}
+		if msg.Quote != "RUB" || msg.Rate != want[msg.Base] {
+			t.Errorf("%s/%s: got %s, want %s", msg.Base, msg.Quote, msg.Rate, want[msg.Base])
+		}
+	}
+}
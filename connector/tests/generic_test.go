@@ -0,0 +1,292 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"connector/internal/connectors"
+	"connector/internal/connectors/generic"
+	"connector/internal/producer"
+
+	"github.com/gorilla/websocket"
+)
+
+const pathDocument = `{
+	"time_ms": 1700000000000,
+	"result": [
+		{"id": "BTC_USDT", "status": "online", "last": 102.5, "meta": {"tick-size": "0.01"}},
+		{"id": "ETH_USDT", "status": "offline", "last": "2000"},
+		{"id": "SOL_USDT", "status": "online", "active": true}
+	],
+	"markets": {"b": {"name": "second"}, "a": {"name": "first"}}
+}`
+
+func decodeDocument(t *testing.T, doc string) interface{} {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	return v
+}
+
+func TestPath_Strings(t *testing.T) {
+	doc := decodeDocument(t, pathDocument)
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"$.time_ms", []string{"1700000000000"}},
+		{"$.result[0].id", []string{"BTC_USDT"}},
+		{"$.result[-1].id", []string{"SOL_USDT"}},
+		{"$.result[5].id", nil},
+		{"$.result[*].id", []string{"BTC_USDT", "ETH_USDT", "SOL_USDT"}},
+		// числа отдаются в исходной записи, без преобразования во float64
+		{"$.result[*].last", []string{"102.5", "2000"}},
+		{"$.result[0].meta['tick-size']", []string{"0.01"}},
+		{"$.result[?(@.status=='online')].id", []string{"BTC_USDT", "SOL_USDT"}},
+		{"$.result[?(@.status!='online')].id", []string{"ETH_USDT"}},
+		{"$.result[?(@.active==true)].id", []string{"SOL_USDT"}},
+		// значения объекта перебираются в порядке ключей
+		{"$.markets.*.name", []string{"first", "second"}},
+		{"$.result[*].missing", nil},
+		// у пути без "$" то же значение относительно переданного узла
+		{"result[1].id", []string{"ETH_USDT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := generic.ParsePath(tt.expr)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := p.Strings(doc); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPath_Scalar(t *testing.T) {
+	doc := decodeDocument(t, pathDocument)
+
+	p, err := generic.ParsePath("$.result[*].last")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got, ok := p.Scalar(doc); !ok || got != "102.5" {
+		t.Fatalf("expected first value 102.5, got %q %v", got, ok)
+	}
+
+	// объект не скаляр, а отсутствующее поле не превращается в пустую строку или ноль
+	for _, expr := range []string{"$.result[0].meta", "$.result[2].last"} {
+		p, err := generic.ParsePath(expr)
+		if err != nil {
+			t.Fatalf("parse %s: %v", expr, err)
+		}
+		if got, ok := p.Scalar(doc); ok {
+			t.Fatalf("%s: expected no scalar, got %q", expr, got)
+		}
+	}
+}
+
+func TestParsePath_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"$.result[",
+		"$.result[abc]",
+		"$..id",
+		"$.result[?(@.status)]",
+		"$.result[?(@.status=='online']",
+		"$.result[?(status=='online')]",
+		"$result",
+	} {
+		if _, err := generic.ParsePath(expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+}
+
+func writeSpec(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write spec: %v", err)
+	}
+	return path
+}
+
+func TestLoadSpec(t *testing.T) {
+	spec, err := generic.LoadSpec("../specs/gateio.yaml")
+	if err != nil {
+		t.Fatalf("load gateio spec: %v", err)
+	}
+	if spec.Name != "gateio" || spec.Data.Price != "last" || spec.Ping.Interval != 15*time.Second {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+	if _, err := generic.NewConnector(spec, connectors.Options{}); err != nil {
+		t.Fatalf("compile gateio spec: %v", err)
+	}
+
+	if _, err := generic.LoadSpec(writeSpec(t, "name: x\nunknown_field: 1\n")); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+
+	invalid := map[string]string{
+		"no price path": `
+name: x
+url: ws://localhost
+discovery: {url: http://localhost, symbols: "$[*].id"}
+subscribe: '{{json .Symbols}}'
+data: {symbol: s}
+`,
+		"bad path": `
+name: x
+url: ws://localhost
+discovery: {url: http://localhost, symbols: "$[*"}
+subscribe: '{{json .Symbols}}'
+data: {symbol: s, price: p}
+`,
+		"bad template": `
+name: x
+url: ws://localhost
+discovery: {url: http://localhost, symbols: "$[*].id"}
+subscribe: '{{json .Symbols'
+data: {symbol: s, price: p}
+`,
+	}
+	for name, body := range invalid {
+		spec, err := generic.LoadSpec(writeSpec(t, body))
+		if err != nil {
+			t.Fatalf("%s: load: %v", name, err)
+		}
+		if _, err := generic.NewConnector(spec, connectors.Options{}); err == nil {
+			t.Errorf("%s: expected compile error", name)
+		}
+	}
+}
+
+// newGenericStub - биржа в формате Gate.io: список пар по HTTP и тикеры по WebSocket;
+// у второго тикера нет объема и диапазона цены
+func newGenericStub(t *testing.T, subscribed chan<- []string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/pairs", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":"BTC_USDT","trade_status":"tradable"},{"id":"OLD_USDT","trade_status":"untradable"},{"id":"ETH_USDT","trade_status":"tradable"}]`))
+	})
+
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var req struct {
+			Payload []string `json:"payload"`
+		}
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		subscribed <- req.Payload
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"subscribe","result":{"status":"success"}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"time_ms":1700000000000,"result":[
+			{"currency_pair":"BTC_USDT","last":"97000.5","base_volume":"1234.5","high_24h":"98000","low_24h":"95000","change_percentage":"1.5"},
+			{"currency_pair":"ETH_USDT","last":3500.25}
+		]}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGenericConnector_Stub(t *testing.T) {
+	subscribed := make(chan []string, 1)
+	stub := newGenericStub(t, subscribed)
+	defer stub.Close()
+
+	spec, err := generic.LoadSpec(writeSpec(t, `
+name: stub
+url: `+strings.Replace(stub.URL, "http", "ws", 1)+`/ws
+discovery:
+  url: `+stub.URL+`/pairs
+  symbols: "$[?(@.trade_status=='tradable')].id"
+subscribe: '{"payload": {{json .Symbols}}}'
+data:
+  items: $.result[*]
+  symbol: currency_pair
+  price: last
+  volume: base_volume
+  high: high_24h
+  low: low_24h
+  change_percent: change_percentage
+  timestamp: $.time_ms
+`))
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	c, err := generic.NewConnector(spec, connectors.Options{Shard: connectors.Shard{Index: 0, Count: 1}})
+	if err != nil {
+		t.Fatalf("new connector: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	pub := newRecordingProducer()
+	go c.SubscribeToMarketData(ctx, pub)
+
+	select {
+	case symbols := <-subscribed:
+		if !reflect.DeepEqual(symbols, []string{"BTC_USDT", "ETH_USDT"}) {
+			t.Fatalf("unexpected symbols: %v", symbols)
+		}
+	case <-ctx.Done():
+		t.Fatal("no subscription received")
+	}
+
+	var tickers [][]byte
+	for len(tickers) < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("expected 2 tickers, got %d", len(tickers))
+		case <-time.After(10 * time.Millisecond):
+		}
+		pub.mu.Lock()
+		tickers = pub.msgs[producer.KindTicker]
+		pub.mu.Unlock()
+	}
+
+	want := []string{
+		`{"exchange":"stub","symbol":"BTC_USDT","price":"97000.5","volume":"1234.5","high":"98000","low":"95000","change_percent":"1.5","timestamp":"1700000000000"}`,
+		// отсутствующие объем и диапазон не публикуются, а не заменяются нулями
+		`{"exchange":"stub","symbol":"ETH_USDT","price":"3500.25","timestamp":"1700000000000"}`,
+	}
+	for i, w := range want {
+		if !bytes.Equal(tickers[i], []byte(w)) {
+			t.Errorf("ticker %d:\n got %s\nwant %s", i, tickers[i], w)
+		}
+	}
+}
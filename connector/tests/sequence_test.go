@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"connector/internal/connectors"
+)
+
+// Номера тикеров Coinbase - общая последовательность продукта: сообщения канала ticker
+// приходят с пропусками, повторами после переподключения и только изредка не по порядку
+func TestSequenceTracker_CoinbaseTickerStream(t *testing.T) {
+	stream := []struct {
+		ProductID string `json:"product_id"`
+		Sequence  int64  `json:"sequence"`
+	}{}
+	raw := `[
+		{"product_id": "BTC-USD", "sequence": 71734261829},
+		{"product_id": "ETH-USD", "sequence": 52931745612},
+		{"product_id": "BTC-USD", "sequence": 71734261874},
+		{"product_id": "BTC-USD", "sequence": 71734262210},
+		{"product_id": "ETH-USD", "sequence": 52931745690},
+		{"product_id": "BTC-USD", "sequence": 71734262210},
+		{"product_id": "BTC-USD", "sequence": 71734263001},
+		{"product_id": "ETH-USD", "sequence": 52931746003}
+	]`
+	if err := json.Unmarshal([]byte(raw), &stream); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := connectors.NewSequenceTracker("coinbase")
+	for _, msg := range stream {
+		if ev, ok := tracker.Observe(msg.ProductID, msg.Sequence); ok {
+			t.Errorf("unexpected %s event for %s: expected %d, received %d", ev.Kind, ev.Symbol, ev.Expected, ev.Received)
+		}
+	}
+
+	ev, ok := tracker.Observe("BTC-USD", 71734262500)
+	if !ok || ev.Kind != connectors.SequenceOutOfOrder {
+		t.Fatalf("expected out_of_order event, got %+v, %v", ev, ok)
+	}
+	if ev.Received != 71734262500 || ev.Expected != 71734263002 {
+		t.Errorf("unexpected event numbers: %+v", ev)
+	}
+
+	// после переподписки последовательность начинается заново
+	tracker.Reset("BTC-USD")
+	if ev, ok := tracker.Observe("BTC-USD", 100); ok {
+		t.Errorf("unexpected event after reset: %+v", ev)
+	}
+}
+
+// Coinbase: тикер несет ID последней сделки, heartbeat раз в секунду сообщает last_trade_id продукта
+func TestSequenceTracker_CoinbaseHeartbeat(t *testing.T) {
+	tracker := connectors.NewSequenceTracker("coinbase")
+
+	// первый heartbeat до тикеров только запоминает номер
+	if ev, ok := tracker.ObserveLatest("BTC-USD", 500); ok {
+		t.Fatalf("unexpected event for first heartbeat: %+v", ev)
+	}
+	// каскад сделок 501-504 приходит одним тикером
+	for _, id := range []int64{501, 504} {
+		if ev, ok := tracker.Observe("BTC-USD", id); ok {
+			t.Fatalf("unexpected event for trade %d: %+v", id, ev)
+		}
+	}
+	if ev, ok := tracker.ObserveLatest("BTC-USD", 504); ok {
+		t.Fatalf("unexpected event when caught up: %+v", ev)
+	}
+
+	// тикеры сделок 505-507 потеряны
+	ev, ok := tracker.ObserveLatest("BTC-USD", 507)
+	if !ok || ev.Kind != connectors.SequenceGap || ev.Expected != 505 || ev.Received != 507 {
+		t.Fatalf("expected gap 505..507, got %+v, %v", ev, ok)
+	}
+	// о том же разрыве сообщается один раз
+	if ev, ok := tracker.ObserveLatest("BTC-USD", 507); ok {
+		t.Fatalf("gap reported twice: %+v", ev)
+	}
+
+	ev, ok = tracker.Observe("BTC-USD", 503)
+	if !ok || ev.Kind != connectors.SequenceOutOfOrder {
+		t.Fatalf("expected out_of_order event, got %+v, %v", ev, ok)
+	}
+}
+
+// OKX: сделки агрегируются, tradeId - последняя сделка, count - их число
+func TestSequenceTracker_OKXTradeRanges(t *testing.T) {
+	tracker := connectors.NewSequenceTracker("okx")
+
+	for _, r := range [][2]int64{{100, 100}, {101, 103}, {104, 104}, {104, 104}} {
+		if ev, ok := tracker.ObserveRange("BTC-USDT", r[0], r[1]); ok {
+			t.Fatalf("unexpected event for %v: %+v", r, ev)
+		}
+	}
+
+	ev, ok := tracker.ObserveRange("BTC-USDT", 108, 110)
+	if !ok || ev.Kind != connectors.SequenceGap || ev.Expected != 105 || ev.Received != 108 {
+		t.Fatalf("expected gap before 108, got %+v, %v", ev, ok)
+	}
+	if ev, ok := tracker.ObserveRange("BTC-USDT", 111, 111); ok {
+		t.Fatalf("unexpected event after gap: %+v", ev)
+	}
+
+	ev, ok = tracker.ObserveRange("BTC-USDT", 102, 103)
+	if !ok || ev.Kind != connectors.SequenceOutOfOrder {
+		t.Fatalf("expected out_of_order event, got %+v, %v", ev, ok)
+	}
+
+	// у другого инструмента свой счетчик
+	if ev, ok := tracker.ObserveRange("ETH-USDT", 7, 7); ok {
+		t.Fatalf("unexpected event for another instrument: %+v", ev)
+	}
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"testing"
+
+	"connector/internal/connectors"
+	"connector/internal/control"
+	"connector/internal/ws"
+)
+
+func TestSubscriptions_SubscribeForeignShard(t *testing.T) {
+	shard := connectors.Shard{Index: 0, Count: 2}
+	var owned, foreign []string
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "XRPUSDT", "DOGEUSDT", "ADAUSDT"} {
+		if shard.Owns(symbol) {
+			owned = append(owned, symbol)
+		} else {
+			foreign = append(foreign, symbol)
+		}
+	}
+	if len(owned) == 0 || len(foreign) == 0 {
+		t.Fatalf("symbols are not split between shards: owned %v, foreign %v", owned, foreign)
+	}
+
+	var subs connectors.Subscriptions
+	send := func(conn *ws.WSClient, symbols []string, subscribe bool) error {
+		t.Fatalf("unexpected send of %v", symbols)
+		return nil
+	}
+
+	// символы другой реплики не подписываются, и отсутствие соединений ошибкой не считается
+	applied, err := subs.HandleCommand(control.Command{Action: control.Subscribe, Symbols: foreign}, shard, send)
+	if err != nil || applied != nil {
+		t.Fatalf("expected no-op for foreign symbols, got %v, %v", applied, err)
+	}
+
+	if _, err := subs.HandleCommand(control.Command{Action: control.Subscribe, Symbols: owned}, shard, send); err == nil {
+		t.Fatal("expected error without live connections")
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/docker/docker v28.0.1+incompatible
 	github.com/fsnotify/fsnotify v1.8.0
 	github.com/golang-migrate/migrate/v4 v4.18.2
+	github.com/streadway/amqp v1.1.0
 	github.com/stretchr/testify v1.10.0
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -2,10 +2,48 @@ package api
 
 import (
 	"controller/internal/config"
+	"controller/internal/controller"
 	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 )
 
+const commandTimeout = 10 * time.Second
+
+var (
+	cfgMu         sync.RWMutex
+	currentConfig config.Config
+)
+
+// SetConfig - обновляет конфигурацию, по которой API находит коннекторы
+func SetConfig(cfg config.Config) {
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	currentConfig = cfg
+}
+
+func findConnector(name string) (config.Connector, bool) {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	for _, c := range currentConfig.Connectors {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return config.Connector{}, false
+}
+
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+// StatusHandler - последние события жизненного цикла реплик коннекторов
+func StatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(controller.Statuses())
+}
+
 func AddConnectorHandler(w http.ResponseWriter, r *http.Request) {
 	var cfg config.Connector
 	json.NewDecoder(r.Body).Decode(&cfg)
@@ -23,3 +61,35 @@ func StopConnectorHandler(w http.ResponseWriter, r *http.Request) {
 	// controller.StopConnector(request.Name) // TODO: остановить коннектор
 	w.Write([]byte("Коннектор остановлен"))
 }
+
+// SendCommandHandler - отправляет команду подписки работающему коннектору и возвращает подтверждения реплик
+func SendCommandHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := findConnector(r.PathValue("name"))
+	if !ok {
+		http.Error(w, "Коннектор не найден", http.StatusNotFound)
+		return
+	}
+
+	var cmd controller.Command
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil || cmd.Action == "" {
+		http.Error(w, "Некорректная команда", http.StatusBadRequest)
+		return
+	}
+
+	acks, err := controller.SendCommand(c, cmd, commandTimeout)
+
+	response := struct {
+		Acks  []controller.CommandAck `json:"acks"`
+		Error string                  `json:"error,omitempty"`
+	}{Acks: acks}
+
+	status := http.StatusOK
+	if err != nil {
+		response.Error = err.Error()
+		status = http.StatusGatewayTimeout
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -6,8 +6,11 @@ import (
 )
 
 func StartServer() {
+	http.HandleFunc("/health", HealthHandler)
+	http.HandleFunc("GET /status", StatusHandler)
 	http.HandleFunc("/add-connector", AddConnectorHandler)
 	http.HandleFunc("/stop-connector", StopConnectorHandler)
+	http.HandleFunc("POST /connectors/{name}/commands", SendCommandHandler)
 
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
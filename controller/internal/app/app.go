@@ -1,9 +1,11 @@
 package app
 
 import (
+	"controller/internal/api"
 	"controller/internal/config"
 	"controller/internal/controller"
 	"log"
+	"time"
 )
 
 func Run(configPath string) {
@@ -25,7 +27,25 @@ func Run(configPath string) {
 		}
 	}
 
-	// go api.StartServer() // TODO: запустить api
+	for _, p := range cfg.Preprocessors {
+		if !cfg.HasConnector(p.Queue) {
+			if err := controller.StartStandalonePreprocessor(p, cfg.Network); err != nil {
+				log.Println("Ошибка запуска preprocessor", p.Name, err)
+			}
+		}
+	}
+
+	go func() {
+		for {
+			if err := controller.MonitorConnectors(config.GetRabbitMQURL()); err != nil {
+				log.Println("Ошибка мониторинга коннекторов", err)
+			}
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	api.SetConfig(cfg)
+	go api.StartServer()
 
 	updateChan := make(chan struct{})
 	go controller.WatchConfigFile(configPath, updateChan)
@@ -33,6 +53,7 @@ func Run(configPath string) {
 	for {
 		<-updateChan
 		newConfig := config.LoadConfig(configPath)
+		api.SetConfig(newConfig)
 		controller.UpdateServices(newConfig)
 	}
 }
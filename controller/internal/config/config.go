@@ -9,13 +9,36 @@ import (
 )
 
 type Connector struct {
-	Name        string `yaml:"name"`
-	Image       string `yaml:"image"`
-	Exchange    string `yaml:"exchange"`
-	Queue       string `yaml:"queue"`
+	Name        string            `yaml:"name"`
+	Image       string            `yaml:"image"`
+	Exchange    string            `yaml:"exchange"`
+	Queue       string            `yaml:"queue"`
+	Replicas    int               `yaml:"replicas"` // число реплик, между которыми делятся символы биржи
+	Env         map[string]string `yaml:"env"`      // дополнительные переменные окружения коннектора
 	RabbitMQURL string
 }
 
+// ControlExchange - обменник команд управления подписками коннектора
+func (c Connector) ControlExchange() string {
+	if name, ok := c.Env["CONTROL_EXCHANGE"]; ok {
+		return name
+	}
+	return c.Queue + "_control"
+}
+
+// ReplicaNames - имена контейнеров реплик коннектора
+func (c Connector) ReplicaNames() []string {
+	if c.Replicas <= 1 {
+		return []string{c.Name}
+	}
+
+	names := make([]string, c.Replicas)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", c.Name, i)
+	}
+	return names
+}
+
 type Preprocessor struct {
 	Name        string `yaml:"name"`
 	Exchange    string `yaml:"exchange"`
@@ -42,6 +65,16 @@ type Config struct {
 	Preprocessors []Preprocessor `yaml:"preprocessors"`
 }
 
+// HasConnector - есть ли коннектор, публикующий в очередь
+func (c Config) HasConnector(queue string) bool {
+	for _, conn := range c.Connectors {
+		if conn.Queue == queue {
+			return true
+		}
+	}
+	return false
+}
+
 func LoadConfig(path string) Config {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"controller/internal/config"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Command - команда управления подписками работающего коннектора
+type Command struct {
+	ID      string   `json:"id"`
+	Action  string   `json:"action"`
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// CommandAck - подтверждение команды от реплики коннектора
+type CommandAck struct {
+	ID       string    `json:"id"`
+	Action   string    `json:"action"`
+	Exchange string    `json:"exchange"`
+	Replica  int       `json:"replica"`
+	Symbols  []string  `json:"symbols,omitempty"`
+	OK       bool      `json:"ok"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// SendCommand - публикует команду в обменник коннектора и ждет подтверждений от всех его реплик
+func SendCommand(c config.Connector, cmd Command, timeout time.Duration) ([]CommandAck, error) {
+	if cmd.ID == "" {
+		cmd.ID = strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	conn, err := amqp.Dial(c.RabbitMQURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect to RabbitMQ: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("open channel: %w", err)
+	}
+	defer ch.Close()
+
+	exchange := c.ControlExchange()
+	ackExchange := exchange + "_ack"
+	for _, name := range []string{exchange, ackExchange} {
+		if err := ch.ExchangeDeclare(name, "fanout", true, false, false, false, nil); err != nil {
+			return nil, fmt.Errorf("declare exchange %s: %w", name, err)
+		}
+	}
+
+	// временная очередь подтверждений, удаляется при закрытии соединения
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("declare ack queue: %w", err)
+	}
+	if err := ch.QueueBind(q.Name, "", ackExchange, false, nil); err != nil {
+		return nil, fmt.Errorf("bind ack queue: %w", err)
+	}
+
+	deliveries, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("consume ack queue: %w", err)
+	}
+
+	body, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, err
+	}
+	err = ch.Publish(exchange, "", false, false, amqp.Publishing{
+		ContentType:   "application/json",
+		CorrelationId: cmd.ID,
+		Body:          body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("publish command: %w", err)
+	}
+
+	replicas := len(c.ReplicaNames())
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var acks []CommandAck
+	for len(acks) < replicas {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				return acks, fmt.Errorf("ack channel closed")
+			}
+			if d.CorrelationId != cmd.ID {
+				continue
+			}
+
+			var ack CommandAck
+			if err := json.Unmarshal(d.Body, &ack); err != nil {
+				return acks, fmt.Errorf("decode ack: %w", err)
+			}
+			acks = append(acks, ack)
+		case <-timer.C:
+			return acks, fmt.Errorf("timeout: received %d of %d acks", len(acks), replicas)
+		}
+	}
+
+	return acks, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -159,16 +160,29 @@ func StartConnectorAndPreprocessor(c config.Connector, p config.Preprocessor, ne
 		return fmt.Errorf("RabbitMQ недоступен: %v", err)
 	}
 
-	err := StartService(c.Name, c.Image, network, map[string]string{
-		"EXCHANGE":     c.Exchange,
-		"QUEUE":        c.Queue,
-		"RABBITMQ_URL": c.RabbitMQURL,
-	})
-	if err != nil {
-		return err
+	replicas := c.ReplicaNames()
+	for i, name := range replicas {
+		env := map[string]string{
+			"EXCHANGE":      c.Exchange,
+			"QUEUE":         c.Queue,
+			"RABBITMQ_URL":  c.RabbitMQURL,
+			"REPLICA_INDEX": strconv.Itoa(i),
+			"REPLICA_COUNT": strconv.Itoa(len(replicas)),
+		}
+		for key, value := range c.Env {
+			env[key] = value
+		}
+
+		if err := StartService(name, c.Image, network, env); err != nil {
+			return err
+		}
 	}
 
-	err = StartService(p.Name, p.Image, network, map[string]string{
+	return startPreprocessor(p, network)
+}
+
+func startPreprocessor(p config.Preprocessor, network string) error {
+	err := StartService(p.Name, p.Image, network, map[string]string{
 		"QUEUE":        p.Queue,
 		"EXCHANGE":     p.Exchange,
 		"RABBITMQ_URL": p.RabbitMQURL,
@@ -181,10 +195,20 @@ func StartConnectorAndPreprocessor(c config.Connector, p config.Preprocessor, ne
 	return nil
 }
 
+// Запуск preprocessor без коннектора: его очередь наполняется, например, командой connector backfill
+func StartStandalonePreprocessor(p config.Preprocessor, network string) error {
+	if err := waitForRabbitMQ("rabbitmq", "5672", 30*time.Second); err != nil {
+		return fmt.Errorf("RabbitMQ недоступен: %v", err)
+	}
+	return startPreprocessor(p, network)
+}
+
 // Остановка связки connector + preprocessor
 func StopConnectorAndPreprocessor(c config.Connector, p config.Preprocessor) error {
-	if err := StopService(c.Name); err != nil {
-		return err
+	for _, name := range c.ReplicaNames() {
+		if err := StopService(name); err != nil {
+			return err
+		}
 	}
 	if err := StopService(p.Name); err != nil {
 		return err
@@ -210,6 +234,16 @@ func UpdateServices(newConfig config.Config) {
 		}
 	}
 
+	for _, p := range newConfig.Preprocessors {
+		if newConfig.HasConnector(p.Queue) {
+			continue
+		}
+		current[p.Name] = true
+		if !runningConnectors[p.Name] {
+			go StartStandalonePreprocessor(p, newConfig.Network)
+		}
+	}
+
 	// TODO: остановка сервисов, которых больше нет в конфиге - починить!
 	// Остановка сервисов, которых больше нет в конфиге
 	// for name := range runningConnectors {
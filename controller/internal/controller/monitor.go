@@ -1,6 +1,98 @@
 package controller
 
-func MonitorConnectors() {
-	// cli, _ := client.NewClientWithOpts(client.FromEnv)
-	// TODO: Добавить мониторинг коннекторов
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// StatusExchange - topic-обменник, в который коннекторы публикуют события жизненного цикла
+const StatusExchange = "connector_status"
+
+// ConnectorStatus - последнее событие реплики коннектора
+type ConnectorStatus struct {
+	Exchange string    `json:"exchange"`
+	Replica  int       `json:"replica"`
+	Event    string    `json:"event"`
+	Detail   string    `json:"detail,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type statusKey struct {
+	exchange string
+	replica  int
+}
+
+var (
+	statusMu sync.RWMutex
+	statuses = make(map[statusKey]ConnectorStatus)
+)
+
+// MonitorConnectors - читает события коннекторов из обменника статусов и хранит последнее для каждой реплики
+func MonitorConnectors(rabbitMQURL string) error {
+	conn, err := amqp.Dial(rabbitMQURL)
+	if err != nil {
+		return fmt.Errorf("connect to RabbitMQ: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("open channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := ch.ExchangeDeclare(StatusExchange, "topic", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare exchange: %w", err)
+	}
+
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return fmt.Errorf("declare status queue: %w", err)
+	}
+	if err := ch.QueueBind(q.Name, "#", StatusExchange, false, nil); err != nil {
+		return fmt.Errorf("bind status queue: %w", err)
+	}
+
+	deliveries, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume status queue: %w", err)
+	}
+
+	for d := range deliveries {
+		var st ConnectorStatus
+		if err := json.Unmarshal(d.Body, &st); err != nil {
+			log.Println("Ошибка разбора события коннектора", err)
+			continue
+		}
+
+		statusMu.Lock()
+		statuses[statusKey{st.Exchange, st.Replica}] = st
+		statusMu.Unlock()
+	}
+
+	return fmt.Errorf("status channel closed")
+}
+
+// Statuses - последние события всех известных реплик коннекторов
+func Statuses() []ConnectorStatus {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+
+	list := make([]ConnectorStatus, 0, len(statuses))
+	for _, st := range statuses {
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Exchange != list[j].Exchange {
+			return list[i].Exchange < list[j].Exchange
+		}
+		return list[i].Replica < list[j].Replica
+	})
+	return list
 }
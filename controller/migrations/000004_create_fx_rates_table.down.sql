@@ -0,0 +1 @@
+DROP TABLE fx_rates;
\ No newline at end of file
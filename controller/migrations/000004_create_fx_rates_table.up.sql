@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS fx_rates (
+    id BIGSERIAL PRIMARY KEY,
+    base VARCHAR(20),
+    quote VARCHAR(20),
+    rate NUMERIC,
+    source VARCHAR(50),
+    timestamp TIMESTAMP,
+    UNIQUE (base, quote, source, timestamp)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fx_rates_pair_timestamp ON fx_rates (base, quote, timestamp);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE instruments;
\ No newline at end of file
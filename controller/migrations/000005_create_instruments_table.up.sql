@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS instruments (
+    ticker_id BIGINT PRIMARY KEY REFERENCES tickers(id) ON DELETE CASCADE,
+    base VARCHAR(20),
+    quote VARCHAR(20),
+    tick_size NUMERIC,
+    lot_size NUMERIC,
+    min_notional NUMERIC,
+    status VARCHAR(50),
+    listing_time TIMESTAMP,
+    updated_at TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE historical_data DROP CONSTRAINT IF EXISTS historical_data_ticker_id_timeframe_timestamp_key;
+DELETE FROM historical_data WHERE timeframe <> '1m';
+ALTER TABLE historical_data ADD CONSTRAINT historical_data_ticker_id_timestamp_key UNIQUE (ticker_id, timestamp);
+
+ALTER TABLE historical_data DROP COLUMN timeframe;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE historical_data ADD COLUMN IF NOT EXISTS timeframe VARCHAR(10) NOT NULL DEFAULT '1m';
+
+ALTER TABLE historical_data DROP CONSTRAINT IF EXISTS historical_data_ticker_id_timestamp_key;
+ALTER TABLE historical_data ADD CONSTRAINT historical_data_ticker_id_timeframe_timestamp_key UNIQUE (ticker_id, timeframe, timestamp);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE options_data;
\ No newline at end of file
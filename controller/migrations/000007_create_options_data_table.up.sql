@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS options_data (
+    id BIGSERIAL PRIMARY KEY,
+    ticker_id BIGINT,
+    underlying VARCHAR(20),
+    expiration TIMESTAMP,
+    strike NUMERIC,
+    option_type VARCHAR(4),
+    mark_price NUMERIC,
+    mark_iv NUMERIC,
+    bid_iv NUMERIC,
+    ask_iv NUMERIC,
+    best_bid_price NUMERIC,
+    best_ask_price NUMERIC,
+    underlying_price NUMERIC,
+    open_interest NUMERIC,
+    delta NUMERIC,
+    gamma NUMERIC,
+    vega NUMERIC,
+    theta NUMERIC,
+    rho NUMERIC,
+    timestamp TIMESTAMP,
+    FOREIGN KEY (ticker_id) REFERENCES tickers(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_options_data_ticker_timestamp ON options_data (ticker_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_options_data_underlying_expiration ON options_data (underlying, expiration);
\ No newline at end of file
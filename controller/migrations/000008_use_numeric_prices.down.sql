@@ -0,0 +1,14 @@
+ALTER TABLE instruments DROP COLUMN IF EXISTS price_scale;
+
+ALTER TABLE historical_data
+    ALTER COLUMN open TYPE BIGINT USING round(open * 1000),
+    ALTER COLUMN high TYPE BIGINT USING round(high * 1000),
+    ALTER COLUMN low TYPE BIGINT USING round(low * 1000),
+    ALTER COLUMN close TYPE BIGINT USING round(close * 1000),
+    ALTER COLUMN volume TYPE BIGINT USING round(volume * 1000);
+
+ALTER TABLE market_data
+    ALTER COLUMN price TYPE BIGINT USING round(price * 1000),
+    ALTER COLUMN volume TYPE BIGINT USING round(volume * 1000),
+    ALTER COLUMN high_price TYPE BIGINT USING round(high_price * 1000),
+    ALTER COLUMN low_price TYPE BIGINT USING round(low_price * 1000);
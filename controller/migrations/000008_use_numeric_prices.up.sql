@@ -0,0 +1,18 @@
+-- цены и объемы хранились как значение * 1000 в BIGINT; существующие строки переводятся в точные значения
+ALTER TABLE market_data
+    ALTER COLUMN price TYPE NUMERIC USING trim_scale(price::numeric / 1000),
+    ALTER COLUMN volume TYPE NUMERIC USING trim_scale(volume::numeric / 1000),
+    ALTER COLUMN high_price TYPE NUMERIC USING trim_scale(high_price::numeric / 1000),
+    ALTER COLUMN low_price TYPE NUMERIC USING trim_scale(low_price::numeric / 1000);
+
+ALTER TABLE historical_data
+    ALTER COLUMN open TYPE NUMERIC USING trim_scale(open::numeric / 1000),
+    ALTER COLUMN high TYPE NUMERIC USING trim_scale(high::numeric / 1000),
+    ALTER COLUMN low TYPE NUMERIC USING trim_scale(low::numeric / 1000),
+    ALTER COLUMN close TYPE NUMERIC USING trim_scale(close::numeric / 1000),
+    ALTER COLUMN volume TYPE NUMERIC USING trim_scale(volume::numeric / 1000);
+
+-- price_scale - число знаков после запятой в цене инструмента, выводится из шага цены
+ALTER TABLE instruments ADD COLUMN IF NOT EXISTS price_scale SMALLINT;
+
+UPDATE instruments SET price_scale = scale(trim_scale(tick_size)) WHERE tick_size IS NOT NULL;
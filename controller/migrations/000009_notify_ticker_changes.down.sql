@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS instruments_deactivated ON instruments;
+DROP TRIGGER IF EXISTS tickers_changed ON tickers;
+DROP FUNCTION IF EXISTS notify_ticker_changed();
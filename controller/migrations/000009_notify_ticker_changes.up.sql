@@ -0,0 +1,22 @@
+-- уведомления для кэша ID тикеров в препроцессорах: тикер переименован, удален или его инструмент деактивирован
+CREATE OR REPLACE FUNCTION notify_ticker_changed() RETURNS trigger AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'instruments' THEN
+        PERFORM pg_notify('tickers_changed', NEW.ticker_id::text);
+        RETURN NEW;
+    END IF;
+
+    PERFORM pg_notify('tickers_changed', OLD.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tickers_changed
+    AFTER UPDATE OF exchange, symbol, market OR DELETE ON tickers
+    FOR EACH ROW EXECUTE FUNCTION notify_ticker_changed();
+
+CREATE TRIGGER instruments_deactivated
+    AFTER UPDATE OF status ON instruments
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status AND NEW.status <> 'active')
+    EXECUTE FUNCTION notify_ticker_changed();
@@ -0,0 +1 @@
+DROP TABLE open_candles;
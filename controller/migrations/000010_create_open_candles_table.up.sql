@@ -0,0 +1,13 @@
+-- незакрытые свечи препроцессора, чтобы агрегация переживала перезапуск
+CREATE TABLE IF NOT EXISTS open_candles (
+    ticker_id BIGINT REFERENCES tickers(id) ON DELETE CASCADE,
+    timeframe VARCHAR(10),
+    timestamp TIMESTAMP,
+    open NUMERIC,
+    high NUMERIC,
+    low NUMERIC,
+    close NUMERIC,
+    volume NUMERIC,
+    updated_at TIMESTAMP,
+    PRIMARY KEY (ticker_id, timeframe, timestamp)
+);
@@ -0,0 +1,16 @@
+-- тики, не прошедшие проверку качества: reject - не сохранены в market_data, flag - сохранены с пометкой.
+-- Тикер хранится строками, чтобы ошибочные символы не создавали записей в tickers.
+CREATE TABLE IF NOT EXISTS quarantine (
+    id BIGSERIAL PRIMARY KEY,
+    exchange VARCHAR(50) NOT NULL,
+    symbol VARCHAR(50) NOT NULL,
+    market VARCHAR(50) NOT NULL,
+    rule VARCHAR(50) NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    detail TEXT,
+    payload JSONB,
+    tick_time TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_quarantine_exchange_rule ON quarantine (exchange, rule, created_at);
@@ -0,0 +1,5 @@
+ALTER TABLE market_data
+    DROP COLUMN IF EXISTS quote_volume,
+    DROP COLUMN IF EXISTS vwap,
+    DROP COLUMN IF EXISTS price_change,
+    ALTER COLUMN price_change_percent TYPE VARCHAR(50) USING COALESCE(price_change_percent::text, 'nil');
@@ -0,0 +1,21 @@
+-- изменение за сутки хранилось строкой, для неизвестного значения - 'nil'; такие строки становятся NULL
+ALTER TABLE market_data
+    ALTER COLUMN price_change_percent TYPE NUMERIC USING (
+        CASE WHEN price_change_percent ~ '^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$'
+             THEN price_change_percent::numeric
+        END
+    ),
+    ADD COLUMN IF NOT EXISTS price_change NUMERIC,
+    ADD COLUMN IF NOT EXISTS vwap NUMERIC,
+    ADD COLUMN IF NOT EXISTS quote_volume NUMERIC;
+
+-- Bybit присылал изменение долей, а не процентами
+UPDATE market_data m
+SET price_change_percent = price_change_percent * 100
+FROM tickers t
+WHERE t.id = m.ticker_id AND t.exchange = 'bybit' AND m.price_change_percent IS NOT NULL;
+
+-- абсолютное изменение старых строк выводится из процента: price - price / (1 + percent / 100)
+UPDATE market_data
+SET price_change = trim_scale(round(price - price / (1 + price_change_percent / 100), scale(price) + 2))
+WHERE price_change_percent IS NOT NULL AND price_change_percent > -100 AND price IS NOT NULL;
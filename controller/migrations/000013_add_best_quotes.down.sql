@@ -0,0 +1,6 @@
+ALTER TABLE market_data
+    DROP COLUMN IF EXISTS spread_bps,
+    DROP COLUMN IF EXISTS ask_size,
+    DROP COLUMN IF EXISTS ask,
+    DROP COLUMN IF EXISTS bid_size,
+    DROP COLUMN IF EXISTS bid;
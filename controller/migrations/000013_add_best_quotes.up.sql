@@ -0,0 +1,7 @@
+-- лучшие цены и объемы заявок на момент тика; spread_bps - (ask - bid) / mid в базисных пунктах
+ALTER TABLE market_data
+    ADD COLUMN IF NOT EXISTS bid NUMERIC,
+    ADD COLUMN IF NOT EXISTS bid_size NUMERIC,
+    ADD COLUMN IF NOT EXISTS ask NUMERIC,
+    ADD COLUMN IF NOT EXISTS ask_size NUMERIC,
+    ADD COLUMN IF NOT EXISTS spread_bps NUMERIC;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_tickers_pair;
+
+ALTER TABLE tickers
+    DROP COLUMN IF EXISTS quote_asset,
+    DROP COLUMN IF EXISTS base_asset;
+
+DROP TABLE asset_aliases;
+DROP TABLE assets;
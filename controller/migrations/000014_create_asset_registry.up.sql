@@ -0,0 +1,55 @@
+-- реестр активов: один актив торгуется на разных биржах под разными символами (BTCUSDT, BTC-USDT, XBT/USDT).
+-- is_quote - валюта котировки; по таким кодам разбираются символы без разделителя
+CREATE TABLE IF NOT EXISTS assets (
+    code VARCHAR(20) PRIMARY KEY,
+    name VARCHAR(100),
+    is_quote BOOLEAN NOT NULL DEFAULT false
+);
+
+-- другие обозначения актива на биржах
+CREATE TABLE IF NOT EXISTS asset_aliases (
+    alias VARCHAR(20) PRIMARY KEY,
+    asset VARCHAR(20) NOT NULL REFERENCES assets(code) ON DELETE CASCADE
+);
+
+INSERT INTO assets (code, name, is_quote) VALUES
+    ('BTC', 'Bitcoin', true),
+    ('ETH', 'Ethereum', true),
+    ('BNB', 'BNB', true),
+    ('USDT', 'Tether', true),
+    ('USDC', 'USD Coin', true),
+    ('FDUSD', 'First Digital USD', true),
+    ('DAI', 'Dai', true),
+    ('USD', 'US Dollar', true),
+    ('EUR', 'Euro', true),
+    ('GBP', 'British Pound', true),
+    ('TRY', 'Turkish Lira', true),
+    ('SOL', 'Solana', false),
+    ('XRP', 'XRP', false),
+    ('DOGE', 'Dogecoin', false),
+    ('ADA', 'Cardano', false),
+    ('TON', 'Toncoin', false)
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO asset_aliases (alias, asset) VALUES
+    ('XBT', 'BTC'),
+    ('XDG', 'DOGE'),
+    -- токен пула DEX обменивается на ETH один к одному
+    ('WETH', 'ETH')
+ON CONFLICT (alias) DO NOTHING;
+
+-- каноническая пара тикера; NULL - символ не удалось разобрать
+ALTER TABLE tickers
+    ADD COLUMN IF NOT EXISTS base_asset VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS quote_asset VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS idx_tickers_pair ON tickers (base_asset, quote_asset);
+
+-- существующие тикеры получают пару из справочника инструментов
+UPDATE tickers t
+SET base_asset = COALESCE(b.asset, upper(i.base)),
+    quote_asset = COALESCE(q.asset, upper(i.quote))
+FROM instruments i
+LEFT JOIN asset_aliases b ON b.alias = upper(i.base)
+LEFT JOIN asset_aliases q ON q.alias = upper(i.quote)
+WHERE i.ticker_id = t.id AND i.base <> '' AND i.quote <> '';
@@ -0,0 +1,4 @@
+ALTER TABLE market_data
+    DROP COLUMN IF EXISTS usd_path,
+    DROP COLUMN IF EXISTS volume_usd,
+    DROP COLUMN IF EXISTS price_usd;
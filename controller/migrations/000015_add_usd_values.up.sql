@@ -0,0 +1,6 @@
+-- цена и суточный оборот тика в USD по последним курсам на момент записи;
+-- usd_path - цепочка курсов пересчета, например USDT/USD@coinbase или ETH/BTC@composite>BTC/USDT@binance>USDT/USD@coinbase
+ALTER TABLE market_data
+    ADD COLUMN IF NOT EXISTS price_usd NUMERIC,
+    ADD COLUMN IF NOT EXISTS volume_usd NUMERIC,
+    ADD COLUMN IF NOT EXISTS usd_path VARCHAR(200);
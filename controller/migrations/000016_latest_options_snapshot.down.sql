@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_options_data_ticker;
@@ -0,0 +1,8 @@
+-- опционы обновляются несколько раз в секунду, а cleaner очищает только market_data;
+-- в options_data хранится один последний снимок на опцион
+DELETE FROM options_data o
+USING options_data newer
+WHERE newer.ticker_id = o.ticker_id
+  AND (newer.timestamp > o.timestamp OR (newer.timestamp = o.timestamp AND newer.id > o.id));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_options_data_ticker ON options_data (ticker_id);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_quarantine_created_at;
@@ -0,0 +1,2 @@
+-- очиститель удаляет записи карантина старше срока хранения
+CREATE INDEX IF NOT EXISTS idx_quarantine_created_at ON quarantine (created_at);
@@ -0,0 +1 @@
+ALTER TABLE historical_data DROP COLUMN IF EXISTS source;
@@ -0,0 +1,3 @@
+-- свечи из дозагрузки истории полные, свечи из тиков могут быть собраны не с начала интервала;
+-- свеча из тиков не заменяет свечу биржи, а свеча биржи заменяет свечу из тиков
+ALTER TABLE historical_data ADD COLUMN IF NOT EXISTS source VARCHAR(10) NOT NULL DEFAULT 'backfill';
@@ -103,3 +103,11 @@ func TestUpdateServices(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+func TestConnectorReplicaNames(t *testing.T) {
+	single := config.Connector{Name: "okx-connector"}
+	require.Equal(t, []string{"okx-connector"}, single.ReplicaNames())
+
+	sharded := config.Connector{Name: "binance-connector", Replicas: 3}
+	require.Equal(t, []string{"binance-connector-0", "binance-connector-1", "binance-connector-2"}, sharded.ReplicaNames())
+}
@@ -1,7 +1,24 @@
 package main
 
-import "preprocessor/internal/app"
+import (
+	"log"
+	"os"
+
+	"preprocessor/internal/app"
+	"preprocessor/internal/deadletter"
+)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "deadletter" {
+		if err := deadletter.Run(os.Args[2:]); err != nil {
+			log.Fatalf("deadletter: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "composite" {
+		app.RunComposite()
+		return
+	}
+
 	app.Run()
 }
@@ -1,14 +1,22 @@
 package app
 
 import (
+	"context"
 	"log"
 	"preprocessor/internal/config"
 	"preprocessor/internal/processor"
 	"preprocessor/internal/storage"
+	"time"
 )
 
 const initialWorkerCount = 4
 
+// tickerCacheLogInterval - как часто выводить счетчики кэша ID тикеров
+const tickerCacheLogInterval = 5 * time.Minute
+
+// qualityLogInterval - как часто выводить счетчики отклоненных и помеченных тиков, а также прореживания
+const qualityLogInterval = 5 * time.Minute
+
 func Run() {
 	cfg := config.LoadConfig()
 
@@ -18,6 +26,16 @@ func Run() {
 	}
 	defer storage.Close()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// без прогрева кэш заполняется по мере поступления тикеров
+	if err := storage.WarmTickerCache(cfg.Preprocessor.Exchange); err != nil {
+		log.Println("Ошибка прогрева кэша тикеров:", err)
+	}
+	go storage.WatchTickerChanges(ctx)
+	go logTickerCache(ctx, storage)
+
 	p, err := processor.NewProcessor(cfg, storage)
 	if err != nil {
 		log.Fatal("Ошибка создания процессора:", err)
@@ -30,5 +48,53 @@ func Run() {
 	}
 	log.Println("Подключение к RabbitMQ успешно")
 
-	p.ProcessMessages(initialWorkerCount)
+	// без реестра символы без разделителя не разбираются, остальные приводятся без синонимов
+	if err := p.LoadAssetRegistry(); err != nil {
+		log.Println("Ошибка загрузки реестра активов:", err)
+	}
+
+	go p.RunCandles(ctx)
+	go p.RunAutoscaler(ctx)
+	go p.RunUsdRates(ctx)
+	go p.RunConflation(ctx)
+	go logQuality(ctx, p)
+
+	// дальше число воркеров меняет автомасштабирование
+	workers := min(max(initialWorkerCount, cfg.Preprocessor.MinWorkers), cfg.Preprocessor.MaxWorkers)
+	p.ProcessMessages(max(workers, 1))
+}
+
+func logTickerCache(ctx context.Context, db *storage.Storage) {
+	ticker := time.NewTicker(tickerCacheLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := db.TickerCacheStats()
+			log.Printf("Кэш тикеров: %d записей, попаданий %d, промахов %d", stats.Size, stats.Hits, stats.Misses)
+		}
+	}
+}
+
+// logQuality - периодически выводит счетчики правил проверки тиков и прореживания записи
+func logQuality(ctx context.Context, p *processor.Processor) {
+	ticker := time.NewTicker(qualityLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := p.QualityStats()
+			if len(stats.Rejected) > 0 || len(stats.Flagged) > 0 {
+				log.Printf("Проверка тиков: отклонено %v, помечено %v", stats.Rejected, stats.Flagged)
+			}
+			conflation := p.ConflationStats()
+			log.Printf("Прореживание тиков: записано %d, отброшено %d", conflation.Written, conflation.Dropped)
+		}
+	}
 }
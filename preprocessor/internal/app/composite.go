@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"preprocessor/internal/config"
+	"preprocessor/internal/processor"
+	"preprocessor/internal/storage"
+	"syscall"
+)
+
+// RunComposite - расчет композитных цен пар по последним тикам всех бирж. Процессоры работают
+// каждый со своей биржей, поэтому композитная цена считается одним отдельным процессом.
+func RunComposite() {
+	cfg := config.LoadConfig()
+
+	spec, err := processor.LoadCompositeSpec(cfg.Composite.Spec)
+	if err != nil {
+		log.Fatal("Ошибка загрузки параметров композитной цены:", err)
+	}
+
+	storage, err := storage.NewStorage(cfg.Database.URL)
+	if err != nil {
+		log.Fatal("Ошибка подключения к DB:", err)
+	}
+	defer storage.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// курсы USD обновляются так же, как в процессорах бирж
+	usd := processor.NewUsdConverter()
+	go usd.Run(ctx, storage, cfg.Preprocessor.UsdRatesInterval, cfg.Preprocessor.UsdFxMaxAge)
+
+	log.Printf("Композитные цены: метод %s, пересчет каждые %s", spec.Method, cfg.Composite.Interval)
+	processor.NewCompositeIndex(storage, spec, cfg.Preprocessor.CandleGrace, usd).Run(ctx, cfg.Composite.Interval)
+}
@@ -1,11 +1,17 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 type RabbitMQConfig struct {
 	URL string
+	// Prefetch - сколько неподтвержденных сообщений брокер отдает процессору; общий лимит всех
+	// воркеров, поэтому должен вмещать пачки BatchSize всех MaxWorkers воркеров
+	Prefetch int
 }
 
 type DBConfig struct {
@@ -15,27 +21,108 @@ type DBConfig struct {
 type PreprocessorConfig struct {
 	Exchange string
 	Queue    string
+	// MaxRetries - число повторов сообщения после ошибки записи, затем оно уходит в dead-letter очередь
+	MaxRetries int
+	RetryDelay time.Duration
+	// BatchSize и FlushInterval - пороги записи накопленных тикеров воркера одной пачкой
+	BatchSize     int
+	FlushInterval time.Duration
+	// CandleGrace - сколько после конца интервала свеча принимает опоздавшие тики
+	CandleGrace time.Duration
+	// MinWorkers и MaxWorkers - границы автомасштабирования воркеров, ScaleInterval - период
+	// замера очереди; 0 отключает автомасштабирование
+	MinWorkers    int
+	MaxWorkers    int
+	ScaleInterval time.Duration
+	// QualityRules - файл правил проверки тиков, пустой путь отключает проверку
+	QualityRules string
+	// UsdRatesInterval - период обновления курсов пересчета цен в USD, 0 отключает пересчет;
+	// UsdFxMaxAge - курсы валют старше не используются; центральные банки не публикуют курсы в выходные
+	UsdRatesInterval time.Duration
+	UsdFxMaxAge      time.Duration
+	// ConflationRules - файл правил прореживания записи тиков, пустой путь отключает прореживание
+	ConflationRules string
+}
+
+// CompositeConfig - параметры команды preprocessor composite
+type CompositeConfig struct {
+	// Spec - файл с методом расчета и сроками свежести котировок бирж
+	Spec     string
+	Interval time.Duration
 }
 
 type Config struct {
 	RabbitMQ     RabbitMQConfig
 	Database     DBConfig
 	Preprocessor PreprocessorConfig
+	Composite    CompositeConfig
 }
 
 func LoadConfig() *Config {
 	cfg := Config{
 		RabbitMQ: RabbitMQConfig{
-			URL: os.Getenv("RABBITMQ_URL"),
+			URL:      os.Getenv("RABBITMQ_URL"),
+			Prefetch: intEnv("PREFETCH", 2000),
 		},
 		Database: DBConfig{
 			URL: os.Getenv("DATABASE_URL"),
 		},
 		Preprocessor: PreprocessorConfig{
-			Exchange: os.Getenv("EXCHANGE"),
-			Queue:    os.Getenv("QUEUE"),
+			Exchange:         os.Getenv("EXCHANGE"),
+			Queue:            os.Getenv("QUEUE"),
+			MaxRetries:       intEnv("MAX_RETRIES", 5),
+			RetryDelay:       durationEnv("RETRY_DELAY", time.Second),
+			BatchSize:        intEnv("BATCH_SIZE", 100),
+			FlushInterval:    durationEnv("FLUSH_INTERVAL", time.Second),
+			CandleGrace:      durationEnv("CANDLE_GRACE", 10*time.Second),
+			MinWorkers:       intEnv("MIN_WORKERS", 1),
+			MaxWorkers:       intEnv("MAX_WORKERS", 16),
+			ScaleInterval:    durationEnv("SCALE_INTERVAL", 10*time.Second),
+			QualityRules:     stringEnv("QUALITY_RULES", "specs/quality_rules.yaml"),
+			UsdRatesInterval: durationEnv("USD_RATES_INTERVAL", 30*time.Second),
+			UsdFxMaxAge:      durationEnv("USD_FX_MAX_AGE", 96*time.Hour),
+			ConflationRules:  stringEnv("CONFLATION_RULES", "specs/conflation.yaml"),
+		},
+		Composite: CompositeConfig{
+			Spec:     stringEnv("COMPOSITE_SPEC", "specs/composite.yaml"),
+			Interval: durationEnv("COMPOSITE_INTERVAL", 5*time.Second),
 		},
 	}
 
+	cfg.clampBatchSize()
 	return &cfg
 }
+
+// clampBatchSize - неподтвержденные сообщения делятся между воркерами, и пачка больше их доли
+// не набирается никогда: запись шла бы только по FlushInterval
+func (c *Config) clampBatchSize() {
+	limit := max(c.RabbitMQ.Prefetch/max(c.Preprocessor.MaxWorkers, 1), 1)
+	if c.Preprocessor.BatchSize > limit {
+		log.Printf("BATCH_SIZE %d больше доли PREFETCH %d на %d воркеров, используется %d",
+			c.Preprocessor.BatchSize, c.RabbitMQ.Prefetch, c.Preprocessor.MaxWorkers, limit)
+		c.Preprocessor.BatchSize = limit
+	}
+}
+
+func stringEnv(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+func intEnv(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v < 0 {
+		return def
+	}
+	return v
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
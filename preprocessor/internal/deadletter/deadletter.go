@@ -0,0 +1,129 @@
+package deadletter
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"preprocessor/internal/processor"
+
+	"github.com/streadway/amqp"
+)
+
+// Config - параметры команды preprocessor deadletter
+type Config struct {
+	Action      string // list или redrive
+	Queue       string
+	Limit       int
+	RabbitMQURL string
+}
+
+func parseFlags(args []string) (Config, error) {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "redrive") {
+		return Config{}, fmt.Errorf("usage: deadletter list|redrive [--queue name] [--limit n]")
+	}
+
+	fs := flag.NewFlagSet("deadletter "+args[0], flag.ContinueOnError)
+	queue := fs.String("queue", os.Getenv("QUEUE"), "main queue whose dead letters are processed")
+	limit := fs.Int("limit", 100, "maximum number of messages")
+	if err := fs.Parse(args[1:]); err != nil {
+		return Config{}, err
+	}
+	if *queue == "" {
+		return Config{}, fmt.Errorf("--queue is required")
+	}
+	if *limit <= 0 {
+		return Config{}, fmt.Errorf("--limit must be positive")
+	}
+
+	return Config{Action: args[0], Queue: *queue, Limit: *limit, RabbitMQURL: os.Getenv("RABBITMQ_URL")}, nil
+}
+
+// Run - выводит сообщения dead-letter очереди или возвращает их в основную очередь
+func Run(args []string) error {
+	cfg, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	conn, err := amqp.Dial(cfg.RabbitMQURL)
+	if err != nil {
+		return fmt.Errorf("connect to RabbitMQ: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("open channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := processor.DeclareDeadLetter(ch, cfg.Queue); err != nil {
+		return err
+	}
+
+	if cfg.Action == "list" {
+		return list(ch, cfg)
+	}
+	return redrive(ch, cfg)
+}
+
+// list - читает сообщения без подтверждения, после закрытия канала брокер возвращает их в очередь
+func list(ch *amqp.Channel, cfg Config) error {
+	for i := 0; i < cfg.Limit; i++ {
+		msg, ok, err := ch.Get(processor.DeadLetterQueue(cfg.Queue), false)
+		if err != nil {
+			return fmt.Errorf("get message: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		fmt.Printf("type=%q retries=%d failed_at=%v reason=%v\n%s\n\n",
+			msg.Type, processor.RetryCount(msg), msg.Headers[processor.HeaderFailedAt], msg.Headers[processor.HeaderFailureReason], msg.Body)
+	}
+	return nil
+}
+
+// redrive - публикует сообщения в основную очередь со сброшенным счетчиком повторов
+func redrive(ch *amqp.Channel, cfg Config) error {
+	redriven := 0
+	for ; redriven < cfg.Limit; redriven++ {
+		msg, ok, err := ch.Get(processor.DeadLetterQueue(cfg.Queue), false)
+		if err != nil {
+			return fmt.Errorf("get message: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for k, v := range msg.Headers {
+			switch k {
+			case processor.HeaderRetryCount, processor.HeaderFailureReason, processor.HeaderFailedAt, processor.HeaderOriginalQueue:
+			default:
+				headers[k] = v
+			}
+		}
+
+		err = ch.Publish("", cfg.Queue, false, false, amqp.Publishing{
+			Headers:      headers,
+			ContentType:  msg.ContentType,
+			DeliveryMode: amqp.Persistent,
+			Type:         msg.Type,
+			Timestamp:    msg.Timestamp,
+			Body:         msg.Body,
+		})
+		if err != nil {
+			msg.Nack(false, true)
+			return fmt.Errorf("publish to %s: %w", cfg.Queue, err)
+		}
+		if err := msg.Ack(false); err != nil {
+			return fmt.Errorf("ack: %w", err)
+		}
+	}
+
+	log.Printf("deadletter: %d messages returned to %s", redriven, cfg.Queue)
+	return nil
+}
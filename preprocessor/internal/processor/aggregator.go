@@ -0,0 +1,298 @@
+package processor
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"preprocessor/internal/storage"
+	"sync"
+	"time"
+)
+
+// candleCheckpointInterval - как часто незакрытые свечи сохраняются для восстановления после перезапуска
+const candleCheckpointInterval = 30 * time.Second
+
+// candleTimeframes - интервалы свечей, которые собираются из тиков
+var candleTimeframes = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+func timeframeDuration(name string) (time.Duration, bool) {
+	for _, tf := range candleTimeframes {
+		if tf.name == name {
+			return tf.duration, true
+		}
+	}
+	return 0, false
+}
+
+type candleKey struct {
+	exchange, symbol, market string
+	timeframe                string
+	start                    time.Time
+}
+
+type instrumentKey struct {
+	exchange, symbol, market string
+}
+
+// liveCandle - свеча, в которую еще могут попасть тики. Открытие и закрытие
+// определяются по времени тика, чтобы опоздавший тик не сдвигал цену закрытия.
+type liveCandle struct {
+	end                    time.Time
+	open, high, low, close *big.Rat
+	volume                 *big.Rat
+	firstAt, lastAt        time.Time
+}
+
+// CandleAggregator - собирает свечи из сохраненных тиков. Свеча закрывается, когда
+// после конца ее интервала прошло grace - до этого в нее принимаются опоздавшие тики.
+type CandleAggregator struct {
+	grace time.Duration
+
+	mu      sync.Mutex
+	candles map[candleKey]*liveCandle
+	// volumes - последний суточный объем тикера; объем свечи - сумма его приростов
+	volumes map[instrumentKey]*big.Rat
+	dropped int
+}
+
+func NewCandleAggregator(grace time.Duration) *CandleAggregator {
+	return &CandleAggregator{
+		grace:   grace,
+		candles: make(map[candleKey]*liveCandle),
+		volumes: make(map[instrumentKey]*big.Rat),
+	}
+}
+
+// Add - учитывает тик во всех интервалах; тик старше окна опоздания отбрасывается
+func (a *CandleAggregator) Add(tick storage.MarketData, now time.Time) {
+	_, price, err := parseDecimal(tick.Price)
+	if err != nil {
+		return
+	}
+	_, volume, err := parseDecimal(tick.Volume)
+	if err != nil {
+		volume = new(big.Rat)
+	}
+	at := tick.Timestamp
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// суточный объем скользящий и может уменьшаться, в свечу идут только приросты
+	inst := instrumentKey{tick.Exchange, tick.Symbol, tick.Market}
+	delta := new(big.Rat)
+	if last, ok := a.volumes[inst]; ok && volume.Cmp(last) > 0 {
+		delta.Sub(volume, last)
+	}
+	a.volumes[inst] = volume
+
+	for _, tf := range candleTimeframes {
+		start := at.Truncate(tf.duration)
+		end := start.Add(tf.duration)
+		if !end.Add(a.grace).After(now) {
+			a.dropped++
+			continue
+		}
+
+		key := candleKey{tick.Exchange, tick.Symbol, tick.Market, tf.name, start}
+		c, ok := a.candles[key]
+		if !ok {
+			a.candles[key] = &liveCandle{
+				end:     end,
+				open:    price,
+				high:    price,
+				low:     price,
+				close:   price,
+				volume:  new(big.Rat).Set(delta),
+				firstAt: at,
+				lastAt:  at,
+			}
+			continue
+		}
+
+		if price.Cmp(c.high) > 0 {
+			c.high = price
+		}
+		if price.Cmp(c.low) < 0 {
+			c.low = price
+		}
+		if at.Before(c.firstAt) {
+			c.open, c.firstAt = price, at
+		}
+		if !at.Before(c.lastAt) {
+			c.close, c.lastAt = price, at
+		}
+		c.volume.Add(c.volume, delta)
+	}
+}
+
+// CloseDue - забирает свечи, окно опоздания которых истекло
+func (a *CandleAggregator) CloseDue(now time.Time) []storage.HistoricalData {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var closed []storage.HistoricalData
+	for key, c := range a.candles {
+		if c.end.Add(a.grace).After(now) {
+			continue
+		}
+		closed = append(closed, c.historical(key))
+		delete(a.candles, key)
+	}
+	return closed
+}
+
+// takeDropped - число отброшенных опоздавших тиков с прошлого вызова
+func (a *CandleAggregator) takeDropped() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := a.dropped
+	a.dropped = 0
+	return n
+}
+
+// snapshot - копия незакрытых свечей для сохранения
+func (a *CandleAggregator) snapshot() []storage.HistoricalData {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	candles := make([]storage.HistoricalData, 0, len(a.candles))
+	for key, c := range a.candles {
+		candles = append(candles, c.historical(key))
+	}
+	return candles
+}
+
+// restore - возвращает в агрегатор свечи, которые не удалось сохранить или загруженные после перезапуска;
+// если свеча за тот же интервал уже собирается заново, значения объединяются
+func (a *CandleAggregator) restore(candles []storage.HistoricalData) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, h := range candles {
+		d, ok := timeframeDuration(h.Timeframe)
+		if !ok {
+			continue
+		}
+		values, err := parseDecimals(h.Open, h.High, h.Low, h.Close, h.Volume)
+		if err != nil {
+			log.Printf("Некорректная незакрытая свеча %s %s %s: %s", h.Symbol, h.Timeframe, h.Timestamp, err)
+			continue
+		}
+
+		key := candleKey{h.Exchange, h.Symbol, h.Market, h.Timeframe, h.Timestamp}
+		restored := &liveCandle{
+			end:    h.Timestamp.Add(d),
+			open:   values[0],
+			high:   values[1],
+			low:    values[2],
+			close:  values[3],
+			volume: values[4],
+			// точное время крайних тиков не сохраняется, сохраненные цены считаются самыми ранними и поздними
+			firstAt: h.Timestamp,
+			lastAt:  h.Timestamp,
+		}
+
+		c, ok := a.candles[key]
+		if !ok {
+			a.candles[key] = restored
+			continue
+		}
+		c.open = restored.open
+		if restored.high.Cmp(c.high) > 0 {
+			c.high = restored.high
+		}
+		if restored.low.Cmp(c.low) < 0 {
+			c.low = restored.low
+		}
+		c.volume.Add(c.volume, restored.volume)
+	}
+}
+
+func parseDecimals(values ...string) ([]*big.Rat, error) {
+	out := make([]*big.Rat, len(values))
+	for i, s := range values {
+		_, v, err := parseDecimal(s)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func (c *liveCandle) historical(key candleKey) storage.HistoricalData {
+	return storage.HistoricalData{
+		Exchange:  key.exchange,
+		Symbol:    key.symbol,
+		Market:    key.market,
+		Timeframe: key.timeframe,
+		Open:      ratString(c.open),
+		High:      ratString(c.high),
+		Low:       ratString(c.low),
+		Close:     ratString(c.close),
+		Volume:    ratString(c.volume),
+		Timestamp: key.start,
+	}
+}
+
+// ratString - значения получены из десятичных строк, поэтому 18 знаков после запятой хватает без округления
+func ratString(r *big.Rat) string {
+	return trimZeros(r.FloatString(18))
+}
+
+// RunCandles - восстанавливает незакрытые свечи биржи, затем закрывает их по истечении окна
+// опоздания и периодически сохраняет незакрытые
+func (p *Processor) RunCandles(ctx context.Context) {
+	runCandles(ctx, p.Db, p.Cfg.Preprocessor.Exchange, p.candles)
+}
+
+func runCandles(ctx context.Context, db *storage.Storage, exchange string, candles *CandleAggregator) {
+	restored, err := db.LoadOpenCandles(exchange)
+	if err != nil {
+		log.Printf("Ошибка загрузки незакрытых свечей: %s", err)
+	}
+	candles.restore(restored)
+	log.Printf("Восстановлено %d незакрытых свечей", len(restored))
+
+	closeTicker := time.NewTicker(time.Second)
+	defer closeTicker.Stop()
+	checkpointTicker := time.NewTicker(candleCheckpointInterval)
+	defer checkpointTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := db.SaveOpenCandles(candles.snapshot()); err != nil {
+				log.Printf("Ошибка сохранения незакрытых свечей: %s", err)
+			}
+			return
+		case now := <-closeTicker.C:
+			closed := candles.CloseDue(now)
+			if len(closed) == 0 {
+				continue
+			}
+			if err := db.SaveCandles(closed); err != nil {
+				// свечи возвращаются и сохраняются при следующей попытке
+				log.Printf("Ошибка сохранения свечей: %s", err)
+				candles.restore(closed)
+			}
+		case <-checkpointTicker.C:
+			if err := db.SaveOpenCandles(candles.snapshot()); err != nil {
+				log.Printf("Ошибка сохранения незакрытых свечей: %s", err)
+			}
+			if dropped := candles.takeDropped(); dropped > 0 {
+				log.Printf("Отброшено %d тиков позже окна опоздания свечей", dropped)
+			}
+		}
+	}
+}
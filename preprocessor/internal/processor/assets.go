@@ -0,0 +1,199 @@
+package processor
+
+import (
+	"log"
+	"preprocessor/internal/storage"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// symbolSeparators - разделители базового актива и котировки в символах бирж (BTC-USDT, BTC/USD, BTC_USDT)
+const symbolSeparators = "-/_"
+
+// AssetRegistry - приводит символы бирж к канонической паре базовый актив/котировка.
+// Состав пары берется из справочника инструментов биржи, иначе символ разбирается по разделителю
+// или по известным валютам котировки в конце символа, если остаток - известный актив;
+// обозначения активов заменяются по синонимам (XBT -> BTC).
+type AssetRegistry struct {
+	mu      sync.RWMutex
+	aliases map[string]string
+	// quotes - валюты котировки от длинных к коротким, чтобы USDT проверялась раньше USD
+	quotes []string
+	// assets - канонические коды известных активов: из реестра и из пар справочника инструментов
+	assets map[string]bool
+	// known - пары из справочника инструментов
+	known map[instrumentKey]storage.TickerPair
+}
+
+func NewAssetRegistry() *AssetRegistry {
+	return &AssetRegistry{
+		aliases: make(map[string]string),
+		assets:  make(map[string]bool),
+		known:   make(map[instrumentKey]storage.TickerPair),
+	}
+}
+
+// SetAssets - заменяет синонимы, валюты котировки и известные активы
+func (r *AssetRegistry) SetAssets(registry storage.AssetRegistry) {
+	quotes := make([]string, len(registry.Quotes))
+	for i, q := range registry.Quotes {
+		quotes[i] = strings.ToUpper(q)
+	}
+	sort.SliceStable(quotes, func(i, j int) bool { return len(quotes[i]) > len(quotes[j]) })
+
+	aliases := make(map[string]string, len(registry.Aliases))
+	for alias, asset := range registry.Aliases {
+		aliases[strings.ToUpper(alias)] = strings.ToUpper(asset)
+	}
+
+	assets := make(map[string]bool, len(registry.Assets)+len(registry.Quotes))
+	for _, asset := range registry.Assets {
+		assets[strings.ToUpper(asset)] = true
+	}
+	for _, asset := range quotes {
+		assets[asset] = true
+	}
+	for _, asset := range aliases {
+		assets[asset] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// активы пар, изученных из справочника инструментов, остаются известными
+	for _, pair := range r.known {
+		assets[pair.Base], assets[pair.Quote] = true, true
+	}
+	r.aliases, r.quotes, r.assets = aliases, quotes, assets
+}
+
+// Learn - запоминает пару инструмента из справочника биржи и возвращает ее в каноническом виде
+func (r *AssetRegistry) Learn(exchange, symbol, market, base, quote string) (storage.TickerPair, bool) {
+	if base == "" || quote == "" {
+		return storage.TickerPair{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pair := storage.TickerPair{
+		Exchange: exchange,
+		Symbol:   symbol,
+		Market:   market,
+		Base:     r.canonical(base),
+		Quote:    r.canonical(quote),
+	}
+	r.known[instrumentKey{exchange, symbol, market}] = pair
+	r.assets[pair.Base], r.assets[pair.Quote] = true, true
+	return pair, true
+}
+
+// Resolve - каноническая пара символа; false, если символ не удалось разобрать
+func (r *AssetRegistry) Resolve(exchange, symbol, market string) (storage.TickerPair, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if pair, ok := r.known[instrumentKey{exchange, symbol, market}]; ok {
+		return pair, true
+	}
+
+	base, quote, ok := r.split(strings.ToUpper(symbol))
+	if !ok {
+		return storage.TickerPair{}, false
+	}
+	return storage.TickerPair{
+		Exchange: exchange,
+		Symbol:   symbol,
+		Market:   market,
+		Base:     r.canonical(base),
+		Quote:    r.canonical(quote),
+	}, true
+}
+
+// split - делит символ по единственному разделителю, иначе отделяет известную валюту котировки с конца.
+// Без разделителя остаток должен быть известным активом: BTCTUSD не разбирается как BTCT/USD.
+func (r *AssetRegistry) split(symbol string) (string, string, bool) {
+	parts := strings.FieldsFunc(symbol, func(c rune) bool {
+		return strings.ContainsRune(symbolSeparators, c)
+	})
+	switch len(parts) {
+	case 2:
+		return parts[0], parts[1], true
+	case 1:
+	default:
+		// символы деривативов (BTC-27DEC24-100000-C) парой не являются
+		return "", "", false
+	}
+
+	for _, quote := range r.quotes {
+		if base, ok := strings.CutSuffix(symbol, quote); ok && r.isAsset(base) {
+			return base, quote, true
+		}
+		// котировка может быть записана синонимом, например XBT
+		for alias, asset := range r.aliases {
+			if asset != quote {
+				continue
+			}
+			if base, ok := strings.CutSuffix(symbol, alias); ok && r.isAsset(base) {
+				return base, alias, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func (r *AssetRegistry) isAsset(asset string) bool {
+	return asset != "" && r.assets[r.canonical(asset)]
+}
+
+func (r *AssetRegistry) canonical(asset string) string {
+	asset = strings.ToUpper(asset)
+	if canonical, ok := r.aliases[asset]; ok {
+		return canonical
+	}
+	return asset
+}
+
+// LoadAssetRegistry - загружает реестр активов из базы
+func (p *Processor) LoadAssetRegistry() error {
+	registry, err := p.Db.LoadAssetRegistry()
+	if err != nil {
+		return err
+	}
+	p.assets.SetAssets(registry)
+	log.Printf("Реестр активов: %d активов, %d валют котировки, %d синонимов",
+		len(registry.Assets), len(registry.Quotes), len(registry.Aliases))
+	return nil
+}
+
+// recordPairs - сохраняет канонические пары тикеров, для которых они еще не записаны
+func (w *Worker) recordPairs(rows []pendingRow) {
+	p := w.Processor
+	var pairs []storage.TickerPair
+
+	p.pairsMu.Lock()
+	for _, row := range rows {
+		key := instrumentKey{row.data.Exchange, row.data.Symbol, row.data.Market}
+		if p.pairsSaved[key] {
+			continue
+		}
+		pair, ok := p.assets.Resolve(key.exchange, key.symbol, key.market)
+		if !ok {
+			continue
+		}
+		p.pairsSaved[key] = true
+		pairs = append(pairs, pair)
+	}
+	p.pairsMu.Unlock()
+
+	if len(pairs) == 0 {
+		return
+	}
+	if err := w.Db.SaveTickerPairs(pairs); err != nil {
+		log.Printf("Worker %d: Ошибка сохранения пар тикеров: %s", w.Id, err)
+		p.pairsMu.Lock()
+		for _, pair := range pairs {
+			delete(p.pairsSaved, instrumentKey{pair.Exchange, pair.Symbol, pair.Market})
+		}
+		p.pairsMu.Unlock()
+	}
+}
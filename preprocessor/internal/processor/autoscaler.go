@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	// scaleDrainTarget - очередь должна разбираться быстрее, иначе добавляется воркер
+	scaleDrainTarget = 30 * time.Second
+	// scaleIdleChecks - сколько замеров подряд очередь должна быть пустой, чтобы убрать воркер
+	scaleIdleChecks = 3
+	// minScaleGain - на сколько должна вырасти пропускная способность после добавления воркера,
+	// чтобы добавлять следующий; иначе воркеры упираются не в свое число, а в базу
+	minScaleGain = 0.1
+)
+
+// ScaleSample - замер очереди и воркеров за интервал автомасштабирования
+type ScaleSample struct {
+	Depth   int     // сообщений в очереди, еще не отданных процессору
+	Rate    float64 // сообщений в секунду, обработанных всеми воркерами
+	Workers int
+}
+
+// Autoscaler - выбирает число воркеров по глубине очереди и их пропускной способности
+type Autoscaler struct {
+	Min, Max int
+
+	idle          int
+	added         bool
+	rateBeforeAdd float64
+}
+
+// NewAutoscaler - хотя бы один воркер всегда остается
+func NewAutoscaler(minWorkers, maxWorkers int) *Autoscaler {
+	minWorkers = max(minWorkers, 1)
+	return &Autoscaler{Min: minWorkers, Max: max(maxWorkers, minWorkers)}
+}
+
+// Decide - нужное число воркеров. Воркер добавляется, пока очередь не успевает разбираться
+// и предыдущий добавленный воркер увеличил пропускную способность; убирается после
+// нескольких замеров с пустой очередью.
+func (a *Autoscaler) Decide(s ScaleSample) int {
+	target := s.Workers
+	backlog := s.Depth > 0 && (s.Rate == 0 || float64(s.Depth)/s.Rate > scaleDrainTarget.Seconds())
+
+	switch {
+	case backlog:
+		a.idle = 0
+		if a.added && s.Rate <= a.rateBeforeAdd*(1+minScaleGain) {
+			// следующий замер снова попробует добавить воркер
+			a.added = false
+			break
+		}
+		target++
+		a.added = true
+		a.rateBeforeAdd = s.Rate
+	case s.Depth == 0:
+		a.added = false
+		a.idle++
+		if a.idle >= scaleIdleChecks {
+			target--
+			a.idle = 0
+		}
+	default:
+		a.added = false
+		a.idle = 0
+	}
+
+	return min(max(target, a.Min), a.Max)
+}
+
+// RunAutoscaler - раз в ScaleInterval сверяет число воркеров с глубиной очереди
+func (p *Processor) RunAutoscaler(ctx context.Context) {
+	cfg := p.Cfg.Preprocessor
+	if cfg.ScaleInterval <= 0 {
+		log.Println("Автомасштабирование воркеров отключено")
+		return
+	}
+	scaler := NewAutoscaler(cfg.MinWorkers, cfg.MaxWorkers)
+
+	var ch *amqp.Channel
+	defer func() {
+		if ch != nil {
+			ch.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(cfg.ScaleInterval)
+	defer ticker.Stop()
+
+	lastProcessed := p.processed.Load()
+	lastAt := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			processed := p.processed.Load()
+			rate := float64(processed-lastProcessed) / now.Sub(lastAt).Seconds()
+			lastProcessed, lastAt = processed, now
+
+			if ch == nil {
+				var err error
+				if ch, err = p.Conn.Channel(); err != nil {
+					log.Printf("Автомасштабирование: ошибка открытия канала: %s", err)
+					ch = nil
+					continue
+				}
+			}
+			// пассивное объявление не меняет очередь, а только возвращает число сообщений в ней
+			q, err := ch.QueueDeclarePassive(cfg.Queue, true, false, false, false, nil)
+			if err != nil {
+				// после ошибки брокер закрывает канал
+				log.Printf("Автомасштабирование: ошибка чтения очереди %s: %s", cfg.Queue, err)
+				ch = nil
+				continue
+			}
+
+			workers := p.WorkerCount()
+			target := scaler.Decide(ScaleSample{Depth: q.Messages, Rate: rate, Workers: workers})
+			if target == workers {
+				continue
+			}
+			log.Printf("Автомасштабирование: очередь %d, %.1f сообщ/с (%.1f на воркер), воркеров %d -> %d",
+				q.Messages, rate, rate/float64(max(workers, 1)), workers, target)
+			p.scaleTo(target)
+		}
+	}
+}
+
+// scaleTo - добавляет воркеры или удаляет воркеры с наибольшими ID
+func (p *Processor) scaleTo(target int) {
+	ids := p.workerIDs()
+	for i := len(ids); i < target; i++ {
+		p.AddWorker()
+	}
+	for i := len(ids) - 1; i >= target; i-- {
+		p.RemoveWorker(ids[i])
+	}
+}
@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"fmt"
+	"preprocessor/internal/storage"
+)
+
+// parseCandle - свеча из дозагрузки истории
+func parseCandle(body []byte) (Record, error) {
+	var data CandleData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+
+	fields := []struct{ name, value string }{
+		{"open", data.Open}, {"high", data.High}, {"low", data.Low}, {"close", data.Close}, {"volume", data.Volume},
+	}
+	var values [5]string
+	for i, f := range fields {
+		v, err := requiredDecimal(f.value)
+		if err != nil {
+			return Record{}, invalidField(f.name, err)
+		}
+		values[i] = v
+	}
+
+	return Record{Candle: &storage.HistoricalData{
+		Exchange:  data.Exchange,
+		Symbol:    data.Symbol,
+		Market:    "crypto",
+		Timeframe: data.Interval,
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    values[4],
+		Timestamp: data.OpenTime,
+	}}, nil
+}
+
+// saveCandle - сохраняет свечу в historical_data
+func (w *Worker) saveCandle(candle storage.HistoricalData) error {
+	if err := w.Db.SaveHistoricalData(candle); err != nil {
+		return fmt.Errorf("ошибка сохранения свечи: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"preprocessor/internal/storage"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CompositeExchange - биржа, под которой сохраняется композитная цена пары
+const CompositeExchange = "composite"
+
+// Методы композитной цены
+const (
+	// CompositeVWAP - средняя цена бирж, взвешенная по суточному объему
+	CompositeVWAP = "vwap"
+	// CompositeMedian - медиана цен бирж
+	CompositeMedian = "median"
+	// CompositeTrimmed - взвешенная по объему цена бирж, отклонившихся от медианы не больше max_deviation_percent
+	CompositeTrimmed = "trimmed"
+)
+
+// CompositeSpec - параметры расчета композитной цены
+type CompositeSpec struct {
+	Method              string  `yaml:"method"`
+	MaxDeviationPercent float64 `yaml:"max_deviation_percent"`
+	// MinSources - меньше свежих котировок, и цена пары не публикуется
+	MinSources int `yaml:"min_sources"`
+	// MaxAge - котировка старше считается устаревшей; Staleness задает срок для отдельных бирж
+	// или целого рынка: тики DEX хранятся под именами бирж uniswap_v2/uniswap_v3, а рынок у них общий - dex
+	MaxAge    time.Duration            `yaml:"max_age"`
+	Staleness map[string]time.Duration `yaml:"staleness"`
+}
+
+// LoadCompositeSpec - читает параметры композитной цены из YAML файла
+func LoadCompositeSpec(path string) (CompositeSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return CompositeSpec{}, fmt.Errorf("read composite spec: %w", err)
+	}
+
+	var spec CompositeSpec
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		return CompositeSpec{}, fmt.Errorf("parse composite spec %s: %w", path, err)
+	}
+	return spec, spec.validate()
+}
+
+func (s CompositeSpec) validate() error {
+	switch s.Method {
+	case CompositeVWAP, CompositeMedian:
+	case CompositeTrimmed:
+		if s.MaxDeviationPercent <= 0 {
+			return fmt.Errorf("max_deviation_percent must be positive for method %q", s.Method)
+		}
+	default:
+		return fmt.Errorf("unknown composite method %q", s.Method)
+	}
+	if s.MinSources < 0 {
+		return fmt.Errorf("min_sources must not be negative")
+	}
+	if s.MaxAge <= 0 {
+		return fmt.Errorf("max_age must be positive")
+	}
+	for exchange, age := range s.Staleness {
+		if age <= 0 {
+			return fmt.Errorf("staleness of %s must be positive", exchange)
+		}
+	}
+	return nil
+}
+
+// maxAge - срок свежести котировки: сначала по бирже, затем по рынку
+func (s CompositeSpec) maxAge(exchange, market string) time.Duration {
+	if age, ok := s.Staleness[exchange]; ok {
+		return age
+	}
+	if age, ok := s.Staleness[market]; ok {
+		return age
+	}
+	return s.MaxAge
+}
+
+// window - самый длинный срок свежести, за который читаются котировки
+func (s CompositeSpec) window() time.Duration {
+	window := s.MaxAge
+	for _, age := range s.Staleness {
+		window = max(window, age)
+	}
+	return window
+}
+
+type compositeKey struct {
+	market, base, quote string
+}
+
+type compositeSource struct {
+	price, volume *big.Rat
+	scale         int
+}
+
+// ComputeComposite - композитная цена каждой пары рынка по свежим котировкам бирж. Символ ряда - BASE-QUOTE,
+// объем - сумма объемов учтенных бирж; точность цены - наибольшая точность исходных цен.
+func ComputeComposite(spec CompositeSpec, quotes []storage.PairQuote, now time.Time) []storage.MarketData {
+	groups := make(map[compositeKey][]compositeSource)
+	for _, q := range quotes {
+		if now.Sub(q.Timestamp) > spec.maxAge(q.Exchange, q.Market) {
+			continue
+		}
+		text, price, err := parseDecimal(q.Price)
+		if err != nil || price.Sign() <= 0 {
+			continue
+		}
+		_, volume, err := parseDecimal(q.Volume)
+		if err != nil || volume.Sign() < 0 {
+			volume = new(big.Rat)
+		}
+		// точность берется из записи цены биржи, а не из значения: 100.00 дает два знака
+		_, fraction, _ := strings.Cut(text, ".")
+
+		key := compositeKey{q.Market, q.Base, q.Quote}
+		groups[key] = append(groups[key], compositeSource{price: price, volume: volume, scale: len(fraction)})
+	}
+
+	ticks := make([]storage.MarketData, 0, len(groups))
+	for key, sources := range groups {
+		if len(sources) < max(spec.MinSources, 1) {
+			continue
+		}
+		price, used := compositePrice(spec, sources)
+		// после отсечения выбросов котировок может не хватить: медиана без подтверждающих ее бирж не публикуется
+		if len(used) < max(spec.MinSources, 1) {
+			continue
+		}
+
+		volume := new(big.Rat)
+		scale := 0
+		for _, s := range used {
+			volume.Add(volume, s.volume)
+			scale = max(scale, s.scale)
+		}
+
+		ticks = append(ticks, storage.MarketData{
+			Exchange:  CompositeExchange,
+			Symbol:    compositeSymbol(key.base, key.quote),
+			Market:    key.market,
+			Price:     trimZeros(price.FloatString(scale)),
+			Volume:    ratString(volume),
+			Timestamp: now,
+		})
+	}
+
+	sort.Slice(ticks, func(i, j int) bool {
+		if ticks[i].Market != ticks[j].Market {
+			return ticks[i].Market < ticks[j].Market
+		}
+		return ticks[i].Symbol < ticks[j].Symbol
+	})
+	return ticks
+}
+
+// compositePrice - цена пары и учтенные в ней котировки
+func compositePrice(spec CompositeSpec, sources []compositeSource) (*big.Rat, []compositeSource) {
+	sort.Slice(sources, func(i, j int) bool { return sources[i].price.Cmp(sources[j].price) < 0 })
+	median := medianPrice(sources)
+
+	switch spec.Method {
+	case CompositeMedian:
+		return median, sources
+	case CompositeTrimmed:
+		limit := new(big.Rat).Mul(median, new(big.Rat).SetFloat64(spec.MaxDeviationPercent/100))
+		kept := make([]compositeSource, 0, len(sources))
+		for _, s := range sources {
+			deviation := new(big.Rat).Sub(s.price, median)
+			if deviation.Abs(deviation).Cmp(limit) <= 0 {
+				kept = append(kept, s)
+			}
+		}
+		return volumeWeighted(kept, median), kept
+	default:
+		return volumeWeighted(sources, median), sources
+	}
+}
+
+// medianPrice - медиана отсортированных по цене котировок; при четном числе - среднее двух средних
+func medianPrice(sources []compositeSource) *big.Rat {
+	n := len(sources)
+	if n%2 == 1 {
+		return sources[n/2].price
+	}
+	sum := new(big.Rat).Add(sources[n/2-1].price, sources[n/2].price)
+	return sum.Quo(sum, big.NewRat(2, 1))
+}
+
+// volumeWeighted - средняя цена, взвешенная по объему; без объемов возвращается fallback
+func volumeWeighted(sources []compositeSource, fallback *big.Rat) *big.Rat {
+	notional, volume := new(big.Rat), new(big.Rat)
+	for _, s := range sources {
+		notional.Add(notional, new(big.Rat).Mul(s.price, s.volume))
+		volume.Add(volume, s.volume)
+	}
+	if volume.Sign() == 0 {
+		return fallback
+	}
+	return notional.Quo(notional, volume)
+}
+
+// CompositeIndex - периодически пересчитывает композитные цены всех пар по последним тикам бирж
+// и сохраняет их рядом биржи composite вместе со свечами и ценой в USD
+type CompositeIndex struct {
+	db      *storage.Storage
+	spec    CompositeSpec
+	candles *CandleAggregator
+	usd     *UsdConverter
+	// pairs - ряды, пара которых уже записана в тикер
+	pairs map[instrumentKey]bool
+}
+
+// NewCompositeIndex - usd обновляется вызывающим, например UsdConverter.Run
+func NewCompositeIndex(db *storage.Storage, spec CompositeSpec, candleGrace time.Duration, usd *UsdConverter) *CompositeIndex {
+	return &CompositeIndex{
+		db:      db,
+		spec:    spec,
+		candles: NewCandleAggregator(candleGrace),
+		usd:     usd,
+		pairs:   make(map[instrumentKey]bool),
+	}
+}
+
+// Run - пересчитывает цены каждые interval до отмены ctx; возвращается после сохранения незакрытых свечей
+func (c *CompositeIndex) Run(ctx context.Context, interval time.Duration) {
+	candlesDone := make(chan struct{})
+	go func() {
+		runCandles(ctx, c.db, CompositeExchange, c.candles)
+		close(candlesDone)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			<-candlesDone
+			return
+		case now := <-ticker.C:
+			if err := c.update(now.UTC()); err != nil {
+				log.Printf("Ошибка расчета композитных цен: %s", err)
+			}
+		}
+	}
+}
+
+func (c *CompositeIndex) update(now time.Time) error {
+	quotes, err := c.db.LoadPairQuotes(now.Add(-c.spec.window()), CompositeExchange)
+	if err != nil {
+		return err
+	}
+
+	ticks := c.convertUsd(ComputeComposite(c.spec, quotes, now), quotes)
+	if err := c.db.SaveMarketDataBatch(ticks); err != nil {
+		return err
+	}
+	for _, tick := range ticks {
+		c.candles.Add(tick, now)
+	}
+	c.savePairs(ticks, quotes)
+	return nil
+}
+
+// convertUsd - пересчитывает композитные цены в USD по валюте котировки пары
+func (c *CompositeIndex) convertUsd(ticks []storage.MarketData, quotes []storage.PairQuote) []storage.MarketData {
+	quoteOf := make(map[instrumentKey]string, len(quotes))
+	for _, q := range quotes {
+		quoteOf[instrumentKey{CompositeExchange, compositeSymbol(q.Base, q.Quote), q.Market}] = q.Quote
+	}
+	for i, tick := range ticks {
+		if quote, ok := quoteOf[instrumentKey{tick.Exchange, tick.Symbol, tick.Market}]; ok {
+			ticks[i] = c.usd.Convert(tick, quote)
+		}
+	}
+	return ticks
+}
+
+// savePairs - записывает пару новых рядов, чтобы композитная цена находилась по паре вместе с биржами
+func (c *CompositeIndex) savePairs(ticks []storage.MarketData, quotes []storage.PairQuote) {
+	published := make(map[instrumentKey]bool, len(ticks))
+	for _, tick := range ticks {
+		published[instrumentKey{tick.Exchange, tick.Symbol, tick.Market}] = true
+	}
+
+	var pairs []storage.TickerPair
+	for _, q := range quotes {
+		pair := storage.TickerPair{Exchange: CompositeExchange, Symbol: compositeSymbol(q.Base, q.Quote), Market: q.Market, Base: q.Base, Quote: q.Quote}
+		key := instrumentKey{pair.Exchange, pair.Symbol, pair.Market}
+		if published[key] && !c.pairs[key] {
+			pairs = append(pairs, pair)
+			c.pairs[key] = true
+		}
+	}
+	if err := c.db.SaveTickerPairs(pairs); err != nil {
+		log.Printf("Ошибка сохранения пар композитных цен: %s", err)
+		for _, p := range pairs {
+			delete(c.pairs, instrumentKey{p.Exchange, p.Symbol, p.Market})
+		}
+	}
+}
+
+func compositeSymbol(base, quote string) string {
+	return base + "-" + quote
+}
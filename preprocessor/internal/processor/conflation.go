@@ -0,0 +1,233 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"preprocessor/internal/storage"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// conflationCheckInterval - как часто проверяются отложенные прореживанием тики
+const conflationCheckInterval = time.Second
+
+// ConflationRule - прореживание записи тиков биржи; "*" - правило по умолчанию, правило биржи
+// переопределяет заданные в нем значения.
+// Тик записывается, если с записи предыдущего тика тикера прошло не меньше MinInterval и цена
+// изменилась хотя бы на MinChangeBps; через MaxInterval тик записывается в любом случае,
+// чтобы последние данные тикера не устаревали. Нулевые значения не ограничивают запись.
+// Последний отброшенный тик откладывается и записывается по таймеру, когда условие выполнится,
+// даже если новых тиков не приходит.
+type ConflationRule struct {
+	Exchange     string        `yaml:"exchange"`
+	MinInterval  time.Duration `yaml:"min_interval"`
+	MinChangeBps float64       `yaml:"min_change_bps"`
+	MaxInterval  time.Duration `yaml:"max_interval"`
+}
+
+// ConflationStats - число записанных и отброшенных прореживанием тиков
+type ConflationStats struct {
+	Written int64
+	Dropped int64
+}
+
+// Conflator - решает, записывать ли тик, по последнему записанному тику того же тикера
+type Conflator struct {
+	rule ConflationRule
+
+	mu   sync.Mutex
+	last map[instrumentKey]conflatedTick
+	// held - последний отброшенный тик тикера после записанного
+	held  map[instrumentKey]storage.MarketData
+	stats ConflationStats
+}
+
+type conflatedTick struct {
+	price *big.Rat
+	at    time.Time
+}
+
+// LoadConflationRule - читает правила прореживания из YAML файла и выбирает правило биржи
+func LoadConflationRule(path, exchange string) (ConflationRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ConflationRule{}, fmt.Errorf("read conflation rules: %w", err)
+	}
+
+	var file struct {
+		Rules []ConflationRule `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return ConflationRule{}, fmt.Errorf("parse conflation rules %s: %w", path, err)
+	}
+
+	// правило биржи переопределяет заданные в нем значения правила по умолчанию
+	var rule, specific ConflationRule
+	for _, r := range file.Rules {
+		switch r.Exchange {
+		case "", "*":
+			rule = r
+		case exchange:
+			specific = r
+		}
+	}
+	if specific.MinInterval > 0 {
+		rule.MinInterval = specific.MinInterval
+	}
+	if specific.MinChangeBps > 0 {
+		rule.MinChangeBps = specific.MinChangeBps
+	}
+	if specific.MaxInterval > 0 {
+		rule.MaxInterval = specific.MaxInterval
+	}
+	rule.Exchange = exchange
+
+	if rule.MinInterval < 0 || rule.MaxInterval < 0 || rule.MinChangeBps < 0 {
+		return ConflationRule{}, fmt.Errorf("conflation rule for %s: limits must not be negative", exchange)
+	}
+	if rule.MaxInterval > 0 && rule.MaxInterval < rule.MinInterval {
+		return ConflationRule{}, fmt.Errorf("conflation rule for %s: max_interval is shorter than min_interval", exchange)
+	}
+	return rule, nil
+}
+
+func NewConflator(rule ConflationRule) *Conflator {
+	return &Conflator{
+		rule: rule,
+		last: make(map[instrumentKey]conflatedTick),
+		held: make(map[instrumentKey]storage.MarketData),
+	}
+}
+
+// Accept - true, если тик нужно записать; принятый тик становится последним записанным
+func (c *Conflator) Accept(tick storage.MarketData) bool {
+	if c.rule.MinInterval == 0 && c.rule.MinChangeBps == 0 {
+		return true
+	}
+	_, price, err := parseDecimal(tick.Price)
+	if err != nil {
+		return true
+	}
+	key := instrumentKey{tick.Exchange, tick.Symbol, tick.Market}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last, ok := c.last[key]
+	if ok && !c.due(last, price, tick.Timestamp) {
+		if _, replaced := c.held[key]; replaced {
+			c.stats.Dropped++
+		}
+		c.held[key] = tick
+		return false
+	}
+	c.last[key] = conflatedTick{price: price, at: tick.Timestamp}
+	delete(c.held, key)
+	return true
+}
+
+// TakeDue - отложенные тики, которые к моменту now пора записать; они становятся последними записанными
+func (c *Conflator) TakeDue(now time.Time) []storage.MarketData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var due []storage.MarketData
+	for key, tick := range c.held {
+		last, ok := c.last[key]
+		_, price, err := parseDecimal(tick.Price)
+		if !ok || err != nil {
+			delete(c.held, key)
+			continue
+		}
+		if !c.due(last, price, now) {
+			continue
+		}
+		c.last[key] = conflatedTick{price: price, at: tick.Timestamp}
+		delete(c.held, key)
+		due = append(due, tick)
+	}
+	return due
+}
+
+func (c *Conflator) due(last conflatedTick, price *big.Rat, at time.Time) bool {
+	elapsed := at.Sub(last.at)
+	if c.rule.MaxInterval > 0 && elapsed >= c.rule.MaxInterval {
+		return true
+	}
+	if elapsed < c.rule.MinInterval {
+		return false
+	}
+	if c.rule.MinChangeBps <= 0 || last.price.Sign() == 0 {
+		return true
+	}
+
+	// |price - last| / last в базисных пунктах
+	change := new(big.Rat).Sub(price, last.price)
+	change.Abs(change).Quo(change, last.price).Mul(change, big.NewRat(10000, 1))
+	threshold := new(big.Rat).SetFloat64(c.rule.MinChangeBps)
+	return change.Cmp(threshold) >= 0
+}
+
+// Forget - сбрасывает последний записанный тик тикеров, запись которых не удалась,
+// чтобы повторно доставленные тики не были отброшены
+func (c *Conflator) Forget(ticks []storage.MarketData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tick := range ticks {
+		key := instrumentKey{tick.Exchange, tick.Symbol, tick.Market}
+		delete(c.last, key)
+		delete(c.held, key)
+	}
+}
+
+// recordWritten - учитывает записанные в базу тики
+func (c *Conflator) recordWritten(n int) {
+	c.mu.Lock()
+	c.stats.Written += int64(n)
+	c.mu.Unlock()
+}
+
+// Stats - счетчики с момента запуска; отложенный тик считается отброшенным, когда его заменил следующий
+func (c *Conflator) Stats() ConflationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// ConflationStats - счетчики прореживания записи тиков
+func (p *Processor) ConflationStats() ConflationStats {
+	return p.conflation.Stats()
+}
+
+// RunConflation - записывает отложенные прореживанием тики, как только для них выполнено правило
+func (p *Processor) RunConflation(ctx context.Context) {
+	ticker := time.NewTicker(conflationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			due := p.conflation.TakeDue(now.UTC())
+			if len(due) == 0 {
+				continue
+			}
+			if err := p.Db.SaveMarketDataBatch(due); err != nil {
+				// следующий тик тикера будет записан без прореживания
+				log.Printf("Ошибка сохранения отложенных тиков: %s", err)
+				p.conflation.Forget(due)
+				continue
+			}
+			p.conflation.recordWritten(len(due))
+		}
+	}
+}
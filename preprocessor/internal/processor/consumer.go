@@ -1,12 +1,14 @@
 package processor
 
 import (
-	"encoding/json"
-	"fmt"
-
 	"github.com/streadway/amqp"
 )
 
+// Publisher - публикация повторов и dead-letter сообщений; в работе это *amqp.Channel
+type Publisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
 func (p *Processor) ConnectToRabbitMQ() error {
 	conn, err := amqp.Dial(p.Cfg.RabbitMQ.URL)
 	if err != nil {
@@ -18,57 +20,38 @@ func (p *Processor) ConnectToRabbitMQ() error {
 		return err
 	}
 
+	// сообщения подтверждаются после записи, prefetch ограничивает число сообщений в обработке;
+	// конфигурация уменьшает пачку воркера до его доли prefetch
+	if err := ch.Qos(max(p.Cfg.RabbitMQ.Prefetch, 1), 0, false); err != nil {
+		return err
+	}
+	if err := DeclareDeadLetter(ch, p.Cfg.Preprocessor.Queue); err != nil {
+		return err
+	}
+
+	// повторы и dead-letter публикуются через отдельный канал, чтобы не мешать чтению очереди
+	pubCh, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+
 	p.Conn = conn
 	p.Ch = ch
+	p.Pub = pubCh
 
 	return nil
 }
 
-func (p *Processor) ConsumeMessage(body []byte) (GenericMessage, error) {
-	var msg GenericMessage
-	switch p.Cfg.Preprocessor.Exchange {
-	case "binance":
-		var msgBinance BinanceMarketData
-		err := json.Unmarshal(body, &msgBinance)
-		if err != nil {
-			return nil, err
-		}
-		msg = msgBinance
-	case "bybit":
-		var msgBybit BybitMarketData
-		err := json.Unmarshal(body, &msgBybit)
-		if err != nil {
-			return nil, err
-		}
-		msg = msgBybit
-	case "okx":
-		var msgOkx OkxMarketData
-		err := json.Unmarshal(body, &msgOkx)
-		if err != nil {
-			return nil, err
-		}
-		msg = msgOkx
-	case "coinbase":
-		var msgCoinbase CoinbaseMarketData
-		err := json.Unmarshal(body, &msgCoinbase)
-		if err != nil {
-			return nil, err
-		}
-		msg = msgCoinbase
-	case "moex":
-		var msgMoex MoexMarketData
-		err := json.Unmarshal(body, &msgMoex)
-		if err != nil {
-			return nil, err
-		}
-		msg = msgMoex
-	default:
-		return nil, fmt.Errorf("unsupported exchange: %s", p.Cfg.Preprocessor.Exchange)
-	}
-	return msg, nil
+func (p *Processor) publish(exchange, key string, msg amqp.Publishing) error {
+	p.pubMu.Lock()
+	defer p.pubMu.Unlock()
+	return p.Pub.Publish(exchange, key, false, false, msg)
 }
 
 func (p *Processor) CloseConnection() {
+	if ch, ok := p.Pub.(*amqp.Channel); ok {
+		ch.Close()
+	}
 	p.Ch.Close()
 	p.Conn.Close()
 }
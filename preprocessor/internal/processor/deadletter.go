@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Заголовки сообщений, которые процессор добавляет при повторе и отправке в dead-letter очередь
+const (
+	HeaderRetryCount    = "x-retry-count"
+	HeaderFailureReason = "x-failure-reason"
+	HeaderFailedAt      = "x-failed-at"
+	HeaderOriginalQueue = "x-original-queue"
+)
+
+// errInvalidMessage - сообщение не удастся обработать и при повторе, оно сразу уходит в dead-letter очередь
+var errInvalidMessage = errors.New("invalid message")
+
+func invalidMessage(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errInvalidMessage, fmt.Sprintf(format, args...))
+}
+
+// DeadLetterExchange - обменник отклоненных сообщений очереди
+func DeadLetterExchange(queue string) string {
+	return queue + ".dlx"
+}
+
+// DeadLetterQueue - очередь, в которой отклоненные сообщения ждут разбора
+func DeadLetterQueue(queue string) string {
+	return queue + ".dead"
+}
+
+// DeclareDeadLetter - объявляет обменник и очередь dead-letter для очереди
+func DeclareDeadLetter(ch *amqp.Channel, queue string) error {
+	if err := ch.ExchangeDeclare(DeadLetterExchange(queue), "fanout", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare dead-letter exchange: %w", err)
+	}
+	if _, err := ch.QueueDeclare(DeadLetterQueue(queue), true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare dead-letter queue: %w", err)
+	}
+	if err := ch.QueueBind(DeadLetterQueue(queue), "", DeadLetterExchange(queue), false, nil); err != nil {
+		return fmt.Errorf("bind dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+// RetryCount - сколько раз сообщение уже возвращалось в очередь после ошибки
+func RetryCount(msg amqp.Delivery) int {
+	switch v := msg.Headers[HeaderRetryCount].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// settle - подтверждает сообщение после успешной записи; при ошибке возвращает его
+// в очередь с увеличенным счетчиком или отправляет в dead-letter очередь
+func (w *Worker) settle(msg amqp.Delivery, err error) {
+	if err == nil {
+		w.ack(msg)
+		return
+	}
+
+	if !errors.Is(err, errInvalidMessage) {
+		w.backoff(RetryCount(msg))
+	}
+	w.reject(msg, err)
+}
+
+// backoff - задержка растет с каждым повтором и сдерживает чтение очереди, пока база недоступна
+func (w *Worker) backoff(retries int) {
+	time.Sleep(w.Processor.Cfg.Preprocessor.RetryDelay * time.Duration(retries+1))
+}
+
+// reject - без задержки возвращает сообщение в очередь или отправляет его в dead-letter очередь
+func (w *Worker) reject(msg amqp.Delivery, err error) {
+	cfg := w.Processor.Cfg.Preprocessor
+	retries := RetryCount(msg)
+	if errors.Is(err, errInvalidMessage) || retries >= cfg.MaxRetries {
+		log.Printf("Worker %d: Сообщение отправлено в dead-letter после %d повторов: %s", w.Id, retries, err)
+		w.publishOrRequeue(msg, DeadLetterExchange(cfg.Queue), "", failureHeaders(msg, cfg.Queue, err))
+		return
+	}
+
+	log.Printf("Worker %d: Повтор %d/%d: %s", w.Id, retries+1, cfg.MaxRetries, err)
+	headers := copyHeaders(msg.Headers)
+	headers[HeaderRetryCount] = int32(retries + 1)
+	w.publishOrRequeue(msg, "", cfg.Queue, headers)
+}
+
+// publishOrRequeue - публикует копию сообщения и подтверждает оригинал; если публикация
+// не удалась, оригинал возвращается брокеру без подтверждения
+func (w *Worker) publishOrRequeue(msg amqp.Delivery, exchange, key string, headers amqp.Table) {
+	err := w.Processor.publish(exchange, key, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Type:         msg.Type,
+		Timestamp:    msg.Timestamp,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		log.Printf("Worker %d: Ошибка повторной публикации: %s", w.Id, err)
+		if err := msg.Nack(false, true); err != nil {
+			log.Printf("Worker %d: Ошибка nack: %s", w.Id, err)
+		}
+		return
+	}
+	w.ack(msg)
+}
+
+func (w *Worker) ack(msg amqp.Delivery) {
+	if err := msg.Ack(false); err != nil {
+		log.Printf("Worker %d: Ошибка подтверждения сообщения: %s", w.Id, err)
+	}
+}
+
+func failureHeaders(msg amqp.Delivery, queue string, err error) amqp.Table {
+	headers := copyHeaders(msg.Headers)
+	headers[HeaderFailureReason] = err.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[HeaderOriginalQueue] = queue
+	return headers
+}
+
+func copyHeaders(h amqp.Table) amqp.Table {
+	out := make(amqp.Table, len(h)+1)
+	for k, v := range h {
+		out[k] = v
+	}
+	return out
+}
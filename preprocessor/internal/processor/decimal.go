@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var decimalPattern = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][-+]?\d+)?$`)
+
+// parseDecimal - проверяет десятичную запись числа из сообщения биржи и возвращает ее
+// без экспоненты вместе со значением. Число не проходит через float64, точность не теряется.
+func parseDecimal(s string) (string, *big.Rat, error) {
+	if !decimalPattern.MatchString(s) {
+		return "", nil, fmt.Errorf("invalid decimal %q", s)
+	}
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	mantissa, exp, found := strings.Cut(strings.ToLower(s), "e")
+	if !found {
+		return s, value, nil
+	}
+
+	// после переноса запятой дробных знаков станет fraction - exp
+	shift, err := strconv.Atoi(exp)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid decimal %q: %w", s, err)
+	}
+	_, fraction, _ := strings.Cut(mantissa, ".")
+	return trimZeros(value.FloatString(max(len(fraction)-shift, 0))), value, nil
+}
+
+// requiredDecimal - десятичная запись без экспоненты
+func requiredDecimal(s string) (string, error) {
+	text, _, err := parseDecimal(s)
+	return text, err
+}
+
+// optionalDecimal - пустое значение остается пустым: биржа его не передает, это не ноль
+func optionalDecimal(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	text, _, err := parseDecimal(s)
+	return text, err
+}
+
+// decimalScale - число значащих знаков после запятой, например 2 для шага цены 0.010
+func decimalScale(s string) (int, bool) {
+	text, _, err := parseDecimal(s)
+	if err != nil {
+		return 0, false
+	}
+	_, fraction, _ := strings.Cut(trimZeros(text), ".")
+	return len(fraction), true
+}
+
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
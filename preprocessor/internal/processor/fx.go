@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"preprocessor/internal/storage"
+	"time"
+)
+
+// stablecoinRateInterval - как часто сохранять курс стейблкоина, выведенный из тикеров
+const stablecoinRateInterval = time.Minute
+
+// stablecoinPairs - тикеры бирж, цена которых является курсом стейблкоина (base/quote)
+var stablecoinPairs = map[string]map[string][2]string{
+	"coinbase": {
+		"USDT-USD": {"USDT", "USD"},
+		"USDC-USD": {"USDC", "USD"},
+		"DAI-USD":  {"DAI", "USD"},
+	},
+	"okx": {
+		"USDC-USDT": {"USDC", "USDT"},
+	},
+	"binance": {
+		"USDCUSDT":  {"USDC", "USDT"},
+		"FDUSDUSDT": {"FDUSD", "USDT"},
+	},
+	"bybit": {
+		"USDCUSDT": {"USDC", "USDT"},
+	},
+}
+
+// parseFxRate - курс валюты от fx коннектора
+func parseFxRate(body []byte) (Record, error) {
+	var data FxRateData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+
+	ts, err := time.Parse(time.RFC3339, data.Time)
+	if err != nil {
+		return Record{}, invalidField("time", err)
+	}
+	rate, err := requiredDecimal(data.Rate)
+	if err != nil {
+		return Record{}, invalidField("rate", err)
+	}
+
+	return Record{FxRate: &storage.FxRate{
+		Base:      data.Base,
+		Quote:     data.Quote,
+		Rate:      rate,
+		Source:    data.Source,
+		Timestamp: ts.UTC(),
+	}}, nil
+}
+
+// saveFxRate - сохраняет курс валюты
+func (w *Worker) saveFxRate(rate storage.FxRate) error {
+	if err := w.Db.SaveFxRate(rate); err != nil {
+		return fmt.Errorf("ошибка сохранения курса %s/%s: %w", rate.Base, rate.Quote, err)
+	}
+	return nil
+}
+
+// deriveStablecoinRate - сохраняет курс стейблкоина, если тикер является парой из stablecoinPairs
+func (w *Worker) deriveStablecoinRate(tick storage.MarketData) {
+	exchange, symbol, price := tick.Exchange, tick.Symbol, tick.Price
+
+	pair, ok := stablecoinPairs[exchange][symbol]
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	if !w.Processor.stablecoinRateDue(symbol, now) {
+		return
+	}
+
+	err := w.Db.SaveFxRate(storage.FxRate{
+		Base:      pair[0],
+		Quote:     pair[1],
+		Rate:      price,
+		Source:    exchange,
+		Timestamp: now,
+	})
+	if err != nil {
+		log.Printf("Worker %d: Ошибка сохранения курса %s/%s: %s", w.Id, pair[0], pair[1], err)
+	}
+}
+
+func (p *Processor) stablecoinRateDue(symbol string, now time.Time) bool {
+	p.stablecoinMu.Lock()
+	defer p.stablecoinMu.Unlock()
+
+	if last, ok := p.stablecoinSaved[symbol]; ok && now.Sub(last) < stablecoinRateInterval {
+		return false
+	}
+	p.stablecoinSaved[symbol] = now
+	return true
+}
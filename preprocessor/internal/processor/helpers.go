@@ -1,198 +1,145 @@
 package processor
 
 import (
-	"log"
+	"fmt"
+	"math/big"
 	"preprocessor/internal/storage"
-	"strconv"
+	"strings"
 )
 
-// ProcessPriceByExchange - обрабатывает сообщение и возвращает структуру MarketData
-func (w *Worker) ProcessFloatsByExchange(msg GenericMessage) storage.MarketData {
-	switch data := msg.(type) {
-	case BinanceMarketData:
-		price, err := strconv.ParseFloat(data.LastPrice, 64)
-		if err != nil {
-			log.Printf("Failed to parse price: %v", err)
-			return storage.MarketData{}
-		}
-
-		if price < 0.1 {
-			return storage.MarketData{}
-		}
-
-		volume, err := strconv.ParseFloat(data.TotalTradedBaseAssetVolume, 64)
-		if err != nil {
-			log.Printf("Failed to parse volume: %v", err)
-			return storage.MarketData{}
-		}
-
-		high, err := strconv.ParseFloat(data.HighPrice, 64)
-		if err != nil {
-			log.Printf("Failed to parse high: %v", err)
-			return storage.MarketData{}
-		}
-
-		low, err := strconv.ParseFloat(data.LowPrice, 64)
-		if err != nil {
-			log.Printf("Failed to parse low: %v", err)
-			return storage.MarketData{}
-		}
-
-		priceInt := int64(price * 1e3)
-		volumeInt := int64(volume * 1e3)
-		highInt := int64(high * 1e3)
-		lowInt := int64(low * 1e3)
-
-		return storage.MarketData{
-			Exchange:           "binance",
-			Symbol:             data.Symbol,
-			Market:             "crypto",
-			Price:              priceInt,
-			Volume:             volumeInt,
-			High:               highInt,
-			Low:                lowInt,
-			PriceChangePercent: data.PriceChangePercent,
-		}
-
-	case BybitMarketData:
-		price, err := strconv.ParseFloat(data.LastPrice, 64)
-		if err != nil {
-			log.Printf("Failed to parse price: %v", err)
-			return storage.MarketData{}
-		}
-
-		if price < 0.1 {
-			return storage.MarketData{}
-		}
-
-		volume, err := strconv.ParseFloat(data.Volume24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse volume: %v", err)
-			return storage.MarketData{}
-		}
-
-		high, err := strconv.ParseFloat(data.HighPrice24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse high: %v", err)
-			return storage.MarketData{}
-		}
-
-		low, err := strconv.ParseFloat(data.LowPrice24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse low: %v", err)
-			return storage.MarketData{}
-		}
-
-		priceInt := int64(price * 1e3)
-		volumeInt := int64(volume * 1e3)
-		highInt := int64(high * 1e3)
-		lowInt := int64(low * 1e3)
-
-		return storage.MarketData{
-			Exchange:           "bybit",
-			Symbol:             data.Symbol,
-			Market:             "crypto",
-			Price:              priceInt,
-			Volume:             volumeInt,
-			High:               highInt,
-			Low:                lowInt,
-			PriceChangePercent: data.Price24hPcnt,
-		}
-
-	case OkxMarketData:
-		price, err := strconv.ParseFloat(data.Last, 64)
-		if err != nil {
-			log.Printf("Failed to parse price: %v", err)
-			return storage.MarketData{}
-		}
-
-		if price < 0.1 {
-			return storage.MarketData{}
-		}
-
-		volume, err := strconv.ParseFloat(data.VolCcy24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse volume: %v", err)
-			return storage.MarketData{}
-		}
-
-		high, err := strconv.ParseFloat(data.High24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse high: %v", err)
-			return storage.MarketData{}
-		}
+// spotTicker - поля тикера биржи в исходной строковой записи; общий для всех бирж разбор в MarketData.
+// Цена обязательна и положительна, суточные значения и лучшие цены необязательны.
+type spotTicker struct {
+	exchange, symbol, market string
+	price, volume, high, low string
+	// optionalRange - объема и диапазона цены может не быть, тогда они остаются пустыми (NULL в базе)
+	optionalRange bool
+	// open - цена открытия суточного окна; если ее нет, она выводится из changePercent
+	open, changePercent        string
+	vwap, quoteVolume          string
+	bid, bidSize, ask, askSize string
+}
 
-		low, err := strconv.ParseFloat(data.Low24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse low: %v", err)
-			return storage.MarketData{}
-		}
+func (t spotTicker) record() (Record, error) {
+	price, priceValue, err := parseDecimal(t.price)
+	if err != nil {
+		return Record{}, invalidField("price", err)
+	}
+	if priceValue.Sign() <= 0 {
+		return Record{}, invalidField("price", fmt.Errorf("non-positive price %s", price))
+	}
 
-		priceInt := int64(price * 1e3)
-		volumeInt := int64(volume * 1e3)
-		highInt := int64(high * 1e3)
-		lowInt := int64(low * 1e3)
+	convert := requiredDecimal
+	if t.optionalRange {
+		convert = optionalDecimal
+	}
+	volume, err := convert(t.volume)
+	if err != nil {
+		return Record{}, invalidField("volume", err)
+	}
+	high, err := convert(t.high)
+	if err != nil {
+		return Record{}, invalidField("high", err)
+	}
+	low, err := convert(t.low)
+	if err != nil {
+		return Record{}, invalidField("low", err)
+	}
 
-		return storage.MarketData{
-			Exchange:           "okx",
-			Symbol:             data.InstID,
-			Market:             "crypto",
-			Price:              priceInt,
-			Volume:             volumeInt,
-			High:               highInt,
-			Low:                lowInt,
-			PriceChangePercent: "nil",
-		}
+	market := t.market
+	if market == "" {
+		market = "crypto"
+	}
+	open := t.open
+	if open == "" && t.changePercent != "" {
+		open = openFromChange(priceValue, t.changePercent)
+	}
 
-	case CoinbaseMarketData:
-		price, err := strconv.ParseFloat(data.Price, 64)
-		if err != nil {
-			log.Printf("Failed to parse price: %v", err)
-			return storage.MarketData{}
-		}
+	data := withBestQuotes(storage.MarketData{
+		Exchange: t.exchange,
+		Symbol:   t.symbol,
+		Market:   market,
+		Price:    price,
+		Volume:   volume,
+		High:     high,
+		Low:      low,
+	}, t.bid, t.bidSize, t.ask, t.askSize)
+	data = withDailyFields(data, priceValue, open, t.vwap, t.quoteVolume)
+	return Record{Ticker: &data}, nil
+}
 
-		if price < 0.1 {
-			return storage.MarketData{}
-		}
+// withDailyFields - заполняет суточные изменение цены, VWAP и объем в валюте котировки.
+// Изменение считается от цены открытия суточного окна. VWAP берется у биржи, а если его нет -
+// считается как оборот в валюте котировки, деленный на объем. Неизвестные значения остаются пустыми.
+func withDailyFields(data storage.MarketData, price *big.Rat, open, vwap, quoteVolume string) storage.MarketData {
+	// производные значения округляются до точности цены с двумя запасными знаками
+	_, fraction, _ := strings.Cut(data.Price, ".")
+	scale := len(fraction) + 2
+
+	if _, openPrice, err := parseDecimal(open); err == nil && openPrice.Sign() > 0 {
+		change := new(big.Rat).Sub(price, openPrice)
+		data.PriceChange = trimZeros(change.FloatString(scale))
+		data.PriceChangePercent = changePercent(price, openPrice)
+	}
 
-		volume, err := strconv.ParseFloat(data.Volume24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse volume: %v", err)
-			return storage.MarketData{}
-		}
+	quote, quoteValue, err := parseDecimal(quoteVolume)
+	if err != nil {
+		return data
+	}
+	data.QuoteVolume = quote
 
-		high, err := strconv.ParseFloat(data.High24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse high: %v", err)
-			return storage.MarketData{}
-		}
+	if text, value, err := parseDecimal(vwap); err == nil && value.Sign() > 0 {
+		data.VWAP = text
+		return data
+	}
+	if _, volume, err := parseDecimal(data.Volume); err == nil && volume.Sign() > 0 {
+		data.VWAP = trimZeros(new(big.Rat).Quo(quoteValue, volume).FloatString(scale))
+	}
+	return data
+}
 
-		low, err := strconv.ParseFloat(data.Low24h, 64)
-		if err != nil {
-			log.Printf("Failed to parse low: %v", err)
-			return storage.MarketData{}
-		}
+// withBestQuotes - заполняет лучшие цены и объемы заявок и спред в базисных пунктах от средней цены.
+// Некорректные или отсутствующие значения остаются пустыми; спред считается, только если
+// обе цены положительны и заявки не пересекаются.
+func withBestQuotes(data storage.MarketData, bid, bidSize, ask, askSize string) storage.MarketData {
+	bidText, bidValue, bidErr := parseDecimal(bid)
+	askText, askValue, askErr := parseDecimal(ask)
+	if bidErr == nil && bidValue.Sign() > 0 {
+		data.Bid = bidText
+		data.BidSize, _, _ = parseDecimal(bidSize)
+	}
+	if askErr == nil && askValue.Sign() > 0 {
+		data.Ask = askText
+		data.AskSize, _, _ = parseDecimal(askSize)
+	}
 
-		priceInt := int64(price * 1e3)
-		volumeInt := int64(volume * 1e3)
-		highInt := int64(high * 1e3)
-		lowInt := int64(low * 1e3)
+	if data.Bid != "" && data.Ask != "" && askValue.Cmp(bidValue) >= 0 {
+		mid := new(big.Rat).Add(bidValue, askValue)
+		mid.Quo(mid, big.NewRat(2, 1))
+		spread := new(big.Rat).Sub(askValue, bidValue)
+		spread.Quo(spread, mid).Mul(spread, big.NewRat(10000, 1))
+		data.SpreadBps = trimZeros(spread.FloatString(4))
+	}
+	return data
+}
 
-		return storage.MarketData{
-			Exchange:           "coinbase",
-			Symbol:             data.ProductID,
-			Market:             "crypto",
-			Price:              priceInt,
-			Volume:             volumeInt,
-			High:               highInt,
-			Low:                lowInt,
-			PriceChangePercent: "nil",
-		}
-	case MoexMarketData:
-		// TODO: add fields
-		return storage.MarketData{}
-	default:
-		log.Printf("Unsupported type: %T", msg)
-		return storage.MarketData{}
+// openFromChange - цена открытия по текущей цене и изменению в процентах: price / (1 + percent/100)
+func openFromChange(price *big.Rat, percent string) string {
+	_, change, err := parseDecimal(percent)
+	if err != nil {
+		return ""
+	}
+	ratio := new(big.Rat).Quo(change, big.NewRat(100, 1))
+	ratio.Add(ratio, big.NewRat(1, 1))
+	if ratio.Sign() <= 0 {
+		return ""
 	}
+	return new(big.Rat).Quo(price, ratio).FloatString(18)
+}
+
+// changePercent - изменение цены в процентах относительно цены открытия
+func changePercent(price, open *big.Rat) string {
+	change := new(big.Rat).Sub(price, open)
+	change.Quo(change, open).Mul(change, big.NewRat(100, 1))
+	return change.FloatString(3)
 }
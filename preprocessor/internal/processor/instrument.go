@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"fmt"
+	"preprocessor/internal/storage"
+)
+
+// parseInstrument - метаданные инструмента, опубликованные коннектором при загрузке справочника
+func parseInstrument(body []byte) (Record, error) {
+	var data InstrumentData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+
+	market := data.Market
+	if market == "" {
+		market = "crypto"
+	}
+
+	// точность цены задается шагом цены инструмента
+	var priceScale *int
+	if scale, ok := decimalScale(data.TickSize); ok {
+		priceScale = &scale
+	}
+
+	return Record{Instrument: &storage.Instrument{
+		Exchange:    data.Exchange,
+		Symbol:      data.Symbol,
+		Market:      market,
+		Base:        data.Base,
+		Quote:       data.Quote,
+		TickSize:    data.TickSize,
+		LotSize:     data.LotSize,
+		MinNotional: data.MinNotional,
+		PriceScale:  priceScale,
+		Status:      data.Status,
+		ListingTime: data.ListingTime,
+	}}, nil
+}
+
+// saveInstrument - сохраняет инструмент и его каноническую пару
+func (w *Worker) saveInstrument(inst storage.Instrument) error {
+	if err := w.Db.SaveInstrument(inst); err != nil {
+		return fmt.Errorf("ошибка сохранения инструмента %s: %w", inst.Symbol, err)
+	}
+
+	// пара из справочника точнее разбора символа и заменяет его
+	if pair, ok := w.Processor.assets.Learn(inst.Exchange, inst.Symbol, inst.Market, inst.Base, inst.Quote); ok {
+		if err := w.Db.SaveTickerPairs([]storage.TickerPair{pair}); err != nil {
+			return fmt.Errorf("ошибка сохранения пары инструмента %s: %w", inst.Symbol, err)
+		}
+		w.Processor.pairsMu.Lock()
+		w.Processor.pairsSaved[instrumentKey{inst.Exchange, inst.Symbol, inst.Market}] = true
+		w.Processor.pairsMu.Unlock()
+	}
+	return nil
+}
@@ -1,7 +1,22 @@
 package processor
 
-type GenericMessage interface{}
+import (
+	"encoding/json"
+	"time"
+)
 
+// Типы сообщений коннектора (свойство Type сообщения AMQP).
+// Рыночные данные в формате биржи приходят без типа.
+const (
+	KindMarketData  = ""
+	KindSequenceGap = "sequence_gap"
+	KindInstrument  = "instrument"
+	KindTicker      = "ticker"
+	KindCandle      = "candle"
+)
+
+// BinanceMarketData - событие 24hrTicker; 24hrMiniTicker использует те же ключи,
+// но содержит только c, o, h, l, v и q
 type BinanceMarketData struct {
 	Event                       string `json:"e"`
 	EventTime                   int64  `json:"E"`
@@ -79,6 +94,76 @@ type OkxMarketData struct {
 	SodUtc8   string `json:"sodUtc8"`
 }
 
+// DeribitTickerData - тикер опциона из канала ticker.{instrument}; числа сохраняются без потери точности
+type DeribitTickerData struct {
+	InstrumentName  string      `json:"instrument_name"`
+	Timestamp       int64       `json:"timestamp"`
+	MarkPrice       json.Number `json:"mark_price"`
+	MarkIV          json.Number `json:"mark_iv"`
+	BidIV           json.Number `json:"bid_iv"`
+	AskIV           json.Number `json:"ask_iv"`
+	BestBidPrice    json.Number `json:"best_bid_price"`
+	BestAskPrice    json.Number `json:"best_ask_price"`
+	UnderlyingPrice json.Number `json:"underlying_price"`
+	OpenInterest    json.Number `json:"open_interest"`
+	Greeks          struct {
+		Delta json.Number `json:"delta"`
+		Gamma json.Number `json:"gamma"`
+		Vega  json.Number `json:"vega"`
+		Theta json.Number `json:"theta"`
+		Rho   json.Number `json:"rho"`
+	} `json:"greeks"`
+}
+
+type FxRateData struct {
+	Market string `json:"market"`
+	Base   string `json:"base"`
+	Quote  string `json:"quote"`
+	Rate   string `json:"rate"`
+	Source string `json:"source"`
+	Time   string `json:"time"`
+}
+
+// GenericMarketData - нормализованный тикер универсального коннектора
+type GenericMarketData struct {
+	Exchange      string `json:"exchange"`
+	Symbol        string `json:"symbol"`
+	Market        string `json:"market"` // пусто для спотовых бирж, "dex" для пулов DEX
+	Price         string `json:"price"`
+	Volume        string `json:"volume"`
+	High          string `json:"high"`
+	Low           string `json:"low"`
+	ChangePercent string `json:"change_percent"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// CandleData - свеча из дозагрузки истории (connector backfill)
+type CandleData struct {
+	Exchange string    `json:"exchange"`
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	OpenTime time.Time `json:"open_time"`
+	Open     string    `json:"open"`
+	High     string    `json:"high"`
+	Low      string    `json:"low"`
+	Close    string    `json:"close"`
+	Volume   string    `json:"volume"`
+}
+
+// InstrumentData - метаданные инструмента из справочника биржи
+type InstrumentData struct {
+	Exchange    string     `json:"exchange"`
+	Symbol      string     `json:"symbol"`
+	Market      string     `json:"market"`
+	Base        string     `json:"base"`
+	Quote       string     `json:"quote"`
+	TickSize    string     `json:"tick_size"`
+	LotSize     string     `json:"lot_size"`
+	MinNotional string     `json:"min_notional"`
+	Status      string     `json:"status"`
+	ListingTime *time.Time `json:"listing_time"`
+}
+
 type MoexMarketData struct {
 	// TODO: add fields
 }
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"fmt"
+	"preprocessor/internal/storage"
+	"strings"
+	"time"
+)
+
+// parseDeribitTicker - тикер опциона Deribit вместе с параметрами контракта из его имени
+func parseDeribitTicker(body []byte) (Record, error) {
+	var data DeribitTickerData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+
+	contract, err := parseOptionName(data.InstrumentName)
+	if err != nil {
+		return Record{}, invalidField("instrument_name", err)
+	}
+
+	return Record{Option: &storage.OptionsData{
+		Exchange:        "deribit",
+		Symbol:          data.InstrumentName,
+		Market:          "options",
+		Underlying:      contract.underlying,
+		Expiration:      contract.expiration,
+		Strike:          contract.strike,
+		OptionType:      contract.optionType,
+		MarkPrice:       data.MarkPrice.String(),
+		MarkIV:          data.MarkIV.String(),
+		BidIV:           data.BidIV.String(),
+		AskIV:           data.AskIV.String(),
+		BestBidPrice:    data.BestBidPrice.String(),
+		BestAskPrice:    data.BestAskPrice.String(),
+		UnderlyingPrice: data.UnderlyingPrice.String(),
+		OpenInterest:    data.OpenInterest.String(),
+		Delta:           data.Greeks.Delta.String(),
+		Gamma:           data.Greeks.Gamma.String(),
+		Vega:            data.Greeks.Vega.String(),
+		Theta:           data.Greeks.Theta.String(),
+		Rho:             data.Greeks.Rho.String(),
+		Timestamp:       time.UnixMilli(data.Timestamp).UTC(),
+	}}, nil
+}
+
+// saveOption - сохраняет снимок опциона
+func (w *Worker) saveOption(option storage.OptionsData) error {
+	if err := w.Db.SaveOptionsData(option); err != nil {
+		return fmt.Errorf("ошибка сохранения опциона %s: %w", option.Symbol, err)
+	}
+	return nil
+}
+
+type optionContract struct {
+	underlying string
+	expiration time.Time
+	strike     string
+	optionType string
+}
+
+// parseOptionName - имя опциона Deribit: BTC-27DEC24-100000-C; дробный страйк записывается через d (0d5)
+func parseOptionName(name string) (optionContract, error) {
+	parts := strings.Split(name, "-")
+	if len(parts) != 4 {
+		return optionContract{}, fmt.Errorf("некорректное имя опциона %q", name)
+	}
+
+	expiry, err := time.Parse("2Jan06", parts[1])
+	if err != nil {
+		return optionContract{}, fmt.Errorf("некорректная дата экспирации %q: %w", name, err)
+	}
+
+	var optionType string
+	switch parts[3] {
+	case "C":
+		optionType = "call"
+	case "P":
+		optionType = "put"
+	default:
+		return optionContract{}, fmt.Errorf("некорректный тип опциона %q", name)
+	}
+
+	return optionContract{
+		underlying: parts[0],
+		// экспирация опционов Deribit в 08:00 UTC
+		expiration: expiry.Add(8 * time.Hour),
+		strike:     strings.ReplaceAll(parts[2], "d", "."),
+		optionType: optionType,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"preprocessor/internal/storage"
+	"sync"
+)
+
+// anyExchange - парсер вида сообщения, общий для всех бирж (тикер универсального коннектора, справочник, свечи)
+const anyExchange = "*"
+
+// Виды ошибок разбора; ParseError разворачивается в один из них
+var (
+	ErrMalformed    = errors.New("malformed payload")
+	ErrInvalidField = errors.New("invalid field")
+	ErrNoParser     = errors.New("no parser")
+)
+
+// ParseError - ошибка разбора сообщения; Field пуст, если сообщение не разбирается целиком
+type ParseError struct {
+	Kind  error
+	Field string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Kind, e.Field, e.Err)
+}
+
+func (e *ParseError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+func malformed(err error) error {
+	return &ParseError{Kind: ErrMalformed, Err: err}
+}
+
+func invalidField(field string, err error) error {
+	return &ParseError{Kind: ErrInvalidField, Field: field, Err: err}
+}
+
+// Record - нормализованная запись из сообщения; заполнено ровно одно поле
+type Record struct {
+	Ticker     *storage.MarketData
+	FxRate     *storage.FxRate
+	Option     *storage.OptionsData
+	Instrument *storage.Instrument
+	Candle     *storage.HistoricalData
+}
+
+// Parser - разбирает сообщения одного вида одной биржи
+type Parser interface {
+	Parse(body []byte) (Record, error)
+}
+
+// ParserFunc - функция как Parser
+type ParserFunc func(body []byte) (Record, error)
+
+func (f ParserFunc) Parse(body []byte) (Record, error) {
+	return f(body)
+}
+
+type parserKey struct {
+	exchange, kind string
+}
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = make(map[parserKey]Parser)
+)
+
+// RegisterParser - регистрирует парсер сообщений вида kind биржи exchange; "*" - любой биржи.
+// Повторная регистрация заменяет парсер.
+func RegisterParser(exchange, kind string, p Parser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[parserKey{exchange, kind}] = p
+}
+
+// LookupParser - парсер биржи, а если его нет - общий парсер вида сообщения
+func LookupParser(exchange, kind string) (Parser, error) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+
+	if p, ok := parsers[parserKey{exchange, kind}]; ok {
+		return p, nil
+	}
+	if p, ok := parsers[parserKey{anyExchange, kind}]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("%w for %s message %q", ErrNoParser, exchange, kind)
+}
+
+// decodeJSON - разбор тела сообщения; ошибка возвращается как ErrMalformed
+func decodeJSON(body []byte, v any) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return malformed(err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package processor
+
+// Парсеры сообщений. Чтобы добавить биржу, достаточно описать модель ее сообщения
+// в models.go и зарегистрировать здесь функцию, переводящую модель в spotTicker.
+func init() {
+	RegisterParser("binance", KindMarketData, ParserFunc(parseBinance))
+	RegisterParser("bybit", KindMarketData, ParserFunc(parseBybit))
+	RegisterParser("okx", KindMarketData, ParserFunc(parseOkx))
+	RegisterParser("coinbase", KindMarketData, ParserFunc(parseCoinbase))
+	RegisterParser("deribit", KindMarketData, ParserFunc(parseDeribitTicker))
+	RegisterParser("fx", KindMarketData, ParserFunc(parseFxRate))
+
+	RegisterParser(anyExchange, KindTicker, ParserFunc(parseGenericTicker))
+	RegisterParser(anyExchange, KindInstrument, ParserFunc(parseInstrument))
+	RegisterParser(anyExchange, KindCandle, ParserFunc(parseCandle))
+}
+
+// parseBinance - 24hrTicker и 24hrMiniTicker; в мини-тикере нет изменения, VWAP и лучших цен
+func parseBinance(body []byte) (Record, error) {
+	var data BinanceMarketData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+	return spotTicker{
+		exchange:    "binance",
+		symbol:      data.Symbol,
+		price:       data.LastPrice,
+		volume:      data.TotalTradedBaseAssetVolume,
+		high:        data.HighPrice,
+		low:         data.LowPrice,
+		open:        data.OpenPrice,
+		vwap:        data.WeightedAveragePrice,
+		quoteVolume: data.TotalTradedQuoteAssetVolume,
+		bid:         data.BestBidPrice,
+		bidSize:     data.BestBidQuantity,
+		ask:         data.BestAskPrice,
+		askSize:     data.BestAskQuantity,
+	}.record()
+}
+
+// parseBybit - price24hPcnt - доля, а не проценты, поэтому изменение считается от prevPrice24h
+func parseBybit(body []byte) (Record, error) {
+	var data BybitMarketData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+	return spotTicker{
+		exchange:    "bybit",
+		symbol:      data.Symbol,
+		price:       data.LastPrice,
+		volume:      data.Volume24h,
+		high:        data.HighPrice24h,
+		low:         data.LowPrice24h,
+		open:        data.PrevPrice24h,
+		quoteVolume: data.Turnover24h,
+	}.record()
+}
+
+// parseOkx - для спота vol24h - объем в базовой валюте, volCcy24h - в валюте котировки
+func parseOkx(body []byte) (Record, error) {
+	var data OkxMarketData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+	return spotTicker{
+		exchange:    "okx",
+		symbol:      data.InstID,
+		price:       data.Last,
+		volume:      data.Vol24h,
+		high:        data.High24h,
+		low:         data.Low24h,
+		open:        data.Open24h,
+		quoteVolume: data.VolCcy24h,
+		bid:         data.BidPx,
+		bidSize:     data.BidSz,
+		ask:         data.AskPx,
+		askSize:     data.AskSz,
+	}.record()
+}
+
+// parseCoinbase - оборот в валюте котировки Coinbase не передает, VWAP и объем котировки остаются пустыми
+func parseCoinbase(body []byte) (Record, error) {
+	var data CoinbaseMarketData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+	return spotTicker{
+		exchange: "coinbase",
+		symbol:   data.ProductID,
+		price:    data.Price,
+		volume:   data.Volume24h,
+		high:     data.High24h,
+		low:      data.Low24h,
+		open:     data.Open24h,
+		bid:      data.BestBid,
+		bidSize:  data.BestBidSize,
+		ask:      data.BestAsk,
+		askSize:  data.BestAskSize,
+	}.record()
+}
+
+// parseGenericTicker - тикер универсального коннектора и DEX; объем и диапазон цены есть не во всех
+// спецификациях, а изменение передается только в процентах
+func parseGenericTicker(body []byte) (Record, error) {
+	var data GenericMarketData
+	if err := decodeJSON(body, &data); err != nil {
+		return Record{}, err
+	}
+	return spotTicker{
+		exchange:      data.Exchange,
+		symbol:        data.Symbol,
+		market:        data.Market,
+		price:         data.Price,
+		volume:        data.Volume,
+		high:          data.High,
+		low:           data.Low,
+		optionalRange: true,
+		changePercent: data.ChangePercent,
+	}.record()
+}
@@ -2,39 +2,87 @@ package processor
 
 import (
 	"log"
-	"math/rand/v2"
 	"preprocessor/internal/config"
 	"preprocessor/internal/storage"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+// jobsBufferSize - сообщения ждут свободного воркера в общей очереди такого размера
+const jobsBufferSize = 100
+
 type Processor struct {
-	Cfg            *config.Config
-	Db             *storage.Storage
-	Conn           *amqp.Connection
-	Ch             *amqp.Channel
-	workers        []*Worker
-	workerChannels []chan amqp.Delivery
-	mu             sync.Mutex
-	wg             sync.WaitGroup
+	Cfg  *config.Config
+	Db   *storage.Storage
+	Conn *amqp.Connection
+	Ch   *amqp.Channel
+	// Pub - отдельный канал публикации повторов и dead-letter сообщений
+	Pub   Publisher
+	pubMu sync.Mutex
+	// jobs - общая очередь воркеров: сообщение забирает первый освободившийся воркер
+	jobs    chan amqp.Delivery
+	workers map[int]*Worker
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	// processed - число обработанных сообщений всеми воркерами, по нему считается пропускная способность
+	processed atomic.Int64
+
+	stablecoinMu    sync.Mutex
+	stablecoinSaved map[string]time.Time
+
+	candles    *CandleAggregator
+	quality    *QualityValidator
+	conflation *Conflator
+
+	// assets - приведение символов к канонической паре; pairsSaved - тикеры с уже записанной парой
+	assets     *AssetRegistry
+	pairsMu    sync.Mutex
+	pairsSaved map[instrumentKey]bool
+
+	usd *UsdConverter
 }
 
 func NewProcessor(cfg *config.Config, db *storage.Storage) (*Processor, error) {
+	var rules []QualityRule
+	if cfg.Preprocessor.QualityRules != "" {
+		var err error
+		if rules, err = LoadQualityRules(cfg.Preprocessor.QualityRules); err != nil {
+			return nil, err
+		}
+	}
+
+	var conflation ConflationRule
+	if cfg.Preprocessor.ConflationRules != "" {
+		var err error
+		if conflation, err = LoadConflationRule(cfg.Preprocessor.ConflationRules, cfg.Preprocessor.Exchange); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Processor{
-		Cfg:            cfg,
-		Db:             db,
-		Conn:           nil,
-		Ch:             nil,
-		workers:        make([]*Worker, 0),
-		workerChannels: make([]chan amqp.Delivery, 0),
+		Cfg:             cfg,
+		Db:              db,
+		Conn:            nil,
+		Ch:              nil,
+		jobs:            make(chan amqp.Delivery, jobsBufferSize),
+		workers:         make(map[int]*Worker),
+		stablecoinSaved: make(map[string]time.Time),
+		candles:         NewCandleAggregator(cfg.Preprocessor.CandleGrace),
+		quality:         NewQualityValidator(rules),
+		conflation:      NewConflator(conflation),
+		assets:          NewAssetRegistry(),
+		pairsSaved:      make(map[instrumentKey]bool),
+		usd:             NewUsdConverter(),
 	}, nil
 }
 
 func (p *Processor) ProcessMessages(initialWorkerCount int) {
 	msgs, err := p.Ch.Consume(
-		p.Cfg.Preprocessor.Queue, "", true, false, false, false, nil,
+		p.Cfg.Preprocessor.Queue, "", false, false, false, false, nil,
 	)
 	if err != nil {
 		log.Fatal("Ошибка подписки на очередь:", err)
@@ -44,67 +92,35 @@ func (p *Processor) ProcessMessages(initialWorkerCount int) {
 		p.AddWorker()
 	}
 
-	const batchSize = 10
-	batch := make([]amqp.Delivery, 0, batchSize)
-
 	go func() {
 		for msg := range msgs {
-			batch = append(batch, msg)
-			if len(batch) >= batchSize {
-				p.mu.Lock()
-				if len(p.workerChannels) > 0 {
-					idx := rand.IntN(len(p.workerChannels))
-					for _, m := range batch {
-						p.workerChannels[idx] <- m
-					}
-				}
-				p.mu.Unlock()
-				batch = batch[:0]
-			}
-		}
-
-		if len(batch) > 0 {
-			p.mu.Lock()
-			if len(p.workerChannels) > 0 {
-				idx := rand.IntN(len(p.workerChannels))
-				for _, m := range batch {
-					p.workerChannels[idx] <- m
-				}
-			}
-			p.mu.Unlock()
+			p.jobs <- msg
 		}
-		p.mu.Lock()
-		for _, ch := range p.workerChannels {
-			close(ch)
-		}
-		p.mu.Unlock()
+		// воркеры дочитывают оставшиеся сообщения и завершаются
+		close(p.jobs)
 	}()
 
 	log.Println("Запуск обработчиков")
 	p.wg.Wait()
 }
 
+// AddWorker - запускает воркер с наименьшим свободным ID
 func (p *Processor) AddWorker() *Worker {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	id := len(p.workers)
-	ch := make(chan amqp.Delivery, 100)
+	id := 0
+	for p.workers[id] != nil {
+		id++
+	}
 	worker := &Worker{
 		Id:        id,
-		Jobs:      ch,
+		Jobs:      p.jobs,
 		Db:        p.Db,
 		Processor: p,
-		Peers:     make([]*Worker, 0, len(p.workers)),
-	}
-
-	for _, w := range p.workers {
-		w.Peers = append(w.Peers, worker)
-		worker.Peers = append(worker.Peers, w)
+		quit:      make(chan struct{}),
 	}
-
-	p.workers = append(p.workers, worker)
-	p.workerChannels = append(p.workerChannels, ch)
+	p.workers[id] = worker
 
 	p.wg.Add(1)
 	go func(w *Worker) {
@@ -116,28 +132,38 @@ func (p *Processor) AddWorker() *Worker {
 	return worker
 }
 
+// RemoveWorker - останавливает воркер по ID. Воркер записывает накопленную пачку
+// и завершается, необработанные сообщения общей очереди достаются остальным.
 func (p *Processor) RemoveWorker(id int) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if id < 0 || id >= len(p.workers) {
+	worker, ok := p.workers[id]
+	if !ok {
 		return
 	}
+	close(worker.quit)
+	delete(p.workers, id)
 
-	close(p.workerChannels[id])
+	log.Printf("Удален воркер %d", id)
+}
 
-	p.workers = append(p.workers[:id], p.workers[id+1:]...)
-	p.workerChannels = append(p.workerChannels[:id], p.workerChannels[id+1:]...)
+// WorkerCount - число работающих воркеров
+func (p *Processor) WorkerCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.workers)
+}
 
-	for _, w := range p.workers {
-		newPeers := make([]*Worker, 0, len(p.workers)-1)
-		for _, peer := range w.Peers {
-			if peer.Id != id {
-				newPeers = append(newPeers, peer)
-			}
-		}
-		w.Peers = newPeers
-	}
+// workerIDs - ID работающих воркеров по возрастанию
+func (p *Processor) workerIDs() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	log.Printf("Удален воркер %d", id)
+	ids := make([]int, 0, len(p.workers))
+	for id := range p.workers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
 }
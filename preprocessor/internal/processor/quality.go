@@ -0,0 +1,274 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"preprocessor/internal/storage"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Правила проверки тиков
+const (
+	RuleHighBelowLow      = "high_below_low"
+	RulePriceOutsideRange = "price_outside_range"
+	RuleZeroVolume        = "zero_volume"
+	RulePriceJump         = "price_jump"
+)
+
+// Действия правила: reject - тик только в карантин, flag - тик сохраняется и копируется в карантин
+const (
+	ActionOff    = "off"
+	ActionReject = "reject"
+	ActionFlag   = "flag"
+)
+
+// QualityRule - проверки тиков биржи и рынка; пустое действие не переопределяет менее точное правило
+type QualityRule struct {
+	Exchange          string  `yaml:"exchange"`
+	Market            string  `yaml:"market"`
+	HighBelowLow      string  `yaml:"high_below_low"`
+	PriceOutsideRange string  `yaml:"price_outside_range"`
+	ZeroVolume        string  `yaml:"zero_volume"`
+	PriceJump         string  `yaml:"price_jump"`
+	MaxJumpPercent    float64 `yaml:"max_jump_percent"`
+}
+
+// Violation - нарушенное тиком правило
+type Violation struct {
+	Rule   string
+	Action string
+	Detail string
+}
+
+// QualityStats - число тиков, отклоненных и помеченных каждым правилом
+type QualityStats struct {
+	Rejected map[string]int64
+	Flagged  map[string]int64
+}
+
+// QualityValidator - проверяет тики по правилам. Для скачка цены помнит последнюю
+// принятую цену тикера; новый уровень принимается, если его подтвердил следующий тик.
+type QualityValidator struct {
+	rules []QualityRule
+
+	mu     sync.Mutex
+	prices map[instrumentKey]*jumpState
+	stats  QualityStats
+}
+
+type jumpState struct {
+	last, candidate *big.Rat
+}
+
+// LoadQualityRules - читает правила проверки тиков из YAML файла
+func LoadQualityRules(path string) ([]QualityRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read quality rules: %w", err)
+	}
+
+	var file struct {
+		Rules []QualityRule `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse quality rules %s: %w", path, err)
+	}
+
+	for i, r := range file.Rules {
+		for _, action := range []string{r.HighBelowLow, r.PriceOutsideRange, r.ZeroVolume, r.PriceJump} {
+			switch action {
+			case "", ActionOff, ActionReject, ActionFlag:
+			default:
+				return nil, fmt.Errorf("rule %d: unknown action %q", i, action)
+			}
+		}
+		if r.MaxJumpPercent < 0 {
+			return nil, fmt.Errorf("rule %d: max_jump_percent must not be negative", i)
+		}
+	}
+	return file.Rules, nil
+}
+
+func NewQualityValidator(rules []QualityRule) *QualityValidator {
+	return &QualityValidator{
+		rules:  rules,
+		prices: make(map[instrumentKey]*jumpState),
+		stats:  QualityStats{Rejected: make(map[string]int64), Flagged: make(map[string]int64)},
+	}
+}
+
+// ruleFor - проверки для биржи и рынка: правила применяются от общих к точным
+func (v *QualityValidator) ruleFor(exchange, market string) QualityRule {
+	matching := make([]QualityRule, 0, len(v.rules))
+	for _, r := range v.rules {
+		if matchesRule(r.Exchange, exchange) && matchesRule(r.Market, market) {
+			matching = append(matching, r)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return ruleSpecificity(matching[i]) < ruleSpecificity(matching[j])
+	})
+
+	var rule QualityRule
+	for _, r := range matching {
+		rule.HighBelowLow = overrideAction(rule.HighBelowLow, r.HighBelowLow)
+		rule.PriceOutsideRange = overrideAction(rule.PriceOutsideRange, r.PriceOutsideRange)
+		rule.ZeroVolume = overrideAction(rule.ZeroVolume, r.ZeroVolume)
+		rule.PriceJump = overrideAction(rule.PriceJump, r.PriceJump)
+		if r.MaxJumpPercent > 0 {
+			rule.MaxJumpPercent = r.MaxJumpPercent
+		}
+	}
+	return rule
+}
+
+func matchesRule(pattern, value string) bool {
+	return pattern == "" || pattern == "*" || pattern == value
+}
+
+// ruleSpecificity - биржа точнее рынка, поэтому правило биржи переопределяет правило рынка
+func ruleSpecificity(r QualityRule) int {
+	n := 0
+	if r.Exchange != "" && r.Exchange != "*" {
+		n += 2
+	}
+	if r.Market != "" && r.Market != "*" {
+		n++
+	}
+	return n
+}
+
+func overrideAction(current, next string) string {
+	if next == "" {
+		return current
+	}
+	return next
+}
+
+// Check - нарушенные тиком правила. Отсутствующие максимум, минимум и объем не проверяются,
+// как и нулевой диапазон цены: часть бирж передает нули вместо диапазона.
+func (v *QualityValidator) Check(tick storage.MarketData) []Violation {
+	rule := v.ruleFor(tick.Exchange, tick.Market)
+	_, price, err := parseDecimal(tick.Price)
+	if err != nil {
+		return nil
+	}
+	_, high, highErr := parseDecimal(tick.High)
+	_, low, lowErr := parseDecimal(tick.Low)
+	_, volume, volumeErr := parseDecimal(tick.Volume)
+	hasRange := highErr == nil && lowErr == nil && (high.Sign() != 0 || low.Sign() != 0)
+
+	var violations []Violation
+	add := func(name, action, format string, args ...interface{}) {
+		if action == "" || action == ActionOff {
+			return
+		}
+		violations = append(violations, Violation{Rule: name, Action: action, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if hasRange && high.Cmp(low) < 0 {
+		add(RuleHighBelowLow, rule.HighBelowLow, "high %s < low %s", tick.High, tick.Low)
+	} else if hasRange && (price.Cmp(low) < 0 || price.Cmp(high) > 0) {
+		add(RulePriceOutsideRange, rule.PriceOutsideRange, "price %s outside [%s, %s]", tick.Price, tick.Low, tick.High)
+	}
+	if volumeErr == nil && volume.Sign() == 0 {
+		add(RuleZeroVolume, rule.ZeroVolume, "zero volume")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if rule.PriceJump != "" && rule.PriceJump != ActionOff && rule.MaxJumpPercent > 0 {
+		if jump, ok := v.checkJump(tick, price, rule); !ok {
+			add(RulePriceJump, rule.PriceJump, "price %s jumped %s%% from previous tick", tick.Price, jump)
+		}
+	}
+
+	for _, violation := range violations {
+		if violation.Action == ActionReject {
+			v.stats.Rejected[violation.Rule]++
+		} else {
+			v.stats.Flagged[violation.Rule]++
+		}
+	}
+	return violations
+}
+
+// checkJump - изменение цены относительно последней принятой; цена, не прошедшая проверку,
+// запоминается кандидатом и принимается, если следующий тик близок к ней
+func (v *QualityValidator) checkJump(tick storage.MarketData, price *big.Rat, rule QualityRule) (string, bool) {
+	key := instrumentKey{tick.Exchange, tick.Symbol, tick.Market}
+	state, ok := v.prices[key]
+	if !ok {
+		v.prices[key] = &jumpState{last: price}
+		return "", true
+	}
+
+	limit := new(big.Rat).SetFloat64(rule.MaxJumpPercent)
+	jump := percentChange(state.last, price)
+	if jump.Cmp(limit) <= 0 {
+		state.last, state.candidate = price, nil
+		return "", true
+	}
+	if state.candidate != nil && percentChange(state.candidate, price).Cmp(limit) <= 0 {
+		// два тика подряд на новом уровне - цена действительно изменилась
+		state.last, state.candidate = price, nil
+		return "", true
+	}
+	state.candidate = price
+	return jump.FloatString(1), false
+}
+
+// percentChange - модуль изменения цены в процентах
+func percentChange(from, to *big.Rat) *big.Rat {
+	change := new(big.Rat).Sub(to, from)
+	change.Abs(change).Quo(change, from)
+	return change.Mul(change, big.NewRat(100, 1))
+}
+
+// Stats - копия счетчиков правил
+func (v *QualityValidator) Stats() QualityStats {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	stats := QualityStats{Rejected: make(map[string]int64), Flagged: make(map[string]int64)}
+	for rule, n := range v.stats.Rejected {
+		stats.Rejected[rule] = n
+	}
+	for rule, n := range v.stats.Flagged {
+		stats.Flagged[rule] = n
+	}
+	return stats
+}
+
+// QualityStats - счетчики отклоненных и помеченных тиков по правилам
+func (p *Processor) QualityStats() QualityStats {
+	return p.quality.Stats()
+}
+
+// quarantine - записи карантина с нарушениями тика и исходным сообщением
+func (w *Worker) quarantine(tick storage.MarketData, body []byte, violations []Violation) []storage.QuarantineEntry {
+	entries := make([]storage.QuarantineEntry, len(violations))
+	for i, violation := range violations {
+		entries[i] = storage.QuarantineEntry{
+			Exchange: tick.Exchange,
+			Symbol:   tick.Symbol,
+			Market:   tick.Market,
+			Rule:     violation.Rule,
+			Action:   violation.Action,
+			Detail:   violation.Detail,
+			Payload:  body,
+			TickTime: tick.Timestamp,
+		}
+		log.Printf("Worker %d: Тик %s %s нарушил правило %s (%s): %s", w.Id, tick.Exchange, tick.Symbol, violation.Rule, violation.Action, violation.Detail)
+	}
+	return entries
+}
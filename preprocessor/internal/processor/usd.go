@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"preprocessor/internal/storage"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usdAsset - валюта, в которую пересчитываются цены
+const usdAsset = "USD"
+
+// crossAssets - криптовалюты, через цены которых пересчитываются пары с криптовалютной котировкой (ETH/BTC)
+var crossAssets = []string{"BTC", "ETH"}
+
+const (
+	// maxUsdHops - самая длинная цепочка курсов: ETH/BTC > BTC/USDT > USDT/USD
+	maxUsdHops = 3
+	// usdPriceExtraScale - знаков цены в USD больше, чем в исходной цене; оборот округляется до центов
+	usdPriceExtraScale = 4
+	usdVolumeScale     = 2
+	// crossRateMaxAge - цены криптовалют старше не используются; рыночные данные хранятся недолго
+	crossRateMaxAge = 5 * time.Minute
+)
+
+// UsdConversion - курс валюты к USD и цепочка курсов, по которой он получен
+type UsdConversion struct {
+	Rate *big.Rat
+	Path string
+}
+
+// UsdConverter - пересчитывает цены тиков в USD по последним курсам валют, стейблкоинов и криптовалют.
+// Для каждой валюты выбирается самая короткая цепочка курсов до USD.
+type UsdConverter struct {
+	mu    sync.RWMutex
+	rates map[string]UsdConversion
+}
+
+func NewUsdConverter() *UsdConverter {
+	return &UsdConverter{rates: map[string]UsdConversion{
+		usdAsset: {Rate: big.NewRat(1, 1), Path: usdAsset},
+	}}
+}
+
+type rateEdge struct {
+	to     string
+	factor *big.Rat // 1 единица валюты = factor единиц to
+	label  string
+}
+
+// SetRates - заменяет курсы; курс base/quote - цена одной единицы base в quote
+func (c *UsdConverter) SetRates(rates []storage.FxRate) {
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Base != rates[j].Base {
+			return rates[i].Base < rates[j].Base
+		}
+		return rates[i].Quote < rates[j].Quote
+	})
+
+	edges := make(map[string][]rateEdge)
+	for _, r := range rates {
+		_, rate, err := parseDecimal(r.Rate)
+		if err != nil || rate.Sign() <= 0 {
+			continue
+		}
+		base, quote := strings.ToUpper(r.Base), strings.ToUpper(r.Quote)
+		label := base + "/" + quote
+		if r.Source != "" {
+			label += "@" + r.Source
+		}
+		edges[base] = append(edges[base], rateEdge{to: quote, factor: rate, label: label})
+		edges[quote] = append(edges[quote], rateEdge{to: base, factor: new(big.Rat).Inv(rate), label: label})
+	}
+
+	// поиск в ширину от USD: валюта получает курс через соседа, курс которого уже известен
+	conversions := map[string]UsdConversion{usdAsset: {Rate: big.NewRat(1, 1), Path: usdAsset}}
+	level := []string{usdAsset}
+	for hop := 0; hop < maxUsdHops && len(level) > 0; hop++ {
+		var next []string
+		for _, known := range level {
+			for _, e := range edges[known] {
+				if _, ok := conversions[e.to]; ok {
+					continue
+				}
+				// 1 known = factor e.to, значит 1 e.to = rate(known) / factor USD
+				path := e.label
+				if known != usdAsset {
+					path += ">" + conversions[known].Path
+				}
+				conversions[e.to] = UsdConversion{
+					Rate: new(big.Rat).Quo(conversions[known].Rate, e.factor),
+					Path: path,
+				}
+				next = append(next, e.to)
+			}
+		}
+		level = next
+	}
+
+	c.mu.Lock()
+	c.rates = conversions
+	c.mu.Unlock()
+}
+
+// Lookup - курс валюты к USD
+func (c *UsdConverter) Lookup(asset string) (UsdConversion, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	conv, ok := c.rates[strings.ToUpper(asset)]
+	return conv, ok
+}
+
+// Convert - дополняет тик ценой и суточным оборотом в USD; без курса котировки тик не меняется.
+// Оборот берется в валюте котировки, а если биржа его не передает - как объем, умноженный на цену;
+// без объема оборот остается пустым.
+func (c *UsdConverter) Convert(tick storage.MarketData, quote string) storage.MarketData {
+	conv, ok := c.Lookup(quote)
+	if !ok {
+		return tick
+	}
+	text, price, err := parseDecimal(tick.Price)
+	if err != nil {
+		return tick
+	}
+
+	_, fraction, _ := strings.Cut(text, ".")
+	tick.PriceUSD = trimZeros(new(big.Rat).Mul(price, conv.Rate).FloatString(len(fraction) + usdPriceExtraScale))
+	tick.UsdPath = conv.Path
+
+	var turnover *big.Rat
+	if _, quoteVolume, err := parseDecimal(tick.QuoteVolume); err == nil {
+		turnover = quoteVolume
+	} else if _, volume, err := parseDecimal(tick.Volume); err == nil {
+		turnover = volume.Mul(volume, price)
+	}
+	if turnover != nil {
+		tick.VolumeUSD = trimZeros(turnover.Mul(turnover, conv.Rate).FloatString(usdVolumeScale))
+	}
+	return tick
+}
+
+// Refresh - перечитывает курсы валют и цены криптовалют; курсы валют старше fxMaxAge не используются
+func (c *UsdConverter) Refresh(db *storage.Storage, fxMaxAge time.Duration) error {
+	now := time.Now()
+	fx, err := db.LoadLatestFxRates(now.Add(-fxMaxAge))
+	if err != nil {
+		return err
+	}
+	cross, err := db.LoadCrossRates(crossAssets, now.Add(-crossRateMaxAge), CompositeExchange)
+	if err != nil {
+		return err
+	}
+	c.SetRates(append(fx, cross...))
+	return nil
+}
+
+// Run - обновляет курсы каждые interval до отмены ctx; нулевой interval отключает пересчет в USD
+func (c *UsdConverter) Run(ctx context.Context, db *storage.Storage, interval, fxMaxAge time.Duration) {
+	if interval == 0 {
+		return
+	}
+	if err := c.Refresh(db, fxMaxAge); err != nil {
+		log.Printf("Ошибка загрузки курсов USD: %s", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Refresh(db, fxMaxAge); err != nil {
+				log.Printf("Ошибка загрузки курсов USD: %s", err)
+			}
+		}
+	}
+}
+
+// RunUsdRates - периодически обновляет курсы пересчета в USD
+func (p *Processor) RunUsdRates(ctx context.Context) {
+	p.usd.Run(ctx, p.Db, p.Cfg.Preprocessor.UsdRatesInterval, p.Cfg.Preprocessor.UsdFxMaxAge)
+}
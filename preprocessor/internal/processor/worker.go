@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"fmt"
 	"log"
 	"preprocessor/internal/storage"
 	"time"
@@ -8,61 +9,202 @@ import (
 	"github.com/streadway/amqp"
 )
 
+// flushCheckInterval - наименьший период проверки порога FlushInterval у простаивающего воркера
+const flushCheckInterval = 100 * time.Millisecond
+
 type Worker struct {
-	Id        int
-	Jobs      chan amqp.Delivery
+	Id int
+	// Jobs - общая очередь процессора, из которой читают все воркеры
+	Jobs      <-chan amqp.Delivery
 	Db        *storage.Storage
 	Processor *Processor
-	Peers     []*Worker
+
+	// quit закрывается при удалении воркера
+	quit chan struct{}
+
+	// pending - тикеры и их нарушения, ожидающие записи пачкой; их сообщения подтверждаются после записи
+	pending      []pendingRow
+	batchStarted time.Time
 }
 
+type pendingRow struct {
+	msg  amqp.Delivery
+	data storage.MarketData
+	// quarantine - нарушения правил качества; отклоненный тик пишется только в карантин
+	quarantine []storage.QuarantineEntry
+	rejected   bool
+}
+
+// ProcessMessages - обрабатывает сообщения общей очереди, пока воркер не удален или очередь
+// не закрыта; перед выходом накопленная пачка записывается
 func (w *Worker) ProcessMessages() {
+	flushTicker := time.NewTicker(max(w.Processor.Cfg.Preprocessor.FlushInterval, flushCheckInterval))
+	defer flushTicker.Stop()
+
 	for {
 		select {
+		case <-w.quit:
+			w.flush()
+			log.Printf("Worker %d: Остановлен", w.Id)
+			return
 		case msg, ok := <-w.Jobs:
 			if !ok {
+				w.flush()
 				log.Printf("Worker %d: Канал закрыт, завершение работы", w.Id)
 				return
 			}
-			w.processMessage(msg)
-		default:
-			stole := false
-			for _, peer := range w.Peers {
-				select {
-				case msg, ok := <-peer.Jobs:
-					if ok {
-						log.Printf("Worker %d: Украл задачу у Worker %d", w.Id, peer.Id)
-						w.processMessage(msg)
-						stole = true
-					}
-				default:
-					continue
-				}
-
-			}
-			if !stole {
-				time.Sleep(10 * time.Second)
+			w.handle(msg)
+			w.Processor.processed.Add(1)
+		case <-flushTicker.C:
+			// новых сообщений может не быть, неполная пачка записывается по времени
+			if len(w.pending) > 0 && time.Since(w.batchStarted) >= w.Processor.Cfg.Preprocessor.FlushInterval {
+				w.flush()
 			}
 		}
 	}
 }
 
-func (w *Worker) processMessage(msg amqp.Delivery) {
-	consumedMessage, err := w.Processor.ConsumeMessage(msg.Body)
-	if err != nil {
-		log.Printf("Worker %d: Ошибка обработки сообщения: %s", w.Id, err)
+// handle - тикеры откладываются до записи пачкой, остальные сообщения подтверждаются сразу
+func (w *Worker) handle(msg amqp.Delivery) {
+	row, err := w.processMessage(msg)
+	if err != nil || row == nil {
+		w.settle(msg, err)
 		return
 	}
 
-	processedData := w.ProcessFloatsByExchange(consumedMessage)
-	if processedData == (storage.MarketData{}) {
-		log.Printf("Worker %d: Не удалось обработать сообщение: %+v", w.Id, consumedMessage)
+	// прореженный тик не записывается, но учитывается в свечах, чтобы не терять максимум и минимум;
+	// тики с нарушениями не прореживаются, чтобы нарушения попали в карантин
+	if len(row.quarantine) == 0 && !w.Processor.conflation.Accept(row.data) {
+		w.Processor.candles.Add(row.data, time.Now())
+		w.ack(msg)
 		return
 	}
-	err = w.Db.SaveMarketData(processedData)
-	if err != nil {
-		log.Printf("Worker %d: Ошибка сохранения данных: %s", w.Id, err)
+
+	if len(w.pending) == 0 {
+		w.batchStarted = time.Now()
+	}
+	w.pending = append(w.pending, *row)
+
+	cfg := w.Processor.Cfg.Preprocessor
+	if len(w.pending) >= cfg.BatchSize || time.Since(w.batchStarted) >= cfg.FlushInterval {
+		w.flush()
+	}
+}
+
+// flush - записывает накопленные нарушения и тикеры и подтверждает их сообщения после записи;
+// при ошибке вся пачка возвращается в очередь после одной задержки
+func (w *Worker) flush() {
+	if len(w.pending) == 0 {
 		return
 	}
-	log.Printf("Worker %d: Обработано и сохранено в DB (%s): %+v", w.Id, w.Processor.Cfg.Preprocessor.Exchange, processedData)
+
+	var (
+		entries []storage.QuarantineEntry
+		written []pendingRow
+		data    []storage.MarketData
+	)
+	for _, row := range w.pending {
+		entries = append(entries, row.quarantine...)
+		if !row.rejected {
+			written = append(written, row)
+			data = append(data, row.data)
+		}
+	}
+
+	started := time.Now()
+	err := w.Db.SaveQuarantine(entries)
+	if err != nil {
+		err = fmt.Errorf("ошибка сохранения в карантин: %w", err)
+	} else if err = w.Db.SaveMarketDataBatch(data); err != nil {
+		err = fmt.Errorf("ошибка сохранения данных: %w", err)
+	}
+	if err != nil {
+		retries := 0
+		for _, row := range w.pending {
+			retries = max(retries, RetryCount(row.msg))
+		}
+		w.backoff(retries)
+		for _, row := range w.pending {
+			w.reject(row.msg, err)
+		}
+		w.Processor.conflation.Forget(data)
+	} else {
+		w.Processor.conflation.recordWritten(len(data))
+		now := time.Now()
+		for _, row := range w.pending {
+			w.ack(row.msg)
+		}
+		for _, row := range written {
+			w.deriveStablecoinRate(row.data)
+			w.Processor.candles.Add(row.data, now)
+		}
+		w.recordPairs(written)
+		log.Printf("Worker %d: Сохранено %d тикеров и %d нарушений (%s) за %s", w.Id, len(data), len(entries), w.Processor.Cfg.Preprocessor.Exchange, time.Since(started))
+	}
+
+	clear(w.pending)
+	w.pending = w.pending[:0]
+}
+
+// processMessage - разбирает сообщение парсером биржи и сохраняет запись или возвращает тикер
+// для записи пачкой; ошибка означает, что сообщение не подтверждается
+func (w *Worker) processMessage(msg amqp.Delivery) (*pendingRow, error) {
+	exchange := w.Processor.Cfg.Preprocessor.Exchange
+	if msg.Type == KindSequenceGap {
+		log.Printf("Worker %d: Нарушение последовательности (%s): %s", w.Id, exchange, msg.Body)
+		return nil, nil
+	}
+
+	parser, err := LookupParser(exchange, msg.Type)
+	if err != nil {
+		return nil, invalidMessage("%s", err)
+	}
+	record, err := parser.Parse(msg.Body)
+	if err != nil {
+		return nil, invalidMessage("ошибка разбора сообщения %s: %s", exchange, err)
+	}
+
+	switch {
+	case record.Ticker != nil:
+		return w.processTicker(msg, *record.Ticker)
+	case record.FxRate != nil:
+		return nil, w.saveFxRate(*record.FxRate)
+	case record.Option != nil:
+		return nil, w.saveOption(*record.Option)
+	case record.Instrument != nil:
+		return nil, w.saveInstrument(*record.Instrument)
+	case record.Candle != nil:
+		return nil, w.saveCandle(*record.Candle)
+	default:
+		return nil, invalidMessage("парсер %s вернул пустую запись", exchange)
+	}
+}
+
+// processTicker - проверяет тикер правилами качества; прошедший проверку тикер пересчитывается в USD.
+// Тикер и его нарушения откладываются для записи пачкой, отклоненный тик пишется только в карантин.
+func (w *Worker) processTicker(msg amqp.Delivery, tick storage.MarketData) (*pendingRow, error) {
+	tick.Timestamp = tickTime(msg, time.Now())
+
+	row := &pendingRow{msg: msg}
+	if violations := w.Processor.quality.Check(tick); len(violations) > 0 {
+		row.quarantine = w.quarantine(tick, msg.Body, violations)
+		for _, violation := range violations {
+			row.rejected = row.rejected || violation.Action == ActionReject
+		}
+	}
+
+	if pair, ok := w.Processor.assets.Resolve(tick.Exchange, tick.Symbol, tick.Market); ok && !row.rejected {
+		tick = w.Processor.usd.Convert(tick, pair.Quote)
+	}
+	row.data = tick
+	return row, nil
+}
+
+// tickTime - время тика; если сообщение пролежало в очереди (повтор, отставание), берется
+// время публикации коннектором, хотя AMQP хранит его с точностью до секунды
+func tickTime(msg amqp.Delivery, now time.Time) time.Time {
+	if !msg.Timestamp.IsZero() && msg.Timestamp.Before(now.Add(-time.Second)) {
+		return msg.Timestamp.UTC()
+	}
+	return now.UTC()
 }
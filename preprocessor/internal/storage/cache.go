@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tickerChangesChannel - канал NOTIFY, в который триггеры базы отправляют ID переименованного
+// или деактивированного тикера
+const tickerChangesChannel = "tickers_changed"
+
+// TickerCacheStats - счетчики кэша ID тикеров
+type TickerCacheStats struct {
+	Hits   int64
+	Misses int64
+	Size   int
+}
+
+// TickerKey - уникальный ключ строки tickers
+type TickerKey struct {
+	Exchange, Symbol, Market string
+}
+
+// TickerCache - кэш (exchange, symbol, market) -> id. Промах загружается один раз:
+// остальные запросы того же ключа ждут результата загрузки, а не идут в базу.
+type TickerCache struct {
+	mu       sync.Mutex
+	ids      map[TickerKey]int64
+	inflight map[TickerKey]*tickerLoad
+	// warmed - биржи, тикеры которых уже загружены в кэш целиком
+	warmed map[string]bool
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type tickerLoad struct {
+	done chan struct{}
+	err  error
+}
+
+func NewTickerCache() *TickerCache {
+	return &TickerCache{
+		ids:      make(map[TickerKey]int64),
+		inflight: make(map[TickerKey]*tickerLoad),
+		warmed:   make(map[string]bool),
+	}
+}
+
+// Resolve - ID тикеров из кэша; отсутствующие ключи загружает load, если их не загружает другой запрос
+func (c *TickerCache) Resolve(ctx context.Context, keys []TickerKey, load func([]TickerKey) (map[TickerKey]int64, error)) (map[TickerKey]int64, error) {
+	out := make(map[TickerKey]int64, len(keys))
+	seen := make(map[TickerKey]bool, len(keys))
+	var claimed []TickerKey
+	var waits []*tickerLoad
+
+	c.mu.Lock()
+	for _, k := range keys {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		if id, ok := c.ids[k]; ok {
+			out[k] = id
+			c.hits.Add(1)
+			continue
+		}
+		c.misses.Add(1)
+		if l, ok := c.inflight[k]; ok {
+			waits = append(waits, l)
+			continue
+		}
+		claimed = append(claimed, k)
+	}
+
+	var own *tickerLoad
+	if len(claimed) > 0 {
+		own = &tickerLoad{done: make(chan struct{})}
+		for _, k := range claimed {
+			c.inflight[k] = own
+		}
+	}
+	c.mu.Unlock()
+
+	if own != nil {
+		ids, err := load(claimed)
+
+		c.mu.Lock()
+		for _, k := range claimed {
+			delete(c.inflight, k)
+			if id, ok := ids[k]; ok && err == nil {
+				c.ids[k] = id
+			}
+		}
+		c.mu.Unlock()
+
+		own.err = err
+		close(own.done)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, l := range waits {
+		select {
+		case <-l.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range seen {
+		if _, ok := out[k]; ok {
+			continue
+		}
+		id, ok := c.ids[k]
+		if !ok {
+			return nil, fmt.Errorf("no ticker id for %s %s %s", k.Exchange, k.Symbol, k.Market)
+		}
+		out[k] = id
+	}
+	return out, nil
+}
+
+func (c *TickerCache) Store(k TickerKey, id int64) {
+	c.mu.Lock()
+	c.ids[k] = id
+	c.mu.Unlock()
+}
+
+func (c *TickerCache) Invalidate(k TickerKey) {
+	c.mu.Lock()
+	delete(c.ids, k)
+	c.mu.Unlock()
+}
+
+// InvalidateID - удаляет все ключи тикера; после переименования старый ключ известен только по ID
+func (c *TickerCache) InvalidateID(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range c.ids {
+		if v == id {
+			delete(c.ids, k)
+		}
+	}
+}
+
+// HandleNotification - обрабатывает уведомление канала tickers_changed с ID измененного тикера
+func (c *TickerCache) HandleNotification(payload string) {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		log.Printf("Ticker cache: invalid notification %q", payload)
+		return
+	}
+	c.InvalidateID(id)
+}
+
+// Clear - очищает кэш; биржи будут прогреты заново
+func (c *TickerCache) Clear() {
+	c.mu.Lock()
+	clear(c.ids)
+	clear(c.warmed)
+	c.mu.Unlock()
+}
+
+// claimWarm - true, если тикеры биржи еще не загружались и загрузить их должен вызывающий
+func (c *TickerCache) claimWarm(exchange string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.warmed[exchange] {
+		return false
+	}
+	c.warmed[exchange] = true
+	return true
+}
+
+func (c *TickerCache) unclaimWarm(exchange string) {
+	c.mu.Lock()
+	delete(c.warmed, exchange)
+	c.mu.Unlock()
+}
+
+func (c *TickerCache) Stats() TickerCacheStats {
+	c.mu.Lock()
+	size := len(c.ids)
+	c.mu.Unlock()
+	return TickerCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Size: size}
+}
+
+// TickerCacheStats - текущие счетчики кэша ID тикеров
+func (s *Storage) TickerCacheStats() TickerCacheStats {
+	return s.tickers.Stats()
+}
+
+// WarmTickerCache - загружает в кэш все тикеры биржи процессора. Процессор может получать тикеры
+// и других бирж (пулы DEX, свечи дозагрузки), их тикеры загружаются при первой записи.
+func (s *Storage) WarmTickerCache(exchange string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	if !s.tickers.claimWarm(exchange) {
+		return nil
+	}
+	return s.warmExchange(ctx, exchange)
+}
+
+// warmExchange - загружает в кэш все тикеры биржи; при ошибке биржа будет прогрета при следующей записи
+func (s *Storage) warmExchange(ctx context.Context, exchange string) (err error) {
+	defer func() {
+		if err != nil {
+			s.tickers.unclaimWarm(exchange)
+		}
+	}()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, exchange, symbol, market FROM tickers WHERE exchange = $1
+	`, exchange)
+	if err != nil {
+		return fmt.Errorf("failed to load tickers: %w", err)
+	}
+	defer rows.Close()
+
+	loaded := 0
+	for rows.Next() {
+		var id int64
+		var k TickerKey
+		if err := rows.Scan(&id, &k.Exchange, &k.Symbol, &k.Market); err != nil {
+			return fmt.Errorf("failed to scan ticker: %w", err)
+		}
+		s.tickers.Store(k, id)
+		loaded++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load tickers: %w", err)
+	}
+
+	log.Printf("Ticker cache: loaded %d tickers for %s", loaded, exchange)
+	return nil
+}
+
+// WatchTickerChanges - слушает уведомления об изменении тикеров и сбрасывает их записи в кэше.
+// При обрыве соединения кэш очищается целиком, так как уведомления за это время потеряны.
+func (s *Storage) WatchTickerChanges(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := s.listenTickerChanges(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Ticker cache: listen error: %v", err)
+			s.tickers.Clear()
+			time.Sleep(5 * time.Second)
+		}
+	}
+}
+
+func (s *Storage) listenTickerChanges(ctx context.Context) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+tickerChangesChannel); err != nil {
+		return err
+	}
+	// соединение возвращается в пул и не должно получать уведомления у других запросов
+	defer conn.Exec(context.Background(), "UNLISTEN "+tickerChangesChannel)
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		s.tickers.HandleNotification(n.Payload)
+	}
+}
@@ -3,24 +3,111 @@ package storage
 import (
 	"context"
 	"fmt"
-	"log"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// batchWriteTimeout - предельное время записи одной пачки рыночных данных
+const batchWriteTimeout = 30 * time.Second
+
 func NewStorage(dbURL string) (*Storage, error) {
 	pool, err := pgxpool.New(context.Background(), dbURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	return &Storage{pool: pool}, nil
+	return &Storage{pool: pool, tickers: NewTickerCache()}, nil
 }
 
 func (s *Storage) Close() {
 	s.pool.Close()
 }
 
-func (s *Storage) SaveMarketData(data MarketData) error {
+// SaveMarketDataBatch - сохраняет пачку рыночных данных; пачка записывается целиком или не записывается
+func (s *Storage) SaveMarketDataBatch(data []MarketData) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	keys := make([]TickerKey, len(data))
+	for i, d := range data {
+		keys[i] = TickerKey{d.Exchange, d.Symbol, d.Market}
+	}
+
+	ids, err := s.ensureTickers(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("failed to ensure tickers exist: %w", err)
+	}
+
+	return s.copyMarketData(ctx, ids, data)
+}
+
+// SaveCandles - сохраняет свечи, собранные из тиков, после их закрытия
+func (s *Storage) SaveCandles(candles []HistoricalData) error {
+	return s.saveCandles(candles, s.upsertCandles)
+}
+
+// SaveOpenCandles - сохраняет незакрытые свечи, чтобы продолжить их после перезапуска
+func (s *Storage) SaveOpenCandles(candles []HistoricalData) error {
+	return s.saveCandles(candles, s.upsertOpenCandles)
+}
+
+func (s *Storage) saveCandles(candles []HistoricalData, save func(context.Context, map[TickerKey]int64, []HistoricalData) error) error {
+	if len(candles) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	keys := make([]TickerKey, len(candles))
+	for i, c := range candles {
+		keys[i] = TickerKey{c.Exchange, c.Symbol, c.Market}
+	}
+
+	ids, err := s.ensureTickers(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("failed to ensure tickers exist: %w", err)
+	}
+
+	return save(ctx, ids, candles)
+}
+
+// LoadOpenCandles - незакрытые свечи биржи, сохраненные перед остановкой
+func (s *Storage) LoadOpenCandles(exchange string) ([]HistoricalData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT t.exchange, t.symbol, t.market, o.timeframe, o.timestamp,
+			   o.open::text, o.high::text, o.low::text, o.close::text, o.volume::text
+		FROM open_candles o
+		JOIN tickers t ON o.ticker_id = t.id
+		WHERE t.exchange = $1
+	`, exchange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load open candles: %w", err)
+	}
+	defer rows.Close()
+
+	var candles []HistoricalData
+	for rows.Next() {
+		var c HistoricalData
+		if err := rows.Scan(&c.Exchange, &c.Symbol, &c.Market, &c.Timeframe, &c.Timestamp,
+			&c.Open, &c.High, &c.Low, &c.Close, &c.Volume); err != nil {
+			return nil, fmt.Errorf("failed to scan open candle: %w", err)
+		}
+		c.Timestamp = c.Timestamp.UTC()
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
+
+func (s *Storage) SaveHistoricalData(data HistoricalData) error {
 	ctx := context.Background()
 
 	tickerID, err := s.ensureTickerExists(ctx, data.Exchange, data.Symbol, data.Market)
@@ -28,15 +115,36 @@ func (s *Storage) SaveMarketData(data MarketData) error {
 		return fmt.Errorf("failed to ensure ticker exists: %w", err)
 	}
 
-	err = s.insertMarketData(ctx, tickerID, data)
+	err = s.insertHistoricalData(ctx, tickerID, data)
 	if err != nil {
-		return fmt.Errorf("failed to insert market data: %w", err)
+		return fmt.Errorf("failed to insert historical data: %w", err)
 	}
-	log.Printf("Market data inserted: %+v", data)
+
 	return nil
 }
 
-func (s *Storage) SaveHistoricalData(data HistoricalData) error {
+func (s *Storage) SaveInstrument(inst Instrument) error {
+	ctx := context.Background()
+
+	tickerID, err := s.ensureTickerExists(ctx, inst.Exchange, inst.Symbol, inst.Market)
+	if err != nil {
+		return fmt.Errorf("failed to ensure ticker exists: %w", err)
+	}
+
+	err = s.upsertInstrument(ctx, tickerID, inst)
+	if err != nil {
+		return fmt.Errorf("failed to upsert instrument: %w", err)
+	}
+
+	// деактивированный инструмент перечитывается из базы при следующем тикере
+	if inst.Status != "" && inst.Status != "active" {
+		s.tickers.Invalidate(TickerKey{inst.Exchange, inst.Symbol, inst.Market})
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveOptionsData(data OptionsData) error {
 	ctx := context.Background()
 
 	tickerID, err := s.ensureTickerExists(ctx, data.Exchange, data.Symbol, data.Market)
@@ -44,10 +152,181 @@ func (s *Storage) SaveHistoricalData(data HistoricalData) error {
 		return fmt.Errorf("failed to ensure ticker exists: %w", err)
 	}
 
-	err = s.insertHistoricalData(ctx, tickerID, data)
+	err = s.upsertOptionsData(ctx, tickerID, data)
 	if err != nil {
-		return fmt.Errorf("failed to insert historical data: %w", err)
+		return fmt.Errorf("failed to save options data: %w", err)
 	}
 
 	return nil
 }
+
+func (s *Storage) SaveFxRate(rate FxRate) error {
+	ctx := context.Background()
+
+	err := s.insertFxRate(ctx, rate)
+	if err != nil {
+		return fmt.Errorf("failed to insert fx rate: %w", err)
+	}
+
+	return nil
+}
+
+// SaveQuarantine - сохраняет нарушения правил качества одной пачкой
+func (s *Storage) SaveQuarantine(entries []QuarantineEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	err := s.insertQuarantine(ctx, entries)
+	if err != nil {
+		return fmt.Errorf("failed to insert quarantine: %w", err)
+	}
+
+	return nil
+}
+
+// LoadAssetRegistry - читает активы, валюты котировки и синонимы активов
+func (s *Storage) LoadAssetRegistry() (AssetRegistry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	registry := AssetRegistry{Aliases: make(map[string]string)}
+	rows, err := s.pool.Query(ctx, `SELECT code, is_quote FROM assets`)
+	if err != nil {
+		return registry, fmt.Errorf("failed to load assets: %w", err)
+	}
+	for rows.Next() {
+		var code string
+		var isQuote bool
+		if err := rows.Scan(&code, &isQuote); err != nil {
+			rows.Close()
+			return registry, fmt.Errorf("failed to scan asset: %w", err)
+		}
+		registry.Assets = append(registry.Assets, code)
+		if isQuote {
+			registry.Quotes = append(registry.Quotes, code)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return registry, fmt.Errorf("failed to load assets: %w", err)
+	}
+
+	rows, err = s.pool.Query(ctx, `SELECT alias, asset FROM asset_aliases`)
+	if err != nil {
+		return registry, fmt.Errorf("failed to load asset aliases: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias, asset string
+		if err := rows.Scan(&alias, &asset); err != nil {
+			return registry, fmt.Errorf("failed to scan asset alias: %w", err)
+		}
+		registry.Aliases[alias] = asset
+	}
+	return registry, rows.Err()
+}
+
+// SaveTickerPairs - записывает канонические пары существующих тикеров
+func (s *Storage) SaveTickerPairs(pairs []TickerPair) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	err := s.updateTickerPairs(ctx, pairs)
+	if err != nil {
+		return fmt.Errorf("failed to update ticker pairs: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPairQuotes - последняя запись каждого тикера с канонической парой, полученная не раньше since;
+// тикеры биржи exclude (ряд самой композитной цены) не читаются
+func (s *Storage) LoadPairQuotes(since time.Time, exclude string) ([]PairQuote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (m.ticker_id)
+			   t.exchange, t.symbol, t.market, t.base_asset, t.quote_asset,
+			   m.price::text, COALESCE(m.volume, 0)::text, m.timestamp
+		FROM market_data m
+		JOIN tickers t ON m.ticker_id = t.id
+		WHERE m.timestamp >= $1
+		  AND t.base_asset IS NOT NULL AND t.quote_asset IS NOT NULL
+		  AND t.exchange <> $2
+		  AND m.price IS NOT NULL
+		ORDER BY m.ticker_id, m.timestamp DESC
+	`, since.UTC(), exclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pair quotes: %w", err)
+	}
+	defer rows.Close()
+
+	var quotes []PairQuote
+	for rows.Next() {
+		var q PairQuote
+		if err := rows.Scan(&q.Exchange, &q.Symbol, &q.Market, &q.Base, &q.Quote, &q.Price, &q.Volume, &q.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan pair quote: %w", err)
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, rows.Err()
+}
+
+// LoadLatestFxRates - последний курс каждой пары валют, полученный не раньше since, от любого источника
+func (s *Storage) LoadLatestFxRates(since time.Time) ([]FxRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (base, quote) base, quote, rate::text, COALESCE(source, ''), timestamp
+		FROM fx_rates
+		WHERE timestamp >= $1 AND rate > 0
+		ORDER BY base, quote, timestamp DESC
+	`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fx rates: %w", err)
+	}
+	return scanFxRates(rows)
+}
+
+// LoadCrossRates - последняя цена каждой пары с базовым активом из bases, полученная не раньше since,
+// в виде курса base/quote; композитная цена предпочитается ценам отдельных бирж
+func (s *Storage) LoadCrossRates(bases []string, since time.Time, composite string) ([]FxRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), batchWriteTimeout)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (t.base_asset, t.quote_asset)
+			   t.base_asset, t.quote_asset, m.price::text, t.exchange, m.timestamp
+		FROM market_data m
+		JOIN tickers t ON m.ticker_id = t.id
+		WHERE t.base_asset = ANY($1) AND t.quote_asset IS NOT NULL
+		  AND m.timestamp >= $2 AND m.price > 0
+		ORDER BY t.base_asset, t.quote_asset, (t.exchange = $3) DESC, m.timestamp DESC
+	`, bases, since.UTC(), composite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cross rates: %w", err)
+	}
+	return scanFxRates(rows)
+}
+
+func scanFxRates(rows pgx.Rows) ([]FxRate, error) {
+	defer rows.Close()
+
+	var rates []FxRate
+	for rows.Next() {
+		var r FxRate
+		if err := rows.Scan(&r.Base, &r.Quote, &r.Rate, &r.Source, &r.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan rate: %w", err)
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
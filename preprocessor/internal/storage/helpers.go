@@ -2,72 +2,387 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-// ensureTickerExists - добавляет тикер, если он отсутствует, и возвращает его ID
+// ensureTickerExists - возвращает ID тикера из кэша, при промахе добавляет тикер, если он отсутствует
 func (s *Storage) ensureTickerExists(ctx context.Context, exchange, symbol, market string) (int64, error) {
-	var tickerID int64
+	k := TickerKey{exchange, symbol, market}
+	ids, err := s.ensureTickers(ctx, []TickerKey{k})
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert or fetch ticker: %w", err)
+	}
+	return ids[k], nil
+}
 
-	err := s.pool.QueryRow(ctx, `
-		SELECT id FROM tickers 
-		WHERE exchange = $1 AND symbol = $2 AND market = $3
-	`, exchange, symbol, market).Scan(&tickerID)
+// Источники свечей в historical_data.source
+const (
+	candleSourceBackfill = "backfill"
+	candleSourceTicks    = "ticks"
+)
 
-	if err == nil {
-		return tickerID, nil
+// ensureTickers - ID всех тикеров пачки; отсутствующие в кэше загружаются одним запросом.
+// Тикеры биржи, впервые встреченной процессором, сначала загружаются в кэш все сразу.
+func (s *Storage) ensureTickers(ctx context.Context, keys []TickerKey) (map[TickerKey]int64, error) {
+	for _, k := range keys {
+		if s.tickers.claimWarm(k.Exchange) {
+			if err := s.warmExchange(ctx, k.Exchange); err != nil {
+				// без прогрева тикеры загружаются по промахам
+				log.Printf("Ticker cache: %v", err)
+			}
+		}
 	}
+	return s.tickers.Resolve(ctx, keys, func(missing []TickerKey) (map[TickerKey]int64, error) {
+		return s.upsertTickers(ctx, missing)
+	})
+}
 
-	err = s.pool.QueryRow(ctx, `
-		INSERT INTO tickers (exchange, symbol, market) 
-		VALUES ($1, $2, $3)
-		ON CONFLICT (exchange, symbol, market) DO NOTHING
-		RETURNING id
-	`, exchange, symbol, market).Scan(&tickerID)
+// upsertTickers - добавляет отсутствующие тикеры и возвращает ID всех переданных тикеров
+func (s *Storage) upsertTickers(ctx context.Context, keys []TickerKey) (map[TickerKey]int64, error) {
+	exchanges := make([]string, len(keys))
+	symbols := make([]string, len(keys))
+	markets := make([]string, len(keys))
+	for i, k := range keys {
+		exchanges[i], symbols[i], markets[i] = k.Exchange, k.Symbol, k.Market
+	}
 
+	// вставленные строки не видны остальному запросу, поэтому новые ID берутся из RETURNING
+	ids, err := s.queryTickerIDs(ctx, `
+		WITH input AS (
+			SELECT DISTINCT * FROM unnest($1::text[], $2::text[], $3::text[]) AS i(exchange, symbol, market)
+		), inserted AS (
+			INSERT INTO tickers (exchange, symbol, market)
+			SELECT exchange, symbol, market FROM input
+			ON CONFLICT (exchange, symbol, market) DO NOTHING
+			RETURNING id, exchange, symbol, market
+		)
+		SELECT id, exchange, symbol, market FROM inserted
+		UNION ALL
+		SELECT t.id, t.exchange, t.symbol, t.market FROM tickers t JOIN input USING (exchange, symbol, market)
+	`, exchanges, symbols, markets)
 	if err != nil {
-		err = s.pool.QueryRow(ctx, `
-			SELECT id FROM tickers 
-			WHERE exchange = $1 AND symbol = $2 AND market = $3
-		`, exchange, symbol, market).Scan(&tickerID)
+		return nil, err
+	}
+
+	// тикер, одновременно вставленный другим воркером, пропущен ON CONFLICT DO NOTHING и не виден
+	// в снимке запроса; повторное чтение отдельным запросом видит его зафиксированную строку
+	exchanges, symbols, markets = exchanges[:0], symbols[:0], markets[:0]
+	for _, k := range keys {
+		if _, ok := ids[k]; !ok {
+			exchanges, symbols, markets = append(exchanges, k.Exchange), append(symbols, k.Symbol), append(markets, k.Market)
+		}
+	}
+	if len(exchanges) == 0 {
+		return ids, nil
+	}
+	raced, err := s.queryTickerIDs(ctx, `
+		SELECT t.id, t.exchange, t.symbol, t.market
+		FROM tickers t
+		JOIN unnest($1::text[], $2::text[], $3::text[]) AS i(exchange, symbol, market) USING (exchange, symbol, market)
+	`, exchanges, symbols, markets)
+	if err != nil {
+		return nil, err
+	}
+	for k, id := range raced {
+		ids[k] = id
+	}
+	return ids, nil
+}
+
+// queryTickerIDs - ID тикеров из строк (id, exchange, symbol, market)
+func (s *Storage) queryTickerIDs(ctx context.Context, sql string, args ...interface{}) (map[TickerKey]int64, error) {
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert tickers: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[TickerKey]int64)
+	for rows.Next() {
+		var id int64
+		var key TickerKey
+		if err := rows.Scan(&id, &key.Exchange, &key.Symbol, &key.Market); err != nil {
+			return nil, fmt.Errorf("failed to scan ticker: %w", err)
+		}
+		ids[key] = id
+	}
+	return ids, rows.Err()
+}
+
+// copyMarketData - записывает пачку рыночных данных командой COPY
+func (s *Storage) copyMarketData(ctx context.Context, ids map[TickerKey]int64, data []MarketData) error {
+	rows := make([][]interface{}, 0, len(data))
+	for _, d := range data {
+		id, ok := ids[TickerKey{d.Exchange, d.Symbol, d.Market}]
+		if !ok {
+			return fmt.Errorf("no ticker id for %s %s", d.Exchange, d.Symbol)
+		}
+
+		v, err := numerics(d.Price, d.Volume, d.High, d.Low, d.PriceChange, d.PriceChangePercent, d.VWAP, d.QuoteVolume,
+			d.Bid, d.BidSize, d.Ask, d.AskSize, d.SpreadBps, d.PriceUSD, d.VolumeUSD)
 		if err != nil {
-			return 0, fmt.Errorf("failed to insert or fetch ticker: %w", err)
+			return err
+		}
+		var usdPath pgtype.Text
+		if d.UsdPath != "" {
+			usdPath = pgtype.Text{String: d.UsdPath, Valid: true}
+		}
+
+		ts := d.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		row := make([]interface{}, 0, len(v)+3)
+		row = append(row, id)
+		for _, n := range v {
+			row = append(row, n)
 		}
+		rows = append(rows, append(row, usdPath, ts.UTC()))
+	}
+
+	_, err := s.pool.CopyFrom(ctx,
+		pgx.Identifier{"market_data"},
+		[]string{"ticker_id", "price", "volume", "high_price", "low_price", "price_change", "price_change_percent", "vwap", "quote_volume",
+			"bid", "bid_size", "ask", "ask_size", "spread_bps", "price_usd", "volume_usd", "usd_path", "timestamp"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy market data: %w", err)
+	}
+	return nil
+}
+
+// insertHistoricalData - вставляет исторические данные
+func (s *Storage) insertHistoricalData(ctx context.Context, tickerID int64, data HistoricalData) error {
+	v, err := numerics(data.Open, data.High, data.Low, data.Close, data.Volume)
+	if err != nil {
+		return err
 	}
 
-	return tickerID, nil
+	// свеча биржи полная и заменяет свечу, собранную из тиков
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO historical_data (ticker_id, timeframe, open, high, low, close, volume, timestamp, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (ticker_id, timeframe, timestamp) DO UPDATE SET
+			open = EXCLUDED.open,
+			high = EXCLUDED.high,
+			low = EXCLUDED.low,
+			close = EXCLUDED.close,
+			volume = EXCLUDED.volume,
+			source = EXCLUDED.source
+		WHERE historical_data.source = $10
+	`, tickerID, data.Timeframe, v[0], v[1], v[2], v[3], v[4], data.Timestamp.UTC(), candleSourceBackfill, candleSourceTicks)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert historical data: %w", err)
+	}
+	return nil
 }
 
-// insertMarketData - вставляет текущие рыночные данные
-func (s *Storage) insertMarketData(ctx context.Context, tickerID int64, data MarketData) error {
+// upsertCandles - сохраняет закрытые свечи и удаляет их из незакрытых в одной транзакции;
+// свеча из тиков заменяет только свечу из тиков: тики могли начаться не с начала интервала,
+// и такая свеча не должна перезаписать полную свечу из дозагрузки истории
+func (s *Storage) upsertCandles(ctx context.Context, ids map[TickerKey]int64, candles []HistoricalData) error {
+	batch := &pgx.Batch{}
+	for _, c := range candles {
+		id := ids[TickerKey{c.Exchange, c.Symbol, c.Market}]
+		v, err := numerics(c.Open, c.High, c.Low, c.Close, c.Volume)
+		if err != nil {
+			return err
+		}
+
+		batch.Queue(`
+			INSERT INTO historical_data (ticker_id, timeframe, open, high, low, close, volume, timestamp, source)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (ticker_id, timeframe, timestamp) DO UPDATE SET
+				open = EXCLUDED.open,
+				high = EXCLUDED.high,
+				low = EXCLUDED.low,
+				close = EXCLUDED.close,
+				volume = EXCLUDED.volume
+			WHERE historical_data.source = EXCLUDED.source
+		`, id, c.Timeframe, v[0], v[1], v[2], v[3], v[4], c.Timestamp.UTC(), candleSourceTicks)
+		batch.Queue(`
+			DELETE FROM open_candles WHERE ticker_id = $1 AND timeframe = $2 AND timestamp = $3
+		`, id, c.Timeframe, c.Timestamp.UTC())
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to upsert candles: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// upsertOpenCandles - сохраняет текущее состояние незакрытых свечей
+func (s *Storage) upsertOpenCandles(ctx context.Context, ids map[TickerKey]int64, candles []HistoricalData) error {
+	now := time.Now().UTC()
+	batch := &pgx.Batch{}
+	for _, c := range candles {
+		id := ids[TickerKey{c.Exchange, c.Symbol, c.Market}]
+		v, err := numerics(c.Open, c.High, c.Low, c.Close, c.Volume)
+		if err != nil {
+			return err
+		}
+
+		batch.Queue(`
+			INSERT INTO open_candles (ticker_id, timeframe, timestamp, open, high, low, close, volume, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (ticker_id, timeframe, timestamp) DO UPDATE SET
+				open = EXCLUDED.open,
+				high = EXCLUDED.high,
+				low = EXCLUDED.low,
+				close = EXCLUDED.close,
+				volume = EXCLUDED.volume,
+				updated_at = EXCLUDED.updated_at
+		`, id, c.Timeframe, c.Timestamp.UTC(), v[0], v[1], v[2], v[3], v[4], now)
+	}
+
+	if err := s.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to upsert open candles: %w", err)
+	}
+	return nil
+}
+
+// insertFxRate - вставляет курс валюты, курс хранится в NUMERIC без потери точности
+func (s *Storage) insertFxRate(ctx context.Context, rate FxRate) error {
+	var value pgtype.Numeric
+	if err := value.Scan(rate.Rate); err != nil {
+		return fmt.Errorf("invalid rate %q: %w", rate.Rate, err)
+	}
+
 	_, err := s.pool.Exec(ctx, `
-		INSERT INTO market_data (ticker_id, price, volume, high_price, low_price, price_change_percent, timestamp)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, tickerID, data.Price, data.Volume, data.High, data.Low, data.PriceChangePercent, time.Now().UTC())
+		INSERT INTO fx_rates (base, quote, rate, source, timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (base, quote, source, timestamp) DO NOTHING
+	`, rate.Base, rate.Quote, value, rate.Source, rate.Timestamp)
 
 	if err != nil {
-		return fmt.Errorf("failed to insert market data: %w", err)
+		return fmt.Errorf("failed to insert fx rate: %w", err)
 	}
-	log.Printf("Inserted into market_data: ticker_id=%d, price=%d, volume=%d, high_price=%d, low_price=%d, price_change_percent=%s\n", tickerID, data.Price, data.Volume, data.High, data.Low, data.PriceChangePercent)
 
 	return nil
 }
 
-// insertHistoricalData - вставляет исторические данные
-func (s *Storage) insertHistoricalData(ctx context.Context, tickerID int64, data HistoricalData) error {
+// updateTickerPairs - обновляет пары тикеров одним запросом; неизменные строки не перезаписываются
+func (s *Storage) updateTickerPairs(ctx context.Context, pairs []TickerPair) error {
+	exchanges := make([]string, len(pairs))
+	symbols := make([]string, len(pairs))
+	markets := make([]string, len(pairs))
+	bases := make([]string, len(pairs))
+	quotes := make([]string, len(pairs))
+	for i, p := range pairs {
+		exchanges[i], symbols[i], markets[i], bases[i], quotes[i] = p.Exchange, p.Symbol, p.Market, p.Base, p.Quote
+	}
+
 	_, err := s.pool.Exec(ctx, `
-		INSERT INTO historical_data (ticker_id, open, high, low, close, volume, timestamp)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT (ticker_id, timestamp) DO NOTHING
-	`, tickerID, data.Open, data.High, data.Low, data.Close, data.Volume, time.Now().UTC())
+		UPDATE tickers t
+		SET base_asset = p.base, quote_asset = p.quote
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[]) AS p(exchange, symbol, market, base, quote)
+		WHERE t.exchange = p.exchange AND t.symbol = p.symbol AND t.market = p.market
+		  AND (t.base_asset IS DISTINCT FROM p.base OR t.quote_asset IS DISTINCT FROM p.quote)
+	`, exchanges, symbols, markets, bases, quotes)
+	return err
+}
 
+// insertQuarantine - исходное сообщение сохраняется как JSONB, сообщения без JSON - как строка
+func (s *Storage) insertQuarantine(ctx context.Context, entries []QuarantineEntry) error {
+	batch := &pgx.Batch{}
+	for _, e := range entries {
+		payload := e.Payload
+		if !json.Valid(payload) {
+			payload, _ = json.Marshal(string(payload))
+		}
+		batch.Queue(`
+			INSERT INTO quarantine (exchange, symbol, market, rule, action, detail, payload, tick_time)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, e.Exchange, e.Symbol, e.Market, e.Rule, e.Action, e.Detail, string(payload), e.TickTime)
+	}
+	return s.pool.SendBatch(ctx, batch).Close()
+}
+
+// upsertInstrument - сохраняет метаданные инструмента, при повторном получении справочника обновляет их
+func (s *Storage) upsertInstrument(ctx context.Context, tickerID int64, inst Instrument) error {
+	values, err := numerics(inst.TickSize, inst.LotSize, inst.MinNotional)
 	if err != nil {
-		return fmt.Errorf("failed to insert historical data: %w", err)
+		return err
+	}
+	tickSize, lotSize, minNotional := values[0], values[1], values[2]
+
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO instruments (ticker_id, base, quote, tick_size, lot_size, min_notional, price_scale, status, listing_time, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (ticker_id) DO UPDATE SET
+			base = EXCLUDED.base,
+			quote = EXCLUDED.quote,
+			tick_size = EXCLUDED.tick_size,
+			lot_size = EXCLUDED.lot_size,
+			min_notional = EXCLUDED.min_notional,
+			price_scale = COALESCE(EXCLUDED.price_scale, instruments.price_scale),
+			status = EXCLUDED.status,
+			listing_time = COALESCE(EXCLUDED.listing_time, instruments.listing_time),
+			updated_at = EXCLUDED.updated_at
+	`, tickerID, inst.Base, inst.Quote, tickSize, lotSize, minNotional, inst.PriceScale, inst.Status, inst.ListingTime, time.Now().UTC())
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert instrument: %w", err)
+	}
+
+	return nil
+}
+
+// upsertOptionsData - заменяет снимок опциона более новым
+func (s *Storage) upsertOptionsData(ctx context.Context, tickerID int64, data OptionsData) error {
+	v, err := numerics(data.Strike, data.MarkPrice, data.MarkIV, data.BidIV, data.AskIV, data.BestBidPrice, data.BestAskPrice,
+		data.UnderlyingPrice, data.OpenInterest, data.Delta, data.Gamma, data.Vega, data.Theta, data.Rho)
+	if err != nil {
+		return err
+	}
+
+	// хранится только последний снимок опциона; опоздавший снимок не заменяет более новый
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO options_data (ticker_id, underlying, expiration, strike, option_type, mark_price, mark_iv, bid_iv, ask_iv,
+			best_bid_price, best_ask_price, underlying_price, open_interest, delta, gamma, vega, theta, rho, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		ON CONFLICT (ticker_id) DO UPDATE SET
+			underlying = EXCLUDED.underlying, expiration = EXCLUDED.expiration, strike = EXCLUDED.strike,
+			option_type = EXCLUDED.option_type, mark_price = EXCLUDED.mark_price, mark_iv = EXCLUDED.mark_iv,
+			bid_iv = EXCLUDED.bid_iv, ask_iv = EXCLUDED.ask_iv, best_bid_price = EXCLUDED.best_bid_price,
+			best_ask_price = EXCLUDED.best_ask_price, underlying_price = EXCLUDED.underlying_price,
+			open_interest = EXCLUDED.open_interest, delta = EXCLUDED.delta, gamma = EXCLUDED.gamma,
+			vega = EXCLUDED.vega, theta = EXCLUDED.theta, rho = EXCLUDED.rho, timestamp = EXCLUDED.timestamp
+		WHERE options_data.timestamp <= EXCLUDED.timestamp
+	`, tickerID, data.Underlying, data.Expiration.UTC(), v[0], data.OptionType, v[1], v[2], v[3], v[4],
+		v[5], v[6], v[7], v[8], v[9], v[10], v[11], v[12], v[13], data.Timestamp.UTC())
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert options data: %w", err)
 	}
-	log.Printf("Inserted into historical_data: ticker_id=%d, open=%d, high=%d, low=%d, close=%d, volume=%d\n",
-		tickerID, data.Open, data.High, data.Low, data.Close, data.Volume)
 
 	return nil
 }
+
+// numerics - строки в NUMERIC; пустая строка означает отсутствие значения и сохраняется как NULL
+func numerics(values ...string) ([]pgtype.Numeric, error) {
+	out := make([]pgtype.Numeric, len(values))
+	for i, value := range values {
+		if value == "" {
+			continue
+		}
+		if err := out[i].Scan(value); err != nil {
+			return nil, fmt.Errorf("invalid number %q: %w", value, err)
+		}
+	}
+	return out, nil
+}
@@ -1,29 +1,137 @@
 package storage
 
-import "github.com/jackc/pgx/v5/pgxpool"
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
 
 type Storage struct {
-	pool *pgxpool.Pool
+	pool    *pgxpool.Pool
+	tickers *TickerCache
 }
 
+// MarketData - цены и объем в точной десятичной записи, сохраняются в NUMERIC
 type MarketData struct {
-	Exchange           string `json:"exchange"`
-	Symbol             string `json:"symbol"`
-	Market             string `json:"market"`
-	Price              int64  `json:"price"`
-	Volume             int64  `json:"volume"`
-	High               int64  `json:"high"`
-	Low                int64  `json:"low"`
-	PriceChangePercent string `json:"price_change_percent"`
+	Exchange           string    `json:"exchange"`
+	Symbol             string    `json:"symbol"`
+	Market             string    `json:"market"`
+	Price              string    `json:"price"`
+	Volume             string    `json:"volume"`
+	High               string    `json:"high"`
+	Low                string    `json:"low"`
+	PriceChange        string    `json:"price_change"`         // суточное изменение цены, пусто если неизвестно
+	PriceChangePercent string    `json:"price_change_percent"` // суточное изменение в процентах, пусто если неизвестно
+	VWAP               string    `json:"vwap"`
+	QuoteVolume        string    `json:"quote_volume"`
+	Bid                string    `json:"bid"` // лучшие цены и объемы заявок, пусто если биржа их не передает
+	BidSize            string    `json:"bid_size"`
+	Ask                string    `json:"ask"`
+	AskSize            string    `json:"ask_size"`
+	SpreadBps          string    `json:"spread_bps"` // (ask - bid) / mid в базисных пунктах
+	PriceUSD           string    `json:"price_usd"`  // цена и суточный оборот в USD, пусто если курса котировки нет
+	VolumeUSD          string    `json:"volume_usd"`
+	UsdPath            string    `json:"usd_path"`  // цепочка курсов, по которой пересчитана цена
+	Timestamp          time.Time `json:"timestamp"` // время получения тикера, пустое - время записи
+}
+
+// TickerPair - каноническая пара тикера: базовый актив и валюта котировки из реестра активов
+type TickerPair struct {
+	Exchange string
+	Symbol   string
+	Market   string
+	Base     string
+	Quote    string
+}
+
+// PairQuote - последняя цена тикера с известной канонической парой, источник композитной цены
+type PairQuote struct {
+	Exchange  string
+	Symbol    string
+	Market    string
+	Base      string
+	Quote     string
+	Price     string
+	Volume    string
+	Timestamp time.Time
+}
+
+// AssetRegistry - синонимы активов (XBT -> BTC) и валюты котировки
+type AssetRegistry struct {
+	Aliases map[string]string
+	Quotes  []string
+	// Assets - все известные активы, включая валюты котировки
+	Assets []string
+}
+
+// QuarantineEntry - нарушение правила качества тиком вместе с исходным сообщением
+type QuarantineEntry struct {
+	Exchange string
+	Symbol   string
+	Market   string
+	Rule     string
+	Action   string // reject или flag
+	Detail   string
+	Payload  []byte
+	TickTime time.Time
 }
 
 type HistoricalData struct {
-	Exchange string `json:"exchange"`
-	Symbol   string `json:"symbol"`
-	Market   string `json:"market"`
-	Open     int64  `json:"open"`
-	High     int64  `json:"high"`
-	Low      int64  `json:"low"`
-	Close    int64  `json:"close"`
-	Volume   int64  `json:"volume"`
+	Exchange  string    `json:"exchange"`
+	Symbol    string    `json:"symbol"`
+	Market    string    `json:"market"`
+	Timeframe string    `json:"timeframe"`
+	Open      string    `json:"open"`
+	High      string    `json:"high"`
+	Low       string    `json:"low"`
+	Close     string    `json:"close"`
+	Volume    string    `json:"volume"`
+	Timestamp time.Time `json:"timestamp"` // время открытия свечи
+}
+
+type Instrument struct {
+	Exchange    string     `json:"exchange"`
+	Symbol      string     `json:"symbol"`
+	Market      string     `json:"market"`
+	Base        string     `json:"base"`
+	Quote       string     `json:"quote"`
+	TickSize    string     `json:"tick_size"`
+	LotSize     string     `json:"lot_size"`
+	MinNotional string     `json:"min_notional"`
+	PriceScale  *int       `json:"price_scale"` // знаков после запятой в цене, nil если шаг цены неизвестен
+	Status      string     `json:"status"`
+	ListingTime *time.Time `json:"listing_time"`
+}
+
+// OptionsData - снимок тикера опциона; числовые поля в исходном строковом виде, пустые сохраняются как NULL
+type OptionsData struct {
+	Exchange        string    `json:"exchange"`
+	Symbol          string    `json:"symbol"`
+	Market          string    `json:"market"`
+	Underlying      string    `json:"underlying"`
+	Expiration      time.Time `json:"expiration"`
+	Strike          string    `json:"strike"`
+	OptionType      string    `json:"option_type"`
+	MarkPrice       string    `json:"mark_price"`
+	MarkIV          string    `json:"mark_iv"`
+	BidIV           string    `json:"bid_iv"`
+	AskIV           string    `json:"ask_iv"`
+	BestBidPrice    string    `json:"best_bid_price"`
+	BestAskPrice    string    `json:"best_ask_price"`
+	UnderlyingPrice string    `json:"underlying_price"`
+	OpenInterest    string    `json:"open_interest"`
+	Delta           string    `json:"delta"`
+	Gamma           string    `json:"gamma"`
+	Vega            string    `json:"vega"`
+	Theta           string    `json:"theta"`
+	Rho             string    `json:"rho"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+type FxRate struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      string    `json:"rate"`
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"`
 }
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"preprocessor/internal/config"
+	"preprocessor/internal/processor"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// candleMessage - свеча дозагрузки записывается сразу, без пачки, и ошибка записи идет через settle
+var candleMessage = []byte(`{"exchange":"binance","symbol":"BTCUSDT","interval":"1m","open_time":"2024-01-01T00:00:00Z",
+	"open":"100","high":"110","low":"90","close":"105","volume":"12.5"}`)
+
+func settleHarness(t *testing.T) *workerHarness {
+	return newWorkerHarness(t, config.PreprocessorConfig{BatchSize: 100, FlushInterval: time.Hour, MaxRetries: 3})
+}
+
+func waitPublished(t *testing.T, h *workerHarness, n int) []publishedMessage {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(h.pub.published()) == n }, 5*time.Second, 10*time.Millisecond)
+	return h.pub.published()
+}
+
+func TestSettle_RetryIncrementsHeader(t *testing.T) {
+	h := settleHarness(t)
+
+	h.send(1, processor.KindCandle, candleMessage, amqp.Table{processor.HeaderRetryCount: int32(1), "x-trace": "abc"})
+	msgs := waitPublished(t, h, 1)
+
+	// повтор публикуется в ту же очередь с увеличенным счетчиком, остальные заголовки сохраняются
+	assert.Equal(t, "", msgs[0].exchange)
+	assert.Equal(t, "binance", msgs[0].key)
+	assert.Equal(t, int32(2), msgs[0].msg.Headers[processor.HeaderRetryCount])
+	assert.Equal(t, "abc", msgs[0].msg.Headers["x-trace"])
+	assert.Equal(t, processor.KindCandle, msgs[0].msg.Type)
+	assert.Equal(t, amqp.Persistent, msgs[0].msg.DeliveryMode)
+	assert.Nil(t, msgs[0].msg.Headers[processor.HeaderFailureReason])
+
+	acked, requeued := h.acks.state()
+	assert.Equal(t, []uint64{1}, acked)
+	assert.Empty(t, requeued)
+}
+
+func TestSettle_ExhaustedGoesToDeadLetter(t *testing.T) {
+	h := settleHarness(t)
+
+	h.send(1, processor.KindCandle, candleMessage, amqp.Table{processor.HeaderRetryCount: int32(3)})
+	msgs := waitPublished(t, h, 1)
+
+	// после MaxRetries повторов сообщение уходит в обменник, связанный с очередью binance.dead
+	assert.Equal(t, processor.DeadLetterExchange("binance"), msgs[0].exchange)
+	assert.Equal(t, "binance.dead", processor.DeadLetterQueue("binance"))
+	headers := msgs[0].msg.Headers
+	assert.Equal(t, int32(3), headers[processor.HeaderRetryCount])
+	assert.Contains(t, headers[processor.HeaderFailureReason], "ошибка сохранения свечи")
+	assert.Equal(t, "binance", headers[processor.HeaderOriginalQueue])
+	_, err := time.Parse(time.RFC3339, headers[processor.HeaderFailedAt].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, candleMessage, msgs[0].msg.Body)
+
+	acked, _ := h.acks.state()
+	assert.Equal(t, []uint64{1}, acked)
+}
+
+func TestSettle_InvalidGoesToDeadLetter(t *testing.T) {
+	h := settleHarness(t)
+
+	// испорченное сообщение и сообщение без парсера не повторяются: повтор дал бы ту же ошибку
+	h.send(1, processor.KindMarketData, []byte(`{"e":"24hrTicker","c":`), nil)
+	h.send(2, "unknown_kind", []byte(`{}`), nil)
+	h.send(3, processor.KindCandle, []byte(`{"exchange":"binance","symbol":"BTCUSDT","open":"abc"}`), nil)
+	msgs := waitPublished(t, h, 3)
+
+	for _, m := range msgs {
+		assert.Equal(t, processor.DeadLetterExchange("binance"), m.exchange)
+		assert.Nil(t, m.msg.Headers[processor.HeaderRetryCount], "в dead-letter уходит без повторов")
+		assert.Contains(t, m.msg.Headers[processor.HeaderFailureReason], "invalid message")
+	}
+	acked, _ := h.acks.state()
+	assert.ElementsMatch(t, []uint64{1, 2, 3}, acked)
+}
+
+func TestSettle_PublishFailureRequeues(t *testing.T) {
+	for name, msg := range map[string]struct {
+		kind string
+		body []byte
+	}{
+		"retry":       {processor.KindCandle, candleMessage},
+		"dead-letter": {processor.KindMarketData, []byte(`not json`)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := settleHarness(t)
+			h.pub.err = errors.New("channel closed")
+
+			h.send(1, msg.kind, msg.body, nil)
+			require.Eventually(t, func() bool {
+				_, requeued := h.acks.state()
+				return len(requeued) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+
+			// без опубликованной копии оригинал возвращается брокеру, а не подтверждается
+			acked, _ := h.acks.state()
+			assert.Empty(t, acked)
+		})
+	}
+}
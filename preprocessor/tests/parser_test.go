@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"preprocessor/internal/processor"
+	"preprocessor/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsers(t *testing.T) {
+	priceScale := 2
+
+	tests := []struct {
+		name     string
+		exchange string
+		kind     string
+		payload  string // файл в testdata/parsers с сообщением, записанным с биржи
+		want     processor.Record
+		wantErr  error
+	}{
+		{
+			name:     "binance 24hr ticker",
+			exchange: "binance",
+			kind:     processor.KindMarketData,
+			payload:  "binance_24hr_ticker.json",
+			want: processor.Record{Ticker: &storage.MarketData{
+				Exchange: "binance", Symbol: "BTCUSDT", Market: "crypto",
+				Price: "38000.00", Volume: "25000.50000", High: "38200.00", Low: "36400.00",
+				PriceChange: "1500", PriceChangePercent: "4.110", VWAP: "37250.12", QuoteVolume: "931253000.00",
+				Bid: "37999.99", BidSize: "1.20000", Ask: "38000.01", AskSize: "0.80000", SpreadBps: "0.0053",
+			}},
+		},
+		{
+			// изменение считается по цене открытия, экспонента раскрывается без потери точности
+			name:     "binance mini ticker",
+			exchange: "binance",
+			kind:     processor.KindMarketData,
+			payload:  "binance_24hr_mini_ticker.json",
+			want: processor.Record{Ticker: &storage.MarketData{
+				Exchange: "binance", Symbol: "PEPEUSDT", Market: "crypto",
+				Price: "0.00001234", Volume: "123456789012.5", High: "0.000015", Low: "0.00000987",
+				PriceChange: "0.00000234", PriceChangePercent: "23.400",
+			}},
+		},
+		{
+			name:     "binance invalid price",
+			exchange: "binance",
+			kind:     processor.KindMarketData,
+			payload:  "binance_invalid_price.json",
+			wantErr:  processor.ErrInvalidField,
+		},
+		{
+			name:     "binance truncated payload",
+			exchange: "binance",
+			kind:     processor.KindMarketData,
+			payload:  "binance_truncated.json",
+			wantErr:  processor.ErrMalformed,
+		},
+		{
+			name:     "bybit ticker",
+			exchange: "bybit",
+			kind:     processor.KindMarketData,
+			payload:  "bybit_ticker.json",
+			want: processor.Record{Ticker: &storage.MarketData{
+				Exchange: "bybit", Symbol: "ETHUSDT", Market: "crypto",
+				Price: "2450.50", Volume: "120000.5", High: "2510.00", Low: "2440.00",
+				PriceChange: "-49.5", PriceChangePercent: "-1.980", VWAP: "2449.9898", QuoteVolume: "294000000.25",
+			}},
+		},
+		{
+			name:     "okx ticker",
+			exchange: "okx",
+			kind:     processor.KindMarketData,
+			payload:  "okx_ticker.json",
+			want: processor.Record{Ticker: &storage.MarketData{
+				Exchange: "okx", Symbol: "BTC-USDT", Market: "crypto",
+				Price: "110", Volume: "20", High: "115", Low: "95",
+				PriceChange: "10", PriceChangePercent: "10.000", VWAP: "105", QuoteVolume: "2100",
+				Bid: "109.9", BidSize: "3", Ask: "110.1", AskSize: "2", SpreadBps: "18.1818",
+			}},
+		},
+		{
+			// пересекающиеся заявки сохраняются, но спред не считается
+			name:     "okx crossed quotes without open",
+			exchange: "okx",
+			kind:     processor.KindMarketData,
+			payload:  "okx_ticker_crossed.json",
+			want: processor.Record{Ticker: &storage.MarketData{
+				Exchange: "okx", Symbol: "BTC-USDT", Market: "crypto",
+				Price: "100", Volume: "1", High: "101", Low: "99",
+				VWAP: "100", QuoteVolume: "100",
+				Bid: "100.2", BidSize: "1", Ask: "100.1", AskSize: "1",
+			}},
+		},
+		{
+			name:     "coinbase ticker",
+			exchange: "coinbase",
+			kind:     processor.KindMarketData,
+			payload:  "coinbase_ticker.json",
+			want: processor.Record{Ticker: &storage.MarketData{
+				Exchange: "coinbase", Symbol: "BTC-USD", Market: "crypto",
+				Price: "100.005", Volume: "1", High: "101", Low: "99",
+				PriceChange: "1.005", PriceChangePercent: "1.015",
+				Bid: "99.99", BidSize: "0.5", Ask: "100.01", AskSize: "1.25", SpreadBps: "2",
+			}},
+		},
+		{
+			name:     "coinbase zero price",
+			exchange: "coinbase",
+			kind:     processor.KindMarketData,
+			payload:  "coinbase_ticker_zero_price.json",
+			wantErr:  processor.ErrInvalidField,
+		},
+		{
+			// общий парсер тикеров подходит любой бирже универсального коннектора
+			name:     "generic ticker",
+			exchange: "gateio",
+			kind:     processor.KindTicker,
+			payload:  "generic_ticker.json",
+			want: processor.Record{Ticker: &storage.MarketData{
+				Exchange: "gateio", Symbol: "BTC_USDT", Market: "crypto",
+				Price: "102", PriceChange: "2", PriceChangePercent: "2.000",
+			}},
+		},
+		{
+			name:     "deribit option",
+			exchange: "deribit",
+			kind:     processor.KindMarketData,
+			payload:  "deribit_option.json",
+			want: processor.Record{Option: &storage.OptionsData{
+				Exchange: "deribit", Symbol: "BTC-27DEC24-100000-C", Market: "options",
+				Underlying: "BTC", Expiration: time.Date(2024, 12, 27, 8, 0, 0, 0, time.UTC),
+				Strike: "100000", OptionType: "call",
+				MarkPrice: "0.0123", MarkIV: "55.5", BidIV: "54.1", AskIV: "56.9",
+				BestBidPrice: "0.012", BestAskPrice: "0.0125", UnderlyingPrice: "37000.5", OpenInterest: "120.3",
+				Delta: "0.25", Gamma: "0.00002", Vega: "30.1", Theta: "-15.2", Rho: "5.5",
+				Timestamp: time.UnixMilli(1700000000000).UTC(),
+			}},
+		},
+		{
+			name:     "fx rate",
+			exchange: "fx",
+			kind:     processor.KindMarketData,
+			payload:  "fx_rate.json",
+			want: processor.Record{FxRate: &storage.FxRate{
+				Base: "EUR", Quote: "USD", Rate: "1.0845", Source: "ecb",
+				Timestamp: time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name:     "instrument",
+			exchange: "binance",
+			kind:     processor.KindInstrument,
+			payload:  "instrument.json",
+			want: processor.Record{Instrument: &storage.Instrument{
+				Exchange: "binance", Symbol: "BTCUSDT", Market: "crypto", Base: "BTC", Quote: "USDT",
+				TickSize: "0.01000000", LotSize: "0.00001000", MinNotional: "5.00000000",
+				PriceScale: &priceScale, Status: "active",
+			}},
+		},
+		{
+			name:     "candle",
+			exchange: "binance",
+			kind:     processor.KindCandle,
+			payload:  "candle.json",
+			want: processor.Record{Candle: &storage.HistoricalData{
+				Exchange: "binance", Symbol: "BTCUSDT", Market: "crypto", Timeframe: "1m",
+				Open: "42000.10", High: "42100.00", Low: "41990.50", Close: "42050.00", Volume: "12.5",
+				Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "parsers", tt.payload))
+			require.NoError(t, err)
+
+			parser, err := processor.LookupParser(tt.exchange, tt.kind)
+			require.NoError(t, err)
+
+			record, err := parser.Parse(body)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, record)
+		})
+	}
+}
+
+func TestLookupParser_Unknown(t *testing.T) {
+	_, err := processor.LookupParser("moex", processor.KindMarketData)
+	assert.ErrorIs(t, err, processor.ErrNoParser)
+
+	_, err = processor.LookupParser("binance", "unknown")
+	assert.ErrorIs(t, err, processor.ErrNoParser)
+}
@@ -2,11 +2,13 @@ package tests
 
 import (
 	"testing"
+	"time"
 
 	"preprocessor/internal/config"
 	"preprocessor/internal/processor"
 	"preprocessor/internal/storage"
 
+	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -78,3 +80,327 @@ func TestProcessor_RemoveWorker(t *testing.T) {
 	assert.NotNil(t, newWorker)
 	assert.Equal(t, 0, newWorker.Id)
 }
+
+func TestRetryCount(t *testing.T) {
+	assert.Equal(t, 0, processor.RetryCount(amqp.Delivery{}))
+	assert.Equal(t, 3, processor.RetryCount(amqp.Delivery{Headers: amqp.Table{processor.HeaderRetryCount: int32(3)}}))
+	assert.Equal(t, "binance_trades.dead", processor.DeadLetterQueue("binance_trades"))
+}
+
+func TestCandleAggregator_LateTicks(t *testing.T) {
+	agg := processor.NewCandleAggregator(10 * time.Second)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tick := func(price, volume string, at time.Time) storage.MarketData {
+		return storage.MarketData{Exchange: "binance", Symbol: "BTCUSDT", Market: "crypto", Price: price, Volume: volume, Timestamp: at}
+	}
+
+	agg.Add(tick("100", "10", start.Add(5*time.Second)), start.Add(5*time.Second))
+	agg.Add(tick("105.5", "12", start.Add(20*time.Second)), start.Add(20*time.Second))
+	agg.Add(tick("99", "13", start.Add(50*time.Second)), start.Add(50*time.Second))
+	// опоздавший тик внутри окна меняет минимум, но не цену закрытия
+	agg.Add(tick("98", "13.5", start.Add(40*time.Second)), start.Add(65*time.Second))
+	// тик после окна опоздания минутной свечи не учитывается
+	agg.Add(tick("1", "14", start.Add(30*time.Second)), start.Add(75*time.Second))
+
+	assert.Empty(t, agg.CloseDue(start.Add(69*time.Second)))
+
+	var minute *storage.HistoricalData
+	for _, c := range agg.CloseDue(start.Add(70 * time.Second)) {
+		if c.Timeframe == "1m" {
+			minute = &c
+		}
+	}
+	require.NotNil(t, minute)
+	assert.Equal(t, start, minute.Timestamp)
+	assert.Equal(t, "100", minute.Open)
+	assert.Equal(t, "105.5", minute.High)
+	assert.Equal(t, "98", minute.Low)
+	assert.Equal(t, "99", minute.Close)
+	assert.Equal(t, "3.5", minute.Volume)
+}
+
+func TestProcessor_RemoveWorkerByID(t *testing.T) {
+	cfg := &config.Config{
+		Preprocessor: config.PreprocessorConfig{
+			Queue: "test-queue",
+		},
+	}
+
+	processor, err := processor.NewProcessor(cfg, nil)
+	require.NoError(t, err)
+
+	first := processor.AddWorker()
+	second := processor.AddWorker()
+	processor.RemoveWorker(first.Id)
+	processor.RemoveWorker(first.Id)
+	assert.Equal(t, 1, processor.WorkerCount())
+
+	third := processor.AddWorker()
+	assert.Equal(t, 0, third.Id)
+	assert.Equal(t, 1, second.Id)
+	assert.Equal(t, 2, processor.WorkerCount())
+}
+
+func TestAutoscaler_Decide(t *testing.T) {
+	scaler := processor.NewAutoscaler(2, 4)
+
+	// очередь не успевает разбираться - воркер добавляется
+	assert.Equal(t, 3, scaler.Decide(processor.ScaleSample{Depth: 10000, Rate: 100, Workers: 2}))
+	// новый воркер не увеличил пропускную способность - число воркеров не меняется
+	assert.Equal(t, 3, scaler.Decide(processor.ScaleSample{Depth: 10000, Rate: 102, Workers: 3}))
+	assert.Equal(t, 4, scaler.Decide(processor.ScaleSample{Depth: 10000, Rate: 102, Workers: 3}))
+	// верхняя граница
+	assert.Equal(t, 4, scaler.Decide(processor.ScaleSample{Depth: 10000, Rate: 200, Workers: 4}))
+
+	// воркер убирается только после нескольких замеров с пустой очередью
+	assert.Equal(t, 4, scaler.Decide(processor.ScaleSample{Depth: 0, Rate: 0, Workers: 4}))
+	assert.Equal(t, 4, scaler.Decide(processor.ScaleSample{Depth: 0, Rate: 0, Workers: 4}))
+	assert.Equal(t, 3, scaler.Decide(processor.ScaleSample{Depth: 0, Rate: 0, Workers: 4}))
+
+	// нижняя граница
+	assert.Equal(t, 2, scaler.Decide(processor.ScaleSample{Depth: 0, Rate: 0, Workers: 1}))
+}
+
+func TestQualityValidator_Check(t *testing.T) {
+	rules, err := processor.LoadQualityRules("../specs/quality_rules.yaml")
+	require.NoError(t, err)
+	validator := processor.NewQualityValidator(rules)
+
+	tick := func(price, high, low, volume string) storage.MarketData {
+		return storage.MarketData{Exchange: "binance", Symbol: "BTCUSDT", Market: "crypto",
+			Price: price, High: high, Low: low, Volume: volume}
+	}
+	rulesOf := func(violations []processor.Violation) []string {
+		var names []string
+		for _, v := range violations {
+			names = append(names, v.Rule+":"+v.Action)
+		}
+		return names
+	}
+
+	assert.Empty(t, validator.Check(tick("100", "110", "90", "5")))
+	assert.Equal(t, []string{"high_below_low:reject"}, rulesOf(validator.Check(tick("100", "90", "110", "5"))))
+	assert.Equal(t, []string{"price_outside_range:flag", "zero_volume:flag"}, rulesOf(validator.Check(tick("120", "110", "90", "0"))))
+
+	// скачок отклоняется, но подтвержденный следующим тиком уровень принимается
+	assert.Equal(t, []string{"price_jump:reject"}, rulesOf(validator.Check(tick("250", "300", "90", "5"))))
+	assert.Empty(t, validator.Check(tick("255", "300", "90", "5")))
+
+	// у DEX нет суточного диапазона и объема
+	dex := storage.MarketData{Exchange: "uniswap", Symbol: "WETHUSDC", Market: "dex", Price: "3000", High: "0", Low: "0", Volume: "0"}
+	assert.Empty(t, validator.Check(dex))
+
+	// универсальный коннектор может не передавать объем и диапазон: отсутствие не считается нулевым объемом
+	generic := storage.MarketData{Exchange: "gateio", Symbol: "BTC_USDT", Market: "crypto", Price: "100"}
+	assert.Empty(t, validator.Check(generic))
+	assert.Equal(t, []string{"zero_volume:flag"}, rulesOf(validator.Check(storage.MarketData{
+		Exchange: "gateio", Symbol: "BTC_USDT", Market: "crypto", Price: "100", Volume: "0"})))
+
+	stats := validator.Stats()
+	assert.Equal(t, int64(1), stats.Rejected["high_below_low"])
+	assert.Equal(t, int64(1), stats.Rejected["price_jump"])
+	assert.Equal(t, int64(2), stats.Flagged["zero_volume"])
+}
+
+func TestAssetRegistry_Resolve(t *testing.T) {
+	registry := processor.NewAssetRegistry()
+	registry.SetAssets(storage.AssetRegistry{
+		Aliases: map[string]string{"XBT": "BTC", "WETH": "ETH"},
+		Quotes:  []string{"USD", "USDT", "USDC", "BTC"},
+		Assets:  []string{"SOL"},
+	})
+
+	pair := func(exchange, symbol, market string) string {
+		p, ok := registry.Resolve(exchange, symbol, market)
+		if !ok {
+			return ""
+		}
+		return p.Base + "/" + p.Quote
+	}
+
+	assert.Equal(t, "BTC/USDT", pair("binance", "BTCUSDT", "crypto"))
+	assert.Equal(t, "BTC/USDT", pair("okx", "BTC-USDT", "crypto"))
+	assert.Equal(t, "BTC/USD", pair("coinbase", "BTC-USD", "crypto"))
+	assert.Equal(t, "BTC/USD", pair("kraken", "XBTUSD", "crypto"))
+	assert.Equal(t, "ETH/BTC", pair("kraken", "ETHXBT", "crypto"))
+	assert.Equal(t, "ETH/USDC", pair("uniswap", "WETH/USDC", "dex"))
+	assert.Equal(t, "", pair("deribit", "BTC-27DEC24-100000-C", "options"))
+	assert.Equal(t, "", pair("binance", "FOOBAR", "crypto"))
+	assert.Equal(t, "SOL/USDT", pair("binance", "SOLUSDT", "crypto"))
+	// остаток перед котировкой - не известный актив: котировка TUSD, а не USD
+	assert.Equal(t, "", pair("binance", "BTCTUSD", "crypto"))
+	assert.Equal(t, "", pair("binance", "PEPEUSDT", "crypto"))
+
+	// пара из справочника инструментов важнее разбора символа
+	_, ok := registry.Learn("binance", "1000PEPEUSDT", "crypto", "1000pepe", "usdt")
+	require.True(t, ok)
+	assert.Equal(t, "1000PEPE/USDT", pair("binance", "1000PEPEUSDT", "crypto"))
+
+	_, ok = registry.Learn("binance", "BTCTUSD", "crypto", "BTC", "TUSD")
+	require.True(t, ok)
+	assert.Equal(t, "BTC/TUSD", pair("binance", "BTCTUSD", "crypto"))
+
+	// активы изученной пары известны и для других символов, в том числе после перезагрузки реестра
+	registry.SetAssets(storage.AssetRegistry{Quotes: []string{"USDT", "USDC"}})
+	assert.Equal(t, "1000PEPE/USDC", pair("bybit", "1000PEPEUSDC", "crypto"))
+}
+
+func TestComputeComposite(t *testing.T) {
+	spec, err := processor.LoadCompositeSpec("../specs/composite.yaml")
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	quote := func(exchange, price, volume string, age time.Duration) storage.PairQuote {
+		return storage.PairQuote{Exchange: exchange, Symbol: "BTCUSDT", Market: "crypto", Base: "BTC", Quote: "USDT",
+			Price: price, Volume: volume, Timestamp: now.Add(-age)}
+	}
+	quotes := []storage.PairQuote{
+		quote("binance", "100.00", "3", 10*time.Second),
+		quote("okx", "102.00", "1", 10*time.Second),
+		quote("bybit", "150.00", "1", 10*time.Second),
+		// устарела по общему сроку, у coinbase срок свежести дольше
+		quote("kraken", "101.00", "100", 2*time.Minute),
+		quote("coinbase", "99.00", "0", 45*time.Second),
+		// у пары одна биржа, меньше min_sources
+		{Exchange: "binance", Symbol: "ETHUSDT", Market: "crypto", Base: "ETH", Quote: "USDT", Price: "2000", Volume: "1", Timestamp: now},
+	}
+
+	tests := []struct {
+		method string
+		price  string
+		volume string
+	}{
+		{processor.CompositeMedian, "101", "5"},
+		{processor.CompositeVWAP, "110.4", "5"},
+		// bybit дальше 2% от медианы
+		{processor.CompositeTrimmed, "100.5", "4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			spec.Method = tt.method
+			ticks := processor.ComputeComposite(spec, quotes, now)
+			require.Len(t, ticks, 1)
+			assert.Equal(t, storage.MarketData{
+				Exchange: processor.CompositeExchange, Symbol: "BTC-USDT", Market: "crypto",
+				Price: tt.price, Volume: tt.volume, Timestamp: now,
+			}, ticks[0])
+		})
+	}
+
+	// ни одна биржа не близка к медиане 125, цена пары не публикуется
+	spec.Method = processor.CompositeTrimmed
+	diverged := []storage.PairQuote{
+		quote("binance", "100.00", "1", time.Second),
+		quote("okx", "150.00", "1", time.Second),
+	}
+	assert.Empty(t, processor.ComputeComposite(spec, diverged, now))
+
+	// пулы DEX обновляются реже: срок свежести задан для рынка dex, а не для имени биржи пула
+	pool := func(exchange, price string, age time.Duration) storage.PairQuote {
+		return storage.PairQuote{Exchange: exchange, Symbol: "WETH-USDC", Market: "dex", Base: "ETH", Quote: "USDC",
+			Price: price, Volume: "1", Timestamp: now.Add(-age)}
+	}
+	dex := []storage.PairQuote{
+		pool("uniswap_v2", "3000", 3*time.Minute),
+		pool("uniswap_v3", "3002", 4*time.Minute),
+		// старше срока рынка dex
+		pool("sushiswap", "3010", 6*time.Minute),
+	}
+	ticks := processor.ComputeComposite(spec, dex, now)
+	require.Len(t, ticks, 1)
+	assert.Equal(t, "ETH-USDC", ticks[0].Symbol)
+	assert.Equal(t, "3001", ticks[0].Price)
+}
+
+func TestUsdConverter_Convert(t *testing.T) {
+	converter := processor.NewUsdConverter()
+	converter.SetRates([]storage.FxRate{
+		{Base: "EUR", Quote: "USD", Rate: "1.08", Source: "ecb"},
+		{Base: "USD", Quote: "RUB", Rate: "90", Source: "cbr"},
+		{Base: "USDT", Quote: "USD", Rate: "0.999", Source: "coinbase"},
+		{Base: "BTC", Quote: "USDT", Rate: "60000", Source: "composite"},
+		// прямого курса USD нет, пересчет через USDT
+		{Base: "USDC", Quote: "USDT", Rate: "1.001", Source: "binance"},
+	})
+
+	tick := func(price, volume, quoteVolume string) storage.MarketData {
+		return storage.MarketData{Exchange: "test", Symbol: "X", Market: "crypto", Price: price, Volume: volume, QuoteVolume: quoteVolume}
+	}
+
+	tests := []struct {
+		name   string
+		tick   storage.MarketData
+		quote  string
+		price  string
+		volume string
+		path   string
+	}{
+		{"usd", tick("100.5", "10", ""), "USD", "100.5", "1005", "USD"},
+		{"fx", tick("100.00", "2", "200"), "EUR", "108", "216", "EUR/USD@ecb"},
+		{"inverse fx", tick("9000", "1", ""), "RUB", "100", "100", "USD/RUB@cbr"},
+		{"stablecoin", tick("60000", "1", ""), "USDT", "59940", "59940", "USDT/USD@coinbase"},
+		{"stablecoin via stablecoin", tick("1.0000", "1000", ""), "USDC", "0.999999", "1000", "USDC/USDT@binance>USDT/USD@coinbase"},
+		{"crypto quote", tick("0.05", "3", ""), "BTC", "2997", "8991", "BTC/USDT@composite>USDT/USD@coinbase"},
+		{"unknown quote", tick("10", "1", ""), "TRY", "", "", ""},
+		// биржа не передала объем: оборот неизвестен, а не нулевой
+		{"no volume", tick("100.5", "", ""), "USD", "100.5", "", "USD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := converter.Convert(tt.tick, tt.quote)
+			assert.Equal(t, tt.price, got.PriceUSD)
+			assert.Equal(t, tt.volume, got.VolumeUSD)
+			assert.Equal(t, tt.path, got.UsdPath)
+		})
+	}
+}
+
+func TestConflator_Accept(t *testing.T) {
+	rule, err := processor.LoadConflationRule("../specs/conflation.yaml", "bybit")
+	require.NoError(t, err)
+	assert.Equal(t, processor.ConflationRule{Exchange: "bybit", MinInterval: 250 * time.Millisecond, MinChangeBps: 0.5, MaxInterval: 20 * time.Second}, rule)
+
+	composite, err := processor.LoadCompositeSpec("../specs/composite.yaml")
+	require.NoError(t, err)
+	assert.Less(t, rule.MaxInterval, composite.MaxAge, "ровный тикер должен оставаться в композитной цене")
+
+	conflator := processor.NewConflator(rule)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tick := func(price string, after time.Duration) storage.MarketData {
+		return storage.MarketData{Exchange: "bybit", Symbol: "BTCUSDT", Market: "crypto", Price: price, Timestamp: start.Add(after)}
+	}
+
+	assert.True(t, conflator.Accept(tick("100000", 0)), "первый тик тикера")
+	assert.False(t, conflator.Accept(tick("100100", 100*time.Millisecond)), "раньше min_interval")
+	assert.False(t, conflator.Accept(tick("100004", 300*time.Millisecond)), "изменение 0.4 bps")
+	assert.True(t, conflator.Accept(tick("100005", 400*time.Millisecond)), "изменение 0.5 bps")
+	assert.True(t, conflator.Accept(tick("100005", 400*time.Millisecond+20*time.Second)), "прошел max_interval")
+
+	// после неудачной записи повторно доставленный тик не отбрасывается
+	conflator.Forget([]storage.MarketData{tick("100005", 0)})
+	assert.True(t, conflator.Accept(tick("100005", 21*time.Second)))
+
+	// отложенный тик записывается по таймеру, даже если за ним рынок затих
+	assert.False(t, conflator.Accept(tick("100500", 21*time.Second+100*time.Millisecond)))
+	assert.Empty(t, conflator.TakeDue(start.Add(21*time.Second+200*time.Millisecond)), "раньше min_interval")
+	assert.Equal(t, []storage.MarketData{tick("100500", 21*time.Second+100*time.Millisecond)},
+		conflator.TakeDue(start.Add(21*time.Second+300*time.Millisecond)))
+	assert.Empty(t, conflator.TakeDue(start.Add(time.Minute)), "отложенный тик записывается один раз")
+
+	// тик без изменения цены ждет max_interval
+	assert.False(t, conflator.Accept(tick("100501", 22*time.Second)))
+	assert.Empty(t, conflator.TakeDue(start.Add(30*time.Second)))
+	assert.Len(t, conflator.TakeDue(start.Add(41*time.Second+100*time.Millisecond)), 1)
+
+	// отброшенными считаются отложенные тики, которые заменил следующий
+	assert.Equal(t, int64(1), conflator.Stats().Dropped)
+
+	// без ограничений записывается каждый тик
+	defaults, err := processor.LoadConflationRule("../specs/conflation.yaml", "okx")
+	require.NoError(t, err)
+	all := processor.NewConflator(defaults)
+	assert.True(t, all.Accept(tick("1", 0)))
+	assert.True(t, all.Accept(tick("1", 0)))
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"preprocessor/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTickerCache_SingleFlight(t *testing.T) {
+	cache := storage.NewTickerCache()
+	key := storage.TickerKey{Exchange: "uniswap_v3", Symbol: "WETHUSDC", Market: "dex"}
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(keys []storage.TickerKey) (map[storage.TickerKey]int64, error) {
+		loads.Add(1)
+		<-release
+		ids := make(map[storage.TickerKey]int64, len(keys))
+		for _, k := range keys {
+			ids[k] = 42
+		}
+		return ids, nil
+	}
+
+	// все воркеры получают новый тикер одновременно, в базу идет один запрос
+	const workers = 8
+	var wg sync.WaitGroup
+	results := make([]int64, workers)
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids, err := cache.Resolve(context.Background(), []storage.TickerKey{key, key}, load)
+			results[i], errs[i] = ids[key], err
+		}(i)
+	}
+	require.Eventually(t, func() bool { return cache.Stats().Misses == workers }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), loads.Load())
+	for i := 0; i < workers; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, int64(42), results[i])
+	}
+
+	ids, err := cache.Resolve(context.Background(), []storage.TickerKey{key}, load)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), ids[key])
+	assert.Equal(t, int32(1), loads.Load(), "повторный запрос берется из кэша")
+	assert.Equal(t, storage.TickerCacheStats{Hits: 1, Misses: workers, Size: 1}, cache.Stats())
+}
+
+func TestTickerCache_LoadError(t *testing.T) {
+	cache := storage.NewTickerCache()
+	key := storage.TickerKey{Exchange: "binance", Symbol: "BTCUSDT", Market: "crypto"}
+
+	release := make(chan struct{})
+	failing := func(keys []storage.TickerKey) (map[storage.TickerKey]int64, error) {
+		<-release
+		return nil, errors.New("connection refused")
+	}
+
+	// ожидающий запрос получает ошибку загрузки, а не пустой ID
+	owner := make(chan error, 1)
+	go func() {
+		_, err := cache.Resolve(context.Background(), []storage.TickerKey{key}, failing)
+		owner <- err
+	}()
+	require.Eventually(t, func() bool { return cache.Stats().Misses == 1 }, time.Second, time.Millisecond)
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := cache.Resolve(context.Background(), []storage.TickerKey{key}, failing)
+		waiter <- err
+	}()
+	require.Eventually(t, func() bool { return cache.Stats().Misses == 2 }, time.Second, time.Millisecond)
+	close(release)
+
+	assert.EqualError(t, <-owner, "connection refused")
+	assert.EqualError(t, <-waiter, "connection refused")
+	assert.Equal(t, 0, cache.Stats().Size)
+
+	// ошибка не запоминается, следующий запрос загружает тикер снова
+	ids, err := cache.Resolve(context.Background(), []storage.TickerKey{key}, func(keys []storage.TickerKey) (map[storage.TickerKey]int64, error) {
+		return map[storage.TickerKey]int64{key: 7}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), ids[key])
+}
+
+func TestTickerCache_Notification(t *testing.T) {
+	cache := storage.NewTickerCache()
+	renamed := storage.TickerKey{Exchange: "okx", Symbol: "MATIC-USDT", Market: "crypto"}
+	other := storage.TickerKey{Exchange: "okx", Symbol: "BTC-USDT", Market: "crypto"}
+	cache.Store(renamed, 5)
+	cache.Store(other, 6)
+
+	// после переименования старый ключ известен только по ID из уведомления
+	cache.HandleNotification("5")
+	cache.HandleNotification("not-an-id")
+	assert.Equal(t, 1, cache.Stats().Size)
+
+	var loaded []storage.TickerKey
+	ids, err := cache.Resolve(context.Background(), []storage.TickerKey{renamed, other}, func(keys []storage.TickerKey) (map[storage.TickerKey]int64, error) {
+		loaded = append(loaded, keys...)
+		return map[storage.TickerKey]int64{renamed: 9}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []storage.TickerKey{renamed}, loaded, "перечитывается только сброшенный тикер")
+	assert.Equal(t, map[storage.TickerKey]int64{renamed: 9, other: 6}, ids)
+
+	cache.Clear()
+	assert.Equal(t, 0, cache.Stats().Size)
+}
+
+func TestTickerCache_MissingID(t *testing.T) {
+	cache := storage.NewTickerCache()
+	key := storage.TickerKey{Exchange: "bybit", Symbol: "BTCUSDT", Market: "crypto"}
+
+	_, err := cache.Resolve(context.Background(), []storage.TickerKey{key}, func(keys []storage.TickerKey) (map[storage.TickerKey]int64, error) {
+		return map[storage.TickerKey]int64{}, nil
+	})
+	assert.ErrorContains(t, err, "no ticker id for bybit BTCUSDT crypto")
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"request-service/internal/service"
 
@@ -39,6 +40,10 @@ func (h *Handler) RegisterRoutes() http.Handler {
 	r.HandleFunc("/exchange/{exchange}", h.GetExchangeData).Methods("GET", "OPTIONS")
 	r.HandleFunc("/exchange/{exchange}/asset/{symbol}", h.GetAssetDetails).Methods("GET", "OPTIONS")
 	r.HandleFunc("/exchange/{exchange}/asset/{symbol}/graph/{interval}", h.GetAssetGraph).Methods("GET", "OPTIONS")
+	r.HandleFunc("/pair/{base}/{quote}", h.GetPair).Methods("GET", "OPTIONS")
+	r.HandleFunc("/composite/{base}/{quote}", h.GetComposite).Methods("GET", "OPTIONS")
+	r.HandleFunc("/options/{underlying}", h.GetOptionsChain).Methods("GET", "OPTIONS")
+	r.HandleFunc("/options/{underlying}/{symbol}", h.GetOption).Methods("GET", "OPTIONS")
 
 	return corsMiddleware(r)
 }
@@ -155,4 +160,76 @@ func (h *Handler) GetAssetDetails(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Asset not found", http.StatusNotFound)
 }
 
+// GetPair - пара на всех биржах, например /pair/BTC/USDT; синонимы вроде XBT допускаются
+func (h *Handler) GetPair(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	data, err := h.marketService.GetPairData(vars["base"], vars["quote"])
+	if err != nil {
+		http.Error(w, "Failed to fetch pair data", http.StatusInternalServerError)
+		return
+	}
+
+	if len(data) == 0 {
+		http.Error(w, "Pair not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, data)
+}
+
+// GetComposite - композитная цена пары по всем биржам, например /composite/BTC/USDT
+func (h *Handler) GetComposite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	data, err := h.marketService.GetCompositeData(vars["base"], vars["quote"])
+	if err != nil {
+		http.Error(w, "Failed to fetch composite price", http.StatusInternalServerError)
+		return
+	}
+
+	if len(data) == 0 {
+		http.Error(w, "Composite price not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, data)
+}
+
 func (h *Handler) GetAssetGraph(w http.ResponseWriter, r *http.Request) {}
+
+func (h *Handler) GetOptionsChain(w http.ResponseWriter, r *http.Request) {
+	underlying := strings.ToUpper(mux.Vars(r)["underlying"])
+
+	chain, err := h.marketService.GetOptionsChain(underlying, "")
+	if err != nil {
+		http.Error(w, "Failed to fetch options data", http.StatusInternalServerError)
+		return
+	}
+
+	if len(chain) == 0 {
+		http.Error(w, "Options not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, chain)
+}
+
+func (h *Handler) GetOption(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	underlying := strings.ToUpper(vars["underlying"])
+	symbol := strings.ToUpper(vars["symbol"])
+
+	chain, err := h.marketService.GetOptionsChain(underlying, symbol)
+	if err != nil {
+		http.Error(w, "Failed to fetch options data", http.StatusInternalServerError)
+		return
+	}
+
+	if len(chain) == 0 {
+		http.Error(w, "Option not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, chain[0])
+}
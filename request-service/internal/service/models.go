@@ -1,15 +1,61 @@
 package service
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
+// ResponseMarketData - цены отдаются числами JSON в точной десятичной записи из базы;
+// неизвестные суточные значения, лучшие цены и спред отдаются как null
 type ResponseMarketData struct {
 	Exchange           string
 	Symbol             string
 	Market             string
-	Price              float64
-	Volume             float64
-	High               float64
-	Low                float64
-	PriceChangePercent string
+	Base               string // канонический базовый актив, пусто если символ не разобран
+	Quote              string
+	Price              json.Number
+	Volume             json.Number
+	High               json.Number
+	Low                json.Number
+	PriceChange        *json.Number
+	PriceChangePercent *json.Number
+	VWAP               *json.Number
+	QuoteVolume        *json.Number
+	Bid                *json.Number
+	BidSize            *json.Number
+	Ask                *json.Number
+	AskSize            *json.Number
+	SpreadBps          *json.Number
+	PriceUSD           *json.Number
+	VolumeUSD          *json.Number
+	UsdPath            string // цепочка курсов пересчета в USD, пусто если цена не пересчитана
 	Timestamp          time.Time
 }
+
+// ResponseOptionData - числа отдаются в точной десятичной записи из базы, как у ResponseMarketData
+type ResponseOptionData struct {
+	Exchange        string
+	Symbol          string
+	Underlying      string
+	Expiration      time.Time
+	Strike          json.Number
+	OptionType      string
+	MarkPrice       *json.Number
+	MarkIV          *json.Number
+	BidIV           *json.Number
+	AskIV           *json.Number
+	BestBidPrice    *json.Number
+	BestAskPrice    *json.Number
+	UnderlyingPrice *json.Number
+	OpenInterest    *json.Number
+	Greeks          ResponseGreeks
+	Timestamp       time.Time
+}
+
+type ResponseGreeks struct {
+	Delta *json.Number
+	Gamma *json.Number
+	Vega  *json.Number
+	Theta *json.Number
+	Rho   *json.Number
+}
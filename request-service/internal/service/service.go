@@ -1,7 +1,11 @@
 package service
 
 import (
+	"encoding/json"
+	"math/big"
 	"request-service/internal/storage"
+	"sort"
+	"strings"
 )
 
 type MarketService struct {
@@ -22,20 +26,149 @@ func (s *MarketService) GetLatestData() ([]ResponseMarketData, error) {
 		return nil, err
 	}
 
-	s.response = make([]ResponseMarketData, 0)
-	for _, d := range lastData {
-		s.response = append(s.response, ResponseMarketData{
+	s.response = toResponse(lastData)
+	return s.response, nil
+}
+
+// GetPairData - последние данные пары на всех биржах, отсортированные по бирже
+func (s *MarketService) GetPairData(base, quote string) ([]ResponseMarketData, error) {
+	data, err := s.storage.GetLatestPairData(base, quote)
+	if err != nil {
+		return nil, err
+	}
+	return toResponse(data), nil
+}
+
+// GetCompositeData - композитная цена пары, рассчитанная по всем биржам
+func (s *MarketService) GetCompositeData(base, quote string) ([]ResponseMarketData, error) {
+	data, err := s.storage.GetLatestCompositeData(base, quote)
+	if err != nil {
+		return nil, err
+	}
+	return toResponse(data), nil
+}
+
+func toResponse(data []storage.MarketData) []ResponseMarketData {
+	response := make([]ResponseMarketData, 0, len(data))
+	for _, d := range data {
+		response = append(response, ResponseMarketData{
 			Exchange:           d.Exchange,
 			Symbol:             d.Symbol,
 			Market:             d.Market,
-			Price:              float64(d.Price) / 1e3,
-			Volume:             float64(d.Volume) / 1e3,
-			High:               float64(d.High) / 1e3,
-			Low:                float64(d.Low) / 1e3,
-			PriceChangePercent: d.PriceChangePercent + "%",
+			Base:               optionalString(d.Base),
+			Quote:              optionalString(d.Quote),
+			Price:              withScale(d.Price, d.PriceScale),
+			Volume:             json.Number(d.Volume),
+			High:               withScale(d.High, d.PriceScale),
+			Low:                withScale(d.Low, d.PriceScale),
+			PriceChange:        optionalNumber(d.PriceChange),
+			PriceChangePercent: optionalNumber(d.PriceChangePercent),
+			VWAP:               optionalNumber(d.VWAP),
+			QuoteVolume:        optionalNumber(d.QuoteVolume),
+			Bid:                optionalPrice(d.Bid, d.PriceScale),
+			BidSize:            optionalNumber(d.BidSize),
+			Ask:                optionalPrice(d.Ask, d.PriceScale),
+			AskSize:            optionalNumber(d.AskSize),
+			SpreadBps:          optionalNumber(d.SpreadBps),
+			PriceUSD:           optionalNumber(d.PriceUSD),
+			VolumeUSD:          optionalNumber(d.VolumeUSD),
+			UsdPath:            optionalString(d.UsdPath),
 			Timestamp:          d.Timestamp,
 		})
 	}
+	return response
+}
 
-	return s.response, nil
+// GetOptionsChain - последние снимки опционов на базовый актив, отсортированные по экспирации и страйку
+func (s *MarketService) GetOptionsChain(underlying, symbol string) ([]ResponseOptionData, error) {
+	data, err := s.storage.GetLatestOptionsData(underlying, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]ResponseOptionData, 0, len(data))
+	for _, d := range data {
+		chain = append(chain, ResponseOptionData{
+			Exchange:        d.Exchange,
+			Symbol:          d.Symbol,
+			Underlying:      d.Underlying,
+			Expiration:      d.Expiration,
+			Strike:          json.Number(d.Strike),
+			OptionType:      d.OptionType,
+			MarkPrice:       optionalNumber(d.MarkPrice),
+			MarkIV:          optionalNumber(d.MarkIV),
+			BidIV:           optionalNumber(d.BidIV),
+			AskIV:           optionalNumber(d.AskIV),
+			BestBidPrice:    optionalNumber(d.BestBidPrice),
+			BestAskPrice:    optionalNumber(d.BestAskPrice),
+			UnderlyingPrice: optionalNumber(d.UnderlyingPrice),
+			OpenInterest:    optionalNumber(d.OpenInterest),
+			Greeks: ResponseGreeks{
+				Delta: optionalNumber(d.Delta),
+				Gamma: optionalNumber(d.Gamma),
+				Vega:  optionalNumber(d.Vega),
+				Theta: optionalNumber(d.Theta),
+				Rho:   optionalNumber(d.Rho),
+			},
+			Timestamp: d.Timestamp,
+		})
+	}
+
+	sort.Slice(chain, func(i, j int) bool {
+		if !chain[i].Expiration.Equal(chain[j].Expiration) {
+			return chain[i].Expiration.Before(chain[j].Expiration)
+		}
+		if c := compareDecimal(chain[i].Strike, chain[j].Strike); c != 0 {
+			return c < 0
+		}
+		return chain[i].OptionType < chain[j].OptionType
+	})
+	return chain, nil
+}
+
+// withScale - дополняет цену нулями до точности инструмента; значащие знаки не отбрасываются
+func withScale(value string, scale *int) json.Number {
+	if scale == nil || *scale <= 0 {
+		return json.Number(value)
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if len(fraction) >= *scale {
+		return json.Number(value)
+	}
+	return json.Number(whole + "." + fraction + strings.Repeat("0", *scale-len(fraction)))
+}
+
+// compareDecimal - сравнивает десятичные записи по значению, а не как строки ("95" < "100")
+func compareDecimal(a, b json.Number) int {
+	x, okA := new(big.Rat).SetString(string(a))
+	y, okB := new(big.Rat).SetString(string(b))
+	if !okA || !okB {
+		return strings.Compare(string(a), string(b))
+	}
+	return x.Cmp(y)
+}
+
+func optionalNumber(value *string) *json.Number {
+	if value == nil {
+		return nil
+	}
+	n := json.Number(*value)
+	return &n
+}
+
+// optionalPrice - цена с точностью инструмента, как у Price
+func optionalPrice(value *string, scale *int) *json.Number {
+	if value == nil {
+		return nil
+	}
+	n := withScale(*value, scale)
+	return &n
+}
+
+func optionalString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
 }
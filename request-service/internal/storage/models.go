@@ -2,14 +2,56 @@ package storage
 
 import "time"
 
+// MarketData - NUMERIC значения читаются текстом, чтобы не терять точность;
+// PriceScale - точность цены инструмента, nil если справочник ее не содержит;
+// суточные значения, лучшие цены и спред равны nil, если биржа не позволяет их получить
 type MarketData struct {
 	Exchange           string
 	Symbol             string
 	Market             string
-	Price              int64
-	Volume             int64
-	High               int64
-	Low                int64
-	PriceChangePercent string
+	Base               *string // каноническая пара из реестра активов, nil если символ не разобран
+	Quote              *string
+	Price              string
+	Volume             string
+	High               string
+	Low                string
+	PriceScale         *int
+	PriceChange        *string
+	PriceChangePercent *string
+	VWAP               *string
+	QuoteVolume        *string
+	Bid                *string
+	BidSize            *string
+	Ask                *string
+	AskSize            *string
+	SpreadBps          *string
+	PriceUSD           *string // цена и оборот в USD, nil если курса валюты котировки не было
+	VolumeUSD          *string
+	UsdPath            *string
 	Timestamp          time.Time
 }
+
+// OptionsData - последний снимок опциона; NUMERIC значения читаются текстом, как у MarketData;
+// NULL в числовых полях означает, что биржа не прислала значение
+type OptionsData struct {
+	Exchange        string
+	Symbol          string
+	Underlying      string
+	Expiration      time.Time
+	Strike          string
+	OptionType      string
+	MarkPrice       *string
+	MarkIV          *string
+	BidIV           *string
+	AskIV           *string
+	BestBidPrice    *string
+	BestAskPrice    *string
+	UnderlyingPrice *string
+	OpenInterest    *string
+	Delta           *string
+	Gamma           *string
+	Vega            *string
+	Theta           *string
+	Rho             *string
+	Timestamp       time.Time
+}
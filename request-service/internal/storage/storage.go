@@ -25,6 +25,28 @@ func (s *Storage) Close() {
 }
 
 func (s *Storage) GetLatestMarketData() ([]MarketData, error) {
+	return s.queryLatestMarketData("")
+}
+
+// GetLatestPairData - последние данные пары на всех биржах; синонимы активов (XBT) приводятся к каноническому коду
+func (s *Storage) GetLatestPairData(base, quote string) ([]MarketData, error) {
+	return s.queryLatestMarketData(`
+		WHERE t.base_asset = COALESCE((SELECT asset FROM asset_aliases WHERE alias = upper($1)), upper($1))
+		  AND t.quote_asset = COALESCE((SELECT asset FROM asset_aliases WHERE alias = upper($2)), upper($2))
+	`, base, quote)
+}
+
+// GetLatestCompositeData - последняя композитная цена пары на каждом рынке
+func (s *Storage) GetLatestCompositeData(base, quote string) ([]MarketData, error) {
+	return s.queryLatestMarketData(`
+		WHERE t.exchange = 'composite'
+		  AND t.base_asset = COALESCE((SELECT asset FROM asset_aliases WHERE alias = upper($1)), upper($1))
+		  AND t.quote_asset = COALESCE((SELECT asset FROM asset_aliases WHERE alias = upper($2)), upper($2))
+	`, base, quote)
+}
+
+// queryLatestMarketData - последняя запись каждого тикера, отобранного условием where
+func (s *Storage) queryLatestMarketData(where string, args ...any) ([]MarketData, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -33,16 +55,32 @@ func (s *Storage) GetLatestMarketData() ([]MarketData, error) {
 			   t.exchange, 
 			   t.symbol, 
 			   t.market,
-			   m.price, 
-			   m.volume,
-			   m.high_price,
-			   m.low_price,
-			   m.price_change_percent,
+			   t.base_asset,
+			   t.quote_asset,
+			   m.price::text,
+			   COALESCE(m.volume, 0)::text,
+			   COALESCE(m.high_price, 0)::text,
+			   COALESCE(m.low_price, 0)::text,
+			   i.price_scale,
+			   m.price_change::text,
+			   m.price_change_percent::text,
+			   m.vwap::text,
+			   m.quote_volume::text,
+			   m.bid::text,
+			   m.bid_size::text,
+			   m.ask::text,
+			   m.ask_size::text,
+			   m.spread_bps::text,
+			   m.price_usd::text,
+			   m.volume_usd::text,
+			   m.usd_path,
 			   m.timestamp
 		FROM market_data m
 		JOIN tickers t ON m.ticker_id = t.id
+		LEFT JOIN instruments i ON i.ticker_id = t.id
+		`+where+`
 		ORDER BY t.exchange, t.symbol, t.market, m.timestamp DESC
-	`)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch market data: %w", err)
 	}
@@ -51,10 +89,62 @@ func (s *Storage) GetLatestMarketData() ([]MarketData, error) {
 	var data []MarketData
 	for rows.Next() {
 		var d MarketData
-		if err := rows.Scan(&d.Exchange, &d.Symbol, &d.Market, &d.Price, &d.Volume, &d.High, &d.Low, &d.PriceChangePercent, &d.Timestamp); err != nil {
+		if err := rows.Scan(&d.Exchange, &d.Symbol, &d.Market, &d.Base, &d.Quote, &d.Price, &d.Volume, &d.High, &d.Low, &d.PriceScale,
+			&d.PriceChange, &d.PriceChangePercent, &d.VWAP, &d.QuoteVolume,
+			&d.Bid, &d.BidSize, &d.Ask, &d.AskSize, &d.SpreadBps, &d.PriceUSD, &d.VolumeUSD, &d.UsdPath, &d.Timestamp); err != nil {
 			return nil, fmt.Errorf("failed to scan market data: %w", err)
 		}
 		data = append(data, d)
 	}
 	return data, nil
 }
+
+// GetLatestOptionsData - последний снимок каждого опциона на базовый актив; symbol сужает выборку до одного опциона
+func (s *Storage) GetLatestOptionsData(underlying, symbol string) ([]OptionsData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (t.exchange, t.symbol)
+			   t.exchange,
+			   t.symbol,
+			   o.underlying,
+			   o.expiration,
+			   o.strike::text,
+			   o.option_type,
+			   o.mark_price::text,
+			   o.mark_iv::text,
+			   o.bid_iv::text,
+			   o.ask_iv::text,
+			   o.best_bid_price::text,
+			   o.best_ask_price::text,
+			   o.underlying_price::text,
+			   o.open_interest::text,
+			   o.delta::text,
+			   o.gamma::text,
+			   o.vega::text,
+			   o.theta::text,
+			   o.rho::text,
+			   o.timestamp
+		FROM options_data o
+		JOIN tickers t ON o.ticker_id = t.id
+		WHERE o.underlying = $1 AND ($2 = '' OR t.symbol = $2) AND o.expiration > now()
+		ORDER BY t.exchange, t.symbol, o.timestamp DESC
+	`, underlying, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch options data: %w", err)
+	}
+	defer rows.Close()
+
+	var data []OptionsData
+	for rows.Next() {
+		var d OptionsData
+		if err := rows.Scan(&d.Exchange, &d.Symbol, &d.Underlying, &d.Expiration, &d.Strike, &d.OptionType,
+			&d.MarkPrice, &d.MarkIV, &d.BidIV, &d.AskIV, &d.BestBidPrice, &d.BestAskPrice, &d.UnderlyingPrice,
+			&d.OpenInterest, &d.Delta, &d.Gamma, &d.Vega, &d.Theta, &d.Rho, &d.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan options data: %w", err)
+		}
+		data = append(data, d)
+	}
+	return data, rows.Err()
+}
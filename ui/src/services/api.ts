@@ -12,7 +12,7 @@ const mapResponseToMarketData = (data: any): MarketData => {
     volume: typeof data.Volume === 'number' ? data.Volume : undefined,
     high: typeof data.High === 'number' ? data.High : undefined,
     low: typeof data.Low === 'number' ? data.Low : undefined,
-    priceChangePercent: data.PriceChangePercent || '',
+    priceChangePercent: typeof data.PriceChangePercent === 'number' ? `${data.PriceChangePercent}%` : '',
     timestamp: data.Timestamp || '',
   };
 };